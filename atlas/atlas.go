@@ -7,7 +7,11 @@ import (
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/cache"
 	_ "github.com/airmap/tegola/cache/filecache"
+	_ "github.com/airmap/tegola/cache/mbtilescache"
+	_ "github.com/airmap/tegola/cache/memorycache"
+	_ "github.com/airmap/tegola/cache/rediscache"
 	_ "github.com/airmap/tegola/cache/s3cache"
+	_ "github.com/airmap/tegola/cache/tieredcache"
 )
 
 //	DefaultAtlas is instanitated for convenience
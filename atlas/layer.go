@@ -1,8 +1,11 @@
 package atlas
 
 import (
+	"context"
+
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/vector_tile"
 )
 
 type Layer struct {
@@ -13,9 +16,14 @@ type Layer struct {
 	MaxZoom           int
 	//	instantiated provider
 	Provider mvt.Provider
+	//	optional. the name the provider is configured with. the layers of a provider
+	//	implementing mvt.BatchProvider are fetched together when it's set
+	ProviderName string
 	//	default tags to include when encoding the layer. provider tags take precedence
 	DefaultTags map[string]interface{}
 	GeomType    tegola.Geometry
+	//	the layer's attribute names mapped to their type. nil if the provider does not know them
+	Fields map[string]string
 	//	if true, ignore the layer when encoding
 	Disabled bool
 }
@@ -28,3 +36,18 @@ func (l *Layer) MVTName() string {
 
 	return l.ProviderLayerName
 }
+
+//	encode fetches the layer from its provider and encodes it on its own
+func (l *Layer) encode(ctx context.Context, tile tegola.Tile) (*vectorTile.Tile_Layer, error) {
+	mvtLayer, err := l.Provider.MVTLayer(ctx, l.ProviderLayerName, tile, l.DefaultTags)
+	if err != nil {
+		return nil, err
+	}
+
+	//	check if we have a layer name
+	if l.Name != "" {
+		mvtLayer.Name = l.Name
+	}
+
+	return mvtLayer.VTileLayer(ctx, tile.BoundingBox())
+}
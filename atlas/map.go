@@ -2,15 +2,19 @@ package atlas
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/basic"
 	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/vector_tile"
 	"github.com/airmap/tegola/provider/debug"
 )
 
@@ -43,6 +47,33 @@ func NewWGS84Map(name string) Map {
 				MinZoom:           0,
 				MaxZoom:           MaxZoom,
 			},
+			{
+				Name:              debug.LayerDebugTileBuffer,
+				ProviderLayerName: debug.LayerDebugTileBuffer,
+				Provider:          debugProvider,
+				GeomType:          basic.Polygon{},
+				Disabled:          true,
+				MinZoom:           0,
+				MaxZoom:           MaxZoom,
+			},
+			{
+				Name:              debug.LayerDebugTileGrid,
+				ProviderLayerName: debug.LayerDebugTileGrid,
+				Provider:          debugProvider,
+				GeomType:          basic.MultiLine{},
+				Disabled:          true,
+				MinZoom:           0,
+				MaxZoom:           MaxZoom,
+			},
+			{
+				Name:              debug.LayerDebugTileStats,
+				ProviderLayerName: debug.LayerDebugTileStats,
+				Provider:          debugProvider,
+				GeomType:          basic.Point{},
+				Disabled:          true,
+				MinZoom:           0,
+				MaxZoom:           MaxZoom,
+			},
 		},
 		SRID: tegola.WGS84,
 	}
@@ -98,7 +129,7 @@ func (m Map) EnableDebugLayers() Map {
 	m.Layers = layers
 
 	for i := range m.Layers {
-		if m.Layers[i].Name == debug.LayerDebugTileCenter || m.Layers[i].Name == debug.LayerDebugTileOutline {
+		if isDebugLayer(m.Layers[i].Name) {
 			m.Layers[i].Disabled = false
 		}
 	}
@@ -113,7 +144,7 @@ func (m Map) DisableDebugLayers() Map {
 	m.Layers = layers
 
 	for i := range m.Layers {
-		if m.Layers[i].Name == debug.LayerDebugTileCenter || m.Layers[i].Name == debug.LayerDebugTileOutline {
+		if isDebugLayer(m.Layers[i].Name) {
 			m.Layers[i].Disabled = true
 		}
 	}
@@ -121,6 +152,55 @@ func (m Map) DisableDebugLayers() Map {
 	return m
 }
 
+//	SetDebugGridSpacing returns the map with the default debug-tile-grid layer drawing its grid lines
+//	at the spacing, in tile coordinates
+func (m Map) SetDebugGridSpacing(spacing int) (Map, error) {
+	debugProvider, err := debug.NewProvider(map[string]interface{}{
+		debug.ConfigKeyGridSpacing: int64(spacing),
+	})
+	if err != nil {
+		return m, err
+	}
+
+	//	make an explict copy of the layers
+	layers := make([]Layer, len(m.Layers))
+	copy(layers, m.Layers)
+	m.Layers = layers
+
+	for i := range m.Layers {
+		if _, ok := m.Layers[i].Provider.(*debug.Provider); ok && m.Layers[i].Name == debug.LayerDebugTileGrid {
+			m.Layers[i].Provider = debugProvider
+		}
+	}
+
+	return m, nil
+}
+
+//	isDebugLayer reports whether the layer name is one of the default debug layers
+func isDebugLayer(name string) bool {
+	for _, l := range debug.Layers {
+		if name == l {
+			return true
+		}
+	}
+	return false
+}
+
+//	isStatsLayer reports whether the layer is the debug-tile-stats layer, which is encoded
+//	after the tile's other layers so it can report their stats
+func isStatsLayer(l Layer) bool {
+	p := l.Provider
+	//	unwrap limited providers (see provider.Limit)
+	if w, ok := p.(interface {
+		Provider() mvt.Provider
+	}); ok {
+		p = w.Provider()
+	}
+
+	_, ok := p.(*debug.Provider)
+	return ok && l.ProviderLayerName == debug.LayerDebugTileStats
+}
+
 //	EnableLayersByZoom returns layers that that are to be rendered between a min and max zoom
 func (m Map) EnableLayersByZoom(zoom int) Map {
 	//	make an explict copy of the layers
@@ -164,6 +244,62 @@ func (m Map) EnableLayersByName(names ...string) Map {
 	return m
 }
 
+//	Params returns the request parameters, from the provided query string values, that are accepted
+//	by the map's layers. Values for parameters the layers don't declare are dropped so they can't
+//	affect the encoded tile or its cache key.
+func (m Map) Params(query url.Values) mvt.Params {
+	params := mvt.Params{}
+
+	for i := range m.Layers {
+		pp, ok := m.Layers[i].Provider.(mvt.ParamsProvider)
+		if !ok {
+			continue
+		}
+
+		for _, name := range pp.LayerParams(m.Layers[i].ProviderLayerName) {
+			if _, ok := query[name]; ok {
+				params[name] = query.Get(name)
+			}
+		}
+	}
+
+	return params
+}
+
+//	fetchLayers fetches the layers, at the indexes, from their provider. The layers must share a
+//	provider implementing mvt.BatchProvider if there's more than one.
+func (m Map) fetchLayers(ctx context.Context, tile tegola.Tile, indexes []int) ([]*mvt.Layer, error) {
+	if len(indexes) == 1 {
+		l := m.Layers[indexes[0]]
+
+		layer, err := l.Provider.MVTLayer(ctx, l.ProviderLayerName, tile, l.DefaultTags)
+		if err != nil {
+			return nil, err
+		}
+		return []*mvt.Layer{layer}, nil
+	}
+
+	names := make([]string, len(indexes))
+	tags := make([]map[string]interface{}, len(indexes))
+	for j, i := range indexes {
+		names[j] = m.Layers[i].ProviderLayerName
+		tags[j] = m.Layers[i].DefaultTags
+	}
+
+	layers, err := m.Layers[indexes[0]].Provider.(mvt.BatchProvider).MVTLayers(ctx, names, tile, tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != len(indexes) {
+		return nil, fmt.Errorf("provider returned %v layers, expected %v", len(layers), len(indexes))
+	}
+
+	return layers, nil
+}
+
+//	Encode encodes the enabled layers of the map for the tile. Request parameters are passed to
+//	the providers via the context (see mvt.ContextWithParams). The layers of a provider implementing
+//	mvt.BatchProvider are fetched with a single MVTLayers call.
 //	TODO: support for max zoom
 func (m Map) Encode(ctx context.Context, tile tegola.Tile) ([]byte, error) {
 	//	generate a tile
@@ -174,24 +310,50 @@ func (m Map) Encode(ctx context.Context, tile tegola.Tile) ([]byte, error) {
 	//	layer stack
 	mvtLayers := make([]*mvt.Layer, len(m.Layers))
 
-	//	set our waitgroup count
-	wg.Add(len(m.Layers))
+	//	the debug-tile-stats layer, if enabled
+	var statsLayer *Layer
+
+	//	the indexes of the enabled layers grouped by the layers fetched together. layers of the same
+	//	provider are grouped if the provider can fetch them in one go (see mvt.BatchProvider)
+	var groups [][]int
+	batches := map[string]int{}
 
 	//	iterate our layers
 	for i, layer := range m.Layers {
 		// check if the label is disabled
 		if layer.Disabled {
-			wg.Done()
 			continue
 		}
 
-		//	go routine for fetching the layer concurrently
-		go func(i int, l Layer) {
+		if isStatsLayer(layer) {
+			statsLayer = &m.Layers[i]
+			continue
+		}
+
+		if _, ok := layer.Provider.(mvt.BatchProvider); ok && layer.ProviderName != "" {
+			if g, ok := batches[layer.ProviderName]; ok {
+				groups[g] = append(groups[g], i)
+				continue
+			}
+			batches[layer.ProviderName] = len(groups)
+		}
+
+		groups = append(groups, []int{i})
+	}
+
+	//	set our waitgroup count
+	wg.Add(len(groups))
+
+	fetchStart := time.Now()
+
+	for _, group := range groups {
+		//	go routine for fetching the layers concurrently
+		go func(group []int) {
 			//	on completion let the wait group know
 			defer wg.Done()
 
-			//	fetch layer from data provider
-			mvtLayer, err := l.Provider.MVTLayer(ctx, l.ProviderLayerName, tile, l.DefaultTags)
+			//	fetch the layers from the data provider
+			layers, err := m.fetchLayers(ctx, tile, group)
 			if err != nil {
 				switch err {
 				case mvt.ErrCanceled:
@@ -206,19 +368,23 @@ func (m Map) Encode(ctx context.Context, tile tegola.Tile) ([]byte, error) {
 				return
 			}
 
-			//	check if we have a layer name
-			if l.Name != "" {
-				mvtLayer.Name = l.Name
-			}
+			for j, i := range group {
+				//	check if we have a layer name
+				if m.Layers[i].Name != "" {
+					layers[j].Name = m.Layers[i].Name
+				}
 
-			//	add the layer to the slice position
-			mvtLayers[i] = mvtLayer
-		}(i, layer)
+				//	add the layer to the slice position
+				mvtLayers[i] = layers[j]
+			}
+		}(group)
 	}
 
 	//	wait for the waitgroup to finish
 	wg.Wait()
 
+	fetchTime := time.Since(fetchStart)
+
 	//	stop processing if the context has an error. this check is necessary
 	//	otherwise the server continues processing even if the request was canceled
 	//	as the waitgroup was not notified of the cancel
@@ -229,12 +395,48 @@ func (m Map) Encode(ctx context.Context, tile tegola.Tile) ([]byte, error) {
 	//	add layers to our tile
 	mvtTile.AddLayers(mvtLayers...)
 
+	encodeStart := time.Now()
+
 	//	generate our tile
 	vtile, err := mvtTile.VTile(ctx, tile.BoundingBox())
 	if err != nil {
 		return nil, err
 	}
 
-	//	encode the tile
-	return proto.Marshal(vtile)
+	if statsLayer != nil {
+		stats := debug.TileStats{
+			FetchTime:  fetchTime,
+			EncodeTime: time.Since(encodeStart),
+		}
+
+		//	the encoded layers keyed by name
+		encoded := map[string]*vectorTile.Tile_Layer{}
+		for _, vtl := range vtile.Layers {
+			encoded[vtl.GetName()] = vtl
+		}
+
+		for _, l := range mvtTile.Layers() {
+			ls := debug.LayerStats{
+				Name:    l.Name,
+				Fetched: len(l.Features()),
+			}
+			if l.Raw != nil {
+				//	raw layers are encoded by the provider
+				ls.Bytes = len(l.Raw)
+			} else if vtl, ok := encoded[l.Name]; ok {
+				ls.Features = len(vtl.Features)
+				ls.Bytes = proto.Size(vtl)
+			}
+			stats.Layers = append(stats.Layers, ls)
+		}
+
+		vtl, err := statsLayer.encode(debug.ContextWithTileStats(ctx, stats), tile)
+		if err != nil {
+			return nil, err
+		}
+		vtile.Layers = append(vtile.Layers, vtl)
+	}
+
+	//	encode the tile, with the layers encoded by the providers (i.e. PostGIS ST_AsMVT)
+	return mvtTile.EncodeVTile(vtile)
 }
@@ -1,10 +1,17 @@
 package atlas_test
 
 import (
+	"context"
+	"net/url"
 	"reflect"
+	"sort"
+	"sync"
 	"testing"
 
+	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/atlas"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/provider/debug"
 )
 
 func TestMapEnableLayersByZoom(t *testing.T) {
@@ -145,3 +152,202 @@ func TestMapEnableLayersByName(t *testing.T) {
 		}
 	}
 }
+
+//	testParamsProvider is a provider with layers accepting request parameters
+type testParamsProvider struct {
+	testMVTProvider
+	params map[string][]string
+}
+
+func (tp *testParamsProvider) LayerParams(layerName string) []string {
+	return tp.params[layerName]
+}
+
+func TestMapParams(t *testing.T) {
+	provider := &testParamsProvider{
+		params: map[string][]string{
+			"airspace": {"class", "after"},
+			"airports": {"class"},
+		},
+	}
+
+	m := atlas.Map{
+		Layers: []atlas.Layer{
+			{Name: "airspace", ProviderLayerName: "airspace", Provider: provider},
+			{Name: "airports", ProviderLayerName: "airports", Provider: provider},
+			{Name: "land", ProviderLayerName: "land", Provider: &testMVTProvider{}},
+		},
+	}
+
+	testcases := []struct {
+		query    string
+		expected mvt.Params
+	}{
+		{
+			query:    "",
+			expected: mvt.Params{},
+		},
+		{
+			query:    "class=B&after=2026-01-01&debug=true&height=10",
+			expected: mvt.Params{"class": "B", "after": "2026-01-01"},
+		},
+		{
+			query:    "class=",
+			expected: mvt.Params{"class": ""},
+		},
+	}
+
+	for i, tc := range testcases {
+		query, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output := m.Params(query); !reflect.DeepEqual(output, tc.expected) {
+			t.Errorf("testcase (%v) failed. expected (%v) got (%v)", i, tc.expected, output)
+		}
+	}
+}
+
+func TestMapEncodeDebugLayers(t *testing.T) {
+	m := atlas.NewWGS84Map("test").EnableDebugLayers()
+
+	data, err := m.Encode(context.Background(), tegola.Tile{Z: 2, X: 1, Y: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tile, err := mvt.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers := map[string]mvt.Layer{}
+	for _, l := range tile.Layers() {
+		layers[l.Name] = l
+	}
+
+	for _, name := range debug.Layers {
+		l, ok := layers[name]
+		if !ok {
+			t.Errorf("expected layer (%v) to be encoded", name)
+			continue
+		}
+		if len(l.Features()) != 1 {
+			t.Errorf("layer (%v), expected 1 feature got %v", name, len(l.Features()))
+		}
+	}
+
+	//	the stats layer reports the stats of the tile's other layers
+	statsLayer := layers[debug.LayerDebugTileStats]
+	stats := statsLayer.Features()[0].Tags
+	for _, key := range []string{"fetch_ms", "encode_ms", "bytes", "debug-tile-grid.features", "debug-tile-grid.fetched", "debug-tile-grid.bytes"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("expected stats tag (%v), got %v", key, stats)
+		}
+	}
+	if _, ok := stats["debug-tile-stats.bytes"]; ok {
+		t.Errorf("expected the stats layer to not report itself, got %v", stats)
+	}
+}
+
+func TestMapSetDebugGridSpacing(t *testing.T) {
+	m := atlas.NewWGS84Map("test")
+
+	//	the grid spacing encoded in the debug-tile-grid layer
+	gridSpacing := func(m atlas.Map) interface{} {
+		data, err := m.EnableDebugLayers().Encode(context.Background(), tegola.Tile{Z: 2, X: 1, Y: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tile, err := mvt.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range tile.Layers() {
+			if l.Name == debug.LayerDebugTileGrid {
+				return l.Features()[0].Tags["spacing"]
+			}
+		}
+		t.Fatalf("expected layer (%v) to be encoded", debug.LayerDebugTileGrid)
+		return nil
+	}
+
+	spaced, err := m.SetDebugGridSpacing(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := gridSpacing(spaced); s != int64(1024) {
+		t.Errorf("spacing, expected 1024 got %v", s)
+	}
+	//	the original map is not changed
+	if s := gridSpacing(m); s != int64(debug.DefaultGridSpacing) {
+		t.Errorf("spacing, expected %v got %v", debug.DefaultGridSpacing, s)
+	}
+
+	if _, err := m.SetDebugGridSpacing(-1); err == nil {
+		t.Error("expected an error for a negative spacing, got nil")
+	}
+}
+
+//	testBatchProvider records the layers fetched by each MVTLayers call
+type testBatchProvider struct {
+	testMVTProvider
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (tp *testBatchProvider) MVTLayers(ctx context.Context, layerNames []string, tile tegola.Tile, tags []map[string]interface{}) ([]*mvt.Layer, error) {
+	tp.mu.Lock()
+	tp.batches = append(tp.batches, layerNames)
+	tp.mu.Unlock()
+
+	layers := make([]*mvt.Layer, len(layerNames))
+	for i := range layerNames {
+		layers[i] = &mvt.Layer{Name: layerNames[i]}
+	}
+	return layers, nil
+}
+
+func TestMapEncodeBatch(t *testing.T) {
+	db, other := &testBatchProvider{}, &testBatchProvider{}
+
+	m := atlas.Map{
+		Layers: []atlas.Layer{
+			{Name: "water", ProviderLayerName: "water", Provider: db, ProviderName: "db"},
+			{Name: "land", ProviderLayerName: "land", Provider: &testMVTProvider{}},
+			{Name: "roads", ProviderLayerName: "osm_roads", Provider: db, ProviderName: "db"},
+			{Name: "parks", ProviderLayerName: "parks", Provider: db, ProviderName: "db", Disabled: true},
+			{Name: "buildings", ProviderLayerName: "buildings", Provider: other, ProviderName: "other"},
+		},
+	}
+
+	data, err := m.Encode(context.Background(), tegola.Tile{Z: 2, X: 1, Y: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//	the enabled layers of a provider are fetched together, a provider with a single layer uses MVTLayer
+	expected := [][]string{{"water", "osm_roads"}}
+	if !reflect.DeepEqual(db.batches, expected) {
+		t.Errorf("batches, expected %v got %v", expected, db.batches)
+	}
+	if len(other.batches) != 0 {
+		t.Errorf("batches, expected none got %v", other.batches)
+	}
+
+	tile, err := mvt.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, l := range tile.Layers() {
+		names = append(names, l.Name)
+	}
+	sort.Strings(names)
+	if expected := []string{"buildings", "land", "roads", "water"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("layers, expected %v got %v", expected, names)
+	}
+}
@@ -0,0 +1,118 @@
+package atlas
+
+import (
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mapbox/tilejson"
+)
+
+//	TileJSON returns the details of the map according to the tileJSON spec (https://github.com/mapbox/tilejson-spec/tree/master/2.1.0).
+//	Disabled layers are skipped. The tile URLs depend on where the map is served from so the Tiles of the map and its
+//	vector layers are left for the caller to set.
+func (m Map) TileJSON() tilejson.TileJSON {
+	tileJSON := tilejson.TileJSON{
+		Attribution: &m.Attribution,
+		Bounds:      m.Bounds,
+		Center:      m.Center,
+		Format:      "pbf",
+		Name:        &m.Name,
+		Scheme:      tilejson.SchemeXYZ,
+		TileJSON:    tilejson.Version,
+		Version:     "1.0.0",
+		Grids:       make([]string, 0),
+		Data:        make([]string, 0),
+	}
+
+	for i := range m.Layers {
+		//	skip disabled layers
+		if m.Layers[i].Disabled {
+			continue
+		}
+
+		//	check if the layer already exists in our slice. this can happen if the config
+		//	is using the "name" param for a layer to override the providerLayerName
+		var skip bool
+		for j := range tileJSON.VectorLayers {
+			if tileJSON.VectorLayers[j].ID == m.Layers[i].MVTName() {
+				//	we need to use the min and max of all layers with this name
+				if tileJSON.VectorLayers[j].MinZoom > m.Layers[i].MinZoom {
+					tileJSON.VectorLayers[j].MinZoom = m.Layers[i].MinZoom
+				}
+
+				if tileJSON.VectorLayers[j].MaxZoom < m.Layers[i].MaxZoom {
+					tileJSON.VectorLayers[j].MaxZoom = m.Layers[i].MaxZoom
+				}
+
+				tileJSON.VectorLayers[j].Fields = mergeFields(tileJSON.VectorLayers[j].Fields, m.Layers[i].Fields)
+
+				skip = true
+				break
+			}
+		}
+		//	entry for layer already exists. move on
+		if skip {
+			continue
+		}
+
+		//	the first layer sets the initial min / max otherwise they default to 0/0
+		if len(tileJSON.VectorLayers) == 0 {
+			tileJSON.MinZoom = m.Layers[i].MinZoom
+			tileJSON.MaxZoom = m.Layers[i].MaxZoom
+		}
+
+		//	check if we have a min zoom lower then our current min
+		if tileJSON.MinZoom > m.Layers[i].MinZoom {
+			tileJSON.MinZoom = m.Layers[i].MinZoom
+		}
+
+		//	check if we have a max zoom higher then our current max
+		if tileJSON.MaxZoom < m.Layers[i].MaxZoom {
+			tileJSON.MaxZoom = m.Layers[i].MaxZoom
+		}
+
+		//	build our vector layer details
+		layer := tilejson.VectorLayer{
+			Version: 2,
+			Extent:  4096,
+			ID:      m.Layers[i].MVTName(),
+			Name:    m.Layers[i].MVTName(),
+			MinZoom: m.Layers[i].MinZoom,
+			MaxZoom: m.Layers[i].MaxZoom,
+			Fields:  mergeFields(nil, m.Layers[i].Fields),
+		}
+
+		switch m.Layers[i].GeomType.(type) {
+		case tegola.Point, tegola.MultiPoint:
+			layer.GeometryType = tilejson.GeomTypePoint
+		case tegola.LineString, tegola.MultiLine:
+			layer.GeometryType = tilejson.GeomTypeLine
+		case tegola.Polygon, tegola.MultiPolygon:
+			layer.GeometryType = tilejson.GeomTypePolygon
+		default:
+			layer.GeometryType = tilejson.GeomTypeUnknown
+		}
+
+		//	add our layer to our tile layer response
+		tileJSON.VectorLayers = append(tileJSON.VectorLayers, layer)
+	}
+
+	return tileJSON
+}
+
+//	mergeFields adds the attribute schema of a layer to the schema of the layers
+//	sharing its name. the first type seen for a field wins.
+func mergeFields(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+
+	for name, typ := range src {
+		if _, ok := dst[name]; !ok {
+			dst[name] = typ
+		}
+	}
+
+	return dst
+}
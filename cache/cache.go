@@ -3,9 +3,12 @@ package cache
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/airmap/tegola/mapbox/tilejson"
 )
 
 //	Interface defines a cache back end
@@ -15,6 +18,18 @@ type Interface interface {
 	Purge(key *Key) error
 }
 
+//	MetadataSetter is implemented by cache back ends storing the details of the maps along with their
+//	tiles (i.e. MBTiles). SetMetadata is called with the tileJSON of each map once the cache is set up.
+type MetadataSetter interface {
+	SetMetadata(mapName string, tileJSON tilejson.TileJSON) error
+}
+
+//	Closer is implemented by cache back ends holding resources (i.e. open files or connections)
+//	that need to be released when the cache is no longer used
+type Closer interface {
+	Close() error
+}
+
 //	ParseKey will parse a string in the format /:map/:layer/:z/:x/:y into a Key struct. The :layer value is optional
 //	ParseKey also supports other OS delimeters (i.e. Windows - "\")
 func ParseKey(str string) (*Key, error) {
@@ -97,13 +112,22 @@ func ParseKey(str string) (*Key, error) {
 type Key struct {
 	MapName   string
 	LayerName string
-	Z         int
-	X         int
-	Y         int
+	//	the URL encoded request parameters the tile was filtered with (see mvt.Params.Encode). optional
+	Params string
+	Z      int
+	X      int
+	Y      int
 }
 
+//	String returns the key as a path in the format :map/:layer/:params/:z/:x/:y. The :layer and :params
+//	values are only included when set. The params are escaped so they can be used in file names.
 func (k Key) String() string {
-	return filepath.Join(k.MapName, k.LayerName, strconv.Itoa(k.Z), strconv.Itoa(k.X), strconv.Itoa(k.Y))
+	var params string
+	if k.Params != "" {
+		params = url.QueryEscape(k.Params)
+	}
+
+	return filepath.Join(k.MapName, k.LayerName, params, strconv.Itoa(k.Z), strconv.Itoa(k.X), strconv.Itoa(k.Y))
 }
 
 // InitFunc initilize a cache given a config map.
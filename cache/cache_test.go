@@ -1,6 +1,7 @@
 package cache_test
 
 import (
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -54,3 +55,29 @@ func TestParseKey(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyString(t *testing.T) {
+	testcases := []struct {
+		key      cache.Key
+		expected string
+	}{
+		{
+			key:      cache.Key{MapName: "osm", Z: 12, X: 11, Y: 123},
+			expected: "osm/12/11/123",
+		},
+		{
+			key:      cache.Key{MapName: "osm", LayerName: "buildings", Z: 12, X: 11, Y: 123},
+			expected: "osm/buildings/12/11/123",
+		},
+		{
+			key:      cache.Key{MapName: "airspace", Params: "after=2026-01-01&class=B", Z: 8, X: 40, Y: 90},
+			expected: "airspace/after%3D2026-01-01%26class%3DB/8/40/90",
+		},
+	}
+
+	for i, tc := range testcases {
+		if output := tc.key.String(); output != filepath.FromSlash(tc.expected) {
+			t.Errorf("testcase (%v) failed. expected (%v) got (%v)", i, tc.expected, output)
+		}
+	}
+}
@@ -89,6 +89,7 @@ func (fc *Filecache) Get(key *cache.Key) ([]byte, bool, error) {
 
 		return nil, false, err
 	}
+	defer f.Close()
 
 	val, err := ioutil.ReadAll(f)
 	if err != nil {
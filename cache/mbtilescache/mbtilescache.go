@@ -0,0 +1,500 @@
+//	Package mbtilescache provides a cache storing tiles in MBTiles files (https://github.com/mapbox/mbtiles-spec),
+//	so the seeded file of a map is a tileset which can be shipped and served without tegola's data providers.
+package mbtilescache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/mapbox/tilejson"
+	"github.com/airmap/tegola/util/dict"
+)
+
+var (
+	ErrMissingPath = errors.New("mbtilescache: one of the params 'basepath' or 'filepath' is required")
+)
+
+const CacheType = "mbtiles"
+
+const (
+	ConfigKeyBasepath = "basepath"
+	ConfigKeyFilepath = "filepath"
+	ConfigKeyMaxZoom  = "max_zoom"
+)
+
+//	the extension of the per map files
+const fileExt = ".mbtiles"
+
+//	statements are the SQL statements of a MBTiles file. The tile_row is in the TMS scheme.
+type statements struct {
+	schema    string
+	getTile   string
+	setTile   string
+	purgeTile string
+}
+
+//	tilesetSQL is the SQL of the per map files, which follow the MBTiles spec
+var tilesetSQL = statements{
+	schema: `
+		CREATE TABLE IF NOT EXISTS metadata (name TEXT, value TEXT);
+		CREATE UNIQUE INDEX IF NOT EXISTS metadata_index ON metadata (name);
+		CREATE TABLE IF NOT EXISTS tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB);
+		CREATE UNIQUE INDEX IF NOT EXISTS tile_index ON tiles (zoom_level, tile_column, tile_row);`,
+	getTile:   `SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?;`,
+	setTile:   `INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?);`,
+	purgeTile: `DELETE FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?;`,
+}
+
+//	sharedSQL is the SQL of a file shared by several maps. The tiles are keyed by map name too, so the file
+//	is not a plain MBTiles tileset. The map name is last in the index so readers looking up tiles by
+//	zoom_level, tile_column and tile_row can use it.
+var sharedSQL = statements{
+	schema: `
+		CREATE TABLE IF NOT EXISTS metadata (name TEXT, value TEXT);
+		CREATE UNIQUE INDEX IF NOT EXISTS metadata_index ON metadata (name);
+		CREATE TABLE IF NOT EXISTS tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB, map_name TEXT);
+		CREATE UNIQUE INDEX IF NOT EXISTS tile_index ON tiles (zoom_level, tile_column, tile_row, map_name);`,
+	getTile:   `SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ? AND map_name = ?;`,
+	setTile:   `INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, map_name, tile_data) VALUES (?, ?, ?, ?, ?);`,
+	purgeTile: `DELETE FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ? AND map_name = ?;`,
+}
+
+const (
+	deleteMetadataSQL = `DELETE FROM metadata;`
+	insertMetadataSQL = `INSERT INTO metadata (name, value) VALUES (?, ?);`
+)
+
+func init() {
+	cache.Register(CacheType, New)
+}
+
+//	New instantiates a MBTilesCache. The config expects one of the following params:
+//
+//		basepath (string): a path to the directory the cache writes a MBTiles file per map to, named after the map (i.e. osm.mbtiles)
+//		filepath (string): a path to a single file the tiles of all the maps are written to. The tiles are keyed by
+//			map name in an extra map_name column, so the file is not a plain MBTiles tileset.
+//
+//	and optionally:
+//
+//		max_zoom (int): max zoom to use the cache. beyond this zoom cache Set() calls will be ignored
+//
+func New(config map[string]interface{}) (cache.Interface, error) {
+	var err error
+
+	mc := MBTilesCache{
+		dbs:      map[string]*sql.DB{},
+		metadata: map[string]map[string]tilejson.TileJSON{},
+	}
+
+	//	parse the config
+	c := dict.M(config)
+
+	basepath := ""
+	if mc.Basepath, err = c.String(ConfigKeyBasepath, &basepath); err != nil {
+		return nil, err
+	}
+	fpath := ""
+	if mc.Filepath, err = c.String(ConfigKeyFilepath, &fpath); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case mc.Basepath == "" && mc.Filepath == "":
+		return nil, ErrMissingPath
+	case mc.Basepath != "" && mc.Filepath != "":
+		return nil, fmt.Errorf("mbtilescache: only one of the params '%v' or '%v' can be set", ConfigKeyBasepath, ConfigKeyFilepath)
+	}
+
+	if _, ok := c[ConfigKeyMaxZoom]; ok {
+		//	a default is needed for Int64 to reject values of the wrong type
+		var maxZoom int64
+		if maxZoom, err = c.Int64(ConfigKeyMaxZoom, &maxZoom); err != nil {
+			return nil, err
+		}
+		if maxZoom < 0 {
+			return nil, fmt.Errorf("mbtilescache: %v (%v) can not be negative", ConfigKeyMaxZoom, maxZoom)
+		}
+		mz := uint(maxZoom)
+		mc.MaxZoom = &mz
+	}
+
+	if mc.Basepath != "" {
+		//	make our basepath if it does not exist
+		if err = os.MkdirAll(mc.Basepath, os.ModePerm); err != nil {
+			return nil, err
+		}
+		return &mc, nil
+	}
+
+	//	create the shared file up front so a bad filepath fails fast
+	if err = os.MkdirAll(filepath.Dir(mc.Filepath), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if _, err = mc.open(mc.Filepath, true); err != nil {
+		return nil, err
+	}
+
+	return &mc, nil
+}
+
+//	MBTilesCache stores the tiles of the maps in MBTiles files. Only whole map tiles are cached; the tiles of a single
+//	layer and tiles filtered by request parameters don't fit the MBTiles schema and are not cached.
+type MBTilesCache struct {
+	//	Basepath is the directory of the per map files. Set if Filepath is not.
+	Basepath string
+	//	Filepath is the file shared by all the maps. Set if Basepath is not.
+	Filepath string
+	//	MaxZoom is the max zoom of the cached tiles. Set() calls for tiles beyond it are ignored
+	MaxZoom *uint
+
+	mu sync.Mutex
+	//	the open files keyed by path
+	dbs map[string]*sql.DB
+	//	the tileJSON of the maps keyed by path and map name. the metadata of a file is made from the maps stored in it.
+	metadata map[string]map[string]tilejson.TileJSON
+}
+
+//	path returns the path of the file the map's tiles are stored in. ok is false if the key
+//	can't be stored (i.e. a layer tile or a map name which is not a plain file name).
+func (mc *MBTilesCache) path(key *cache.Key) (path string, ok bool) {
+	if key.LayerName != "" || key.Params != "" {
+		return "", false
+	}
+
+	if mc.Filepath != "" {
+		return mc.Filepath, true
+	}
+
+	//	the map name comes from the request URL. don't let it escape the basepath
+	if key.MapName == "" || key.MapName != filepath.Base(key.MapName) || key.MapName == ".." {
+		return "", false
+	}
+
+	return filepath.Join(mc.Basepath, key.MapName+fileExt), true
+}
+
+//	open returns the database of the MBTiles file, opening it if necessary. If create is false, nil is returned
+//	when the file does not exist (sqlite would otherwise create it).
+func (mc *MBTilesCache) open(path string, create bool) (*sql.DB, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if db, ok := mc.dbs[path]; ok {
+		return db, nil
+	}
+
+	if !create {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("mbtilescache: failed opening (%v): %v", path, err)
+	}
+	//	sqlite allows a single writer. serialize the writes instead of failing with "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(mc.sql().schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("mbtilescache: failed creating the tables of (%v): %v", path, err)
+	}
+
+	mc.dbs[path] = db
+
+	return db, nil
+}
+
+//	sql returns the SQL statements of the cache's files
+func (mc *MBTilesCache) sql() statements {
+	if mc.Filepath != "" {
+		return sharedSQL
+	}
+	return tilesetSQL
+}
+
+//	tileArgs returns the arguments of the SQL statements identifying the key's tile. The tile row is in the
+//	TMS scheme, which has the y axis flipped. The map name is only used to identify tiles in a shared file.
+func (mc *MBTilesCache) tileArgs(key *cache.Key) []interface{} {
+	args := []interface{}{key.Z, key.X, (1 << uint(key.Z)) - 1 - key.Y}
+	if mc.Filepath != "" {
+		args = append(args, key.MapName)
+	}
+	return args
+}
+
+func (mc *MBTilesCache) Get(key *cache.Key) ([]byte, bool, error) {
+	path, ok := mc.path(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	db, err := mc.open(path, false)
+	if err != nil || db == nil {
+		return nil, false, err
+	}
+
+	var data []byte
+	err = db.QueryRow(mc.sql().getTile, mc.tileArgs(key)...).Scan(&data)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	//	MBTiles vector tiles are gzip compressed
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	defer gz.Close()
+
+	val, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+func (mc *MBTilesCache) Set(key *cache.Key, val []byte) error {
+	//	check for maxzoom
+	if mc.MaxZoom != nil && key.Z > int(*mc.MaxZoom) {
+		return nil
+	}
+
+	path, ok := mc.path(key)
+	if !ok {
+		return nil
+	}
+
+	db, err := mc.open(path, true)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err = gz.Write(val); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(mc.sql().setTile, append(mc.tileArgs(key), buf.Bytes())...)
+	return err
+}
+
+func (mc *MBTilesCache) Purge(key *cache.Key) error {
+	path, ok := mc.path(key)
+	if !ok {
+		return nil
+	}
+
+	db, err := mc.open(path, false)
+	if err != nil || db == nil {
+		return err
+	}
+
+	_, err = db.Exec(mc.sql().purgeTile, mc.tileArgs(key)...)
+	return err
+}
+
+//	SetMetadata fills the metadata table of the map's file from the map's tileJSON (see cache.MetadataSetter).
+//	The metadata of a file shared by several maps covers all of them.
+func (mc *MBTilesCache) SetMetadata(mapName string, tileJSON tilejson.TileJSON) error {
+	path, ok := mc.path(&cache.Key{MapName: mapName})
+	if !ok {
+		return fmt.Errorf("mbtilescache: invalid map name (%v)", mapName)
+	}
+
+	db, err := mc.open(path, true)
+	if err != nil {
+		return err
+	}
+
+	mc.mu.Lock()
+	if mc.metadata[path] == nil {
+		mc.metadata[path] = map[string]tilejson.TileJSON{}
+	}
+	mc.metadata[path][mapName] = tileJSON
+	rows, err := metadataRows(mc.metadata[path])
+	mc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(deleteMetadataSQL); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range rows {
+		if _, err = tx.Exec(insertMetadataSQL, row[0], row[1]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	vectorLayer is a layer listed in the json metadata row
+type vectorLayer struct {
+	ID      string            `json:"id"`
+	MinZoom int               `json:"minzoom"`
+	MaxZoom int               `json:"maxzoom"`
+	Fields  map[string]string `json:"fields"`
+}
+
+//	metadataRows returns the name / value rows of the metadata table for the maps stored in a file.
+//	The maps are combined in name order: the bounds and zooms cover all of them, the center is the first
+//	map's and the vector layers sharing a name are merged.
+func metadataRows(maps map[string]tilejson.TileJSON) ([][2]string, error) {
+	var names []string
+	for name := range maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		attributions []string
+		bounds       [4]float64
+		center       [3]float64
+		minZoom      int
+		maxZoom      int
+		layers       []vectorLayer
+	)
+
+	for i, name := range names {
+		tj := maps[name]
+
+		if tj.Attribution != nil && *tj.Attribution != "" {
+			attributions = append(attributions, *tj.Attribution)
+		}
+
+		if i == 0 {
+			bounds, center = tj.Bounds, tj.Center
+			minZoom, maxZoom = tj.MinZoom, tj.MaxZoom
+		} else {
+			if tj.Bounds[0] < bounds[0] {
+				bounds[0] = tj.Bounds[0]
+			}
+			if tj.Bounds[1] < bounds[1] {
+				bounds[1] = tj.Bounds[1]
+			}
+			if tj.Bounds[2] > bounds[2] {
+				bounds[2] = tj.Bounds[2]
+			}
+			if tj.Bounds[3] > bounds[3] {
+				bounds[3] = tj.Bounds[3]
+			}
+			if tj.MinZoom < minZoom {
+				minZoom = tj.MinZoom
+			}
+			if tj.MaxZoom > maxZoom {
+				maxZoom = tj.MaxZoom
+			}
+		}
+
+	vectorLayers:
+		for _, vl := range tj.VectorLayers {
+			for j := range layers {
+				if layers[j].ID != vl.ID {
+					continue
+				}
+				if vl.MinZoom < layers[j].MinZoom {
+					layers[j].MinZoom = vl.MinZoom
+				}
+				if vl.MaxZoom > layers[j].MaxZoom {
+					layers[j].MaxZoom = vl.MaxZoom
+				}
+				for field, typ := range vl.Fields {
+					if _, ok := layers[j].Fields[field]; !ok {
+						layers[j].Fields[field] = typ
+					}
+				}
+				continue vectorLayers
+			}
+
+			//	fields is required by the spec
+			fields := map[string]string{}
+			for field, typ := range vl.Fields {
+				fields[field] = typ
+			}
+			layers = append(layers, vectorLayer{
+				ID:      vl.ID,
+				MinZoom: vl.MinZoom,
+				MaxZoom: vl.MaxZoom,
+				Fields:  fields,
+			})
+		}
+	}
+
+	//	the vector layers are required for vector tilesets
+	if layers == nil {
+		layers = []vectorLayer{}
+	}
+	js, err := json.Marshal(struct {
+		VectorLayers []vectorLayer `json:"vector_layers"`
+	}{layers})
+	if err != nil {
+		return nil, err
+	}
+
+	float := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	rows := [][2]string{
+		{"name", strings.Join(names, ",")},
+		{"format", "pbf"},
+		{"bounds", strings.Join([]string{float(bounds[0]), float(bounds[1]), float(bounds[2]), float(bounds[3])}, ",")},
+		{"center", strings.Join([]string{float(center[0]), float(center[1]), strconv.Itoa(int(center[2]))}, ",")},
+		{"minzoom", strconv.Itoa(minZoom)},
+		{"maxzoom", strconv.Itoa(maxZoom)},
+		{"json", string(js)},
+	}
+	if len(attributions) > 0 {
+		rows = append(rows, [2]string{"attribution", strings.Join(attributions, " ")})
+	}
+
+	return rows, nil
+}
+
+//	Close closes the MBTiles files
+func (mc *MBTilesCache) Close() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	var err error
+	for path, db := range mc.dbs {
+		if cerr := db.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(mc.dbs, path)
+	}
+
+	return err
+}
@@ -0,0 +1,443 @@
+package mbtilescache_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/cache/mbtilescache"
+	"github.com/airmap/tegola/mapbox/tilejson"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/provider/mbtiles"
+)
+
+//	the directory the tests write their files to. it's removed once each test completes
+const testDir = "testfiles/tegola-cache"
+
+//	metadata reads the metadata table of the MBTiles file
+func metadata(t *testing.T, path string) map[string]string {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT name, value FROM metadata`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	md := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			t.Fatal(err)
+		}
+		md[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return md
+}
+
+//	tileColumns reads the column names of the tiles table
+func tileColumns(t *testing.T, db *sql.DB) []string {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('tiles')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		columns = append(columns, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return columns
+}
+
+func TestNew(t *testing.T) {
+	defer os.RemoveAll(testDir)
+
+	maxZoom := uint(9)
+
+	testcases := []struct {
+		config    map[string]interface{}
+		basepath  string
+		filepath  string
+		maxZoom   *uint
+		expectErr bool
+	}{
+		{
+			config: map[string]interface{}{
+				"basepath": filepath.Join(testDir, "maps"),
+			},
+			basepath: filepath.Join(testDir, "maps"),
+		},
+		{
+			config: map[string]interface{}{
+				"filepath": filepath.Join(testDir, "shared", "tegola.mbtiles"),
+				"max_zoom": int64(9),
+			},
+			filepath: filepath.Join(testDir, "shared", "tegola.mbtiles"),
+			maxZoom:  &maxZoom,
+		},
+		{
+			config:    map[string]interface{}{},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				"basepath": testDir,
+				"filepath": filepath.Join(testDir, "tegola.mbtiles"),
+			},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				"basepath": testDir,
+				"max_zoom": "foo",
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := mbtilescache.New(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		mc := output.(*mbtilescache.MBTilesCache)
+		if mc.Basepath != tc.basepath || mc.Filepath != tc.filepath || !reflect.DeepEqual(mc.MaxZoom, tc.maxZoom) {
+			t.Errorf("testcase (%v) failed. expected (%v, %v, %v) does not match output (%v, %v, %v)", i, tc.basepath, tc.filepath, tc.maxZoom, mc.Basepath, mc.Filepath, mc.MaxZoom)
+		}
+		mc.Close()
+	}
+
+	//	the shared file is created up front
+	if _, err := os.Stat(filepath.Join(testDir, "shared", "tegola.mbtiles")); err != nil {
+		t.Errorf("expected the shared file to be created, err: %v", err)
+	}
+}
+
+func TestSetGetPurge(t *testing.T) {
+	defer os.RemoveAll(testDir)
+
+	c, err := mbtilescache.New(map[string]interface{}{
+		"basepath": testDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := c.(*mbtilescache.MBTilesCache)
+	defer mc.Close()
+
+	key := cache.Key{MapName: "osm", Z: 2, X: 1, Y: 0}
+	val := []byte("\x53\x69\x6c\x61\x73")
+
+	//	reading a map without a file does not create one
+	if _, hit, err := mc.Get(&key); hit || err != nil {
+		t.Errorf("expected a cache miss, got (%v, %v)", hit, err)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "osm.mbtiles")); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be created, err: %v", err)
+	}
+
+	if err := mc.Set(&key, val); err != nil {
+		t.Fatalf("set err: %v", err)
+	}
+
+	output, hit, err := mc.Get(&key)
+	if err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	if !hit || !reflect.DeepEqual(output, val) {
+		t.Errorf("expected a hit with (%v) got (%v, %v)", val, hit, output)
+	}
+
+	//	the tile is stored gzip compressed with the TMS tile_row
+	db, err := sql.Open("sqlite3", filepath.Join(testDir, "osm.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var data []byte
+	if err := db.QueryRow(`SELECT tile_data FROM tiles WHERE zoom_level = 2 AND tile_column = 1 AND tile_row = 3`).Scan(&data); err != nil {
+		t.Fatalf("expected the tile at tile_row 3, err: %v", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected gzip compressed tile_data, err: %v", err)
+	}
+	if stored, _ := ioutil.ReadAll(gz); !reflect.DeepEqual(stored, val) {
+		t.Errorf("expected stored (%v) got (%v)", val, stored)
+	}
+
+	//	the per map files follow the MBTiles schema
+	expectedColumns := []string{"zoom_level", "tile_column", "tile_row", "tile_data"}
+	if columns := tileColumns(t, db); !reflect.DeepEqual(columns, expectedColumns) {
+		t.Errorf("expected the tiles columns (%v) got (%v)", expectedColumns, columns)
+	}
+
+	if err := mc.Purge(&key); err != nil {
+		t.Fatalf("purge err: %v", err)
+	}
+	if _, hit, _ := mc.Get(&key); hit {
+		t.Error("expected a cache miss after purge")
+	}
+
+	//	keys which don't fit the MBTiles schema are not cached
+	uncached := []cache.Key{
+		{MapName: "osm", LayerName: "roads", Z: 2, X: 1, Y: 0},
+		{MapName: "osm", Params: "class=B", Z: 2, X: 1, Y: 0},
+		{MapName: "..", Z: 2, X: 1, Y: 0},
+		{Z: 2, X: 1, Y: 0},
+	}
+	for i := range uncached {
+		if err := mc.Set(&uncached[i], val); err != nil {
+			t.Errorf("uncached (%v) set err: %v", i, err)
+		}
+		if _, hit, _ := mc.Get(&uncached[i]); hit {
+			t.Errorf("uncached (%v) expected a cache miss", i)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(testDir, "*")); len(files) != 1 {
+		t.Errorf("expected only osm.mbtiles to be created, got %v", files)
+	}
+}
+
+func TestSharedFile(t *testing.T) {
+	defer os.RemoveAll(testDir)
+
+	fpath := filepath.Join(testDir, "tegola.mbtiles")
+	c, err := mbtilescache.New(map[string]interface{}{
+		"filepath": fpath,
+		"max_zoom": int64(5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := c.(*mbtilescache.MBTilesCache)
+	defer mc.Close()
+
+	//	the same tile of two maps
+	osm := cache.Key{MapName: "osm", Z: 1, X: 1, Y: 1}
+	airspace := cache.Key{MapName: "airspace", Z: 1, X: 1, Y: 1}
+
+	if err := mc.Set(&osm, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Set(&airspace, []byte{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[*cache.Key][]byte{&osm: {1}, &airspace: {2}} {
+		output, hit, err := mc.Get(key)
+		if err != nil || !hit || !reflect.DeepEqual(output, expected) {
+			t.Errorf("map (%v) expected a hit with (%v) got (%v, %v, %v)", key.MapName, expected, hit, output, err)
+		}
+	}
+
+	//	beyond the max zoom
+	deep := cache.Key{MapName: "osm", Z: 6}
+	if err := mc.Set(&deep, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit, _ := mc.Get(&deep); hit {
+		t.Error("expected tiles beyond the max zoom not to be cached")
+	}
+
+	//	the metadata covers both maps
+	osmName, airspaceName, attribution := "osm", "airspace", "© OpenStreetMap"
+	tileJSONs := map[string]tilejson.TileJSON{
+		"osm": {
+			Name:        &osmName,
+			Attribution: &attribution,
+			Bounds:      [4]float64{-180, -85.0511, 180, 85.0511},
+			Center:      [3]float64{-76.275329586789, 39.153492567373, 8},
+			MinZoom:     0,
+			MaxZoom:     14,
+			VectorLayers: []tilejson.VectorLayer{
+				{ID: "roads", MinZoom: 6, MaxZoom: 14, Fields: map[string]string{"class": "String"}},
+			},
+		},
+		"airspace": {
+			Name:    &airspaceName,
+			Bounds:  [4]float64{-125, 24, -66, 50},
+			MinZoom: 4,
+			MaxZoom: 18,
+			VectorLayers: []tilejson.VectorLayer{
+				{ID: "roads", MinZoom: 4, MaxZoom: 10, Fields: map[string]string{"class": "Number", "name": "String"}},
+				{ID: "airspace", MinZoom: 4, MaxZoom: 18},
+			},
+		},
+	}
+	for name, tj := range tileJSONs {
+		if err := mc.SetMetadata(name, tj); err != nil {
+			t.Fatalf("set metadata err: %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		"name":        "airspace,osm",
+		"format":      "pbf",
+		"bounds":      "-180,-85.0511,180,85.0511",
+		"center":      "0,0,0",
+		"minzoom":     "0",
+		"maxzoom":     "18",
+		"attribution": "© OpenStreetMap",
+		"json":        `{"vector_layers":[{"id":"roads","minzoom":4,"maxzoom":14,"fields":{"class":"Number","name":"String"}},{"id":"airspace","minzoom":4,"maxzoom":18,"fields":{}}]}`,
+	}
+	if md := metadata(t, fpath); !reflect.DeepEqual(md, expected) {
+		t.Errorf("expected metadata (%v) got (%v)", expected, md)
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	defer os.RemoveAll(testDir)
+
+	c, err := mbtilescache.New(map[string]interface{}{
+		"basepath": testDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := c.(*mbtilescache.MBTilesCache)
+	defer mc.Close()
+
+	name, attribution := "osm", ""
+	tj := tilejson.TileJSON{
+		Name:        &name,
+		Attribution: &attribution,
+		Bounds:      [4]float64{-180, -85.0511, 180, 85.0511},
+		Center:      [3]float64{-76.275329586789, 39.153492567373, 8},
+		MinZoom:     2,
+		MaxZoom:     14,
+		VectorLayers: []tilejson.VectorLayer{
+			{ID: "pois", MinZoom: 2, MaxZoom: 14, Fields: map[string]string{"name": "String"}},
+		},
+	}
+
+	if err := mc.SetMetadata("osm", tj); err != nil {
+		t.Fatalf("set metadata err: %v", err)
+	}
+	//	the metadata is replaced
+	if err := mc.SetMetadata("osm", tj); err != nil {
+		t.Fatalf("set metadata err: %v", err)
+	}
+
+	md := metadata(t, filepath.Join(testDir, "osm.mbtiles"))
+	expected := map[string]string{
+		"name":    "osm",
+		"format":  "pbf",
+		"bounds":  "-180,-85.0511,180,85.0511",
+		"center":  "-76.275329586789,39.153492567373,8",
+		"minzoom": "2",
+		"maxzoom": "14",
+		"json":    `{"vector_layers":[{"id":"pois","minzoom":2,"maxzoom":14,"fields":{"name":"String"}}]}`,
+	}
+	if !reflect.DeepEqual(md, expected) {
+		t.Errorf("expected metadata (%v) got (%v)", expected, md)
+	}
+
+	var meta struct {
+		VectorLayers []map[string]interface{} `json:"vector_layers"`
+	}
+	if err := json.Unmarshal([]byte(md["json"]), &meta); err != nil || len(meta.VectorLayers) != 1 {
+		t.Errorf("expected a valid json row with 1 vector layer, got (%v, %v)", meta, err)
+	}
+
+	if err := mc.SetMetadata("../osm", tj); err == nil {
+		t.Error("expected an error for an invalid map name, got nil")
+	}
+}
+
+//	TestTileset checks a seeded file can be served by the mbtiles provider
+func TestTileset(t *testing.T) {
+	defer os.RemoveAll(testDir)
+
+	c, err := mbtilescache.New(map[string]interface{}{
+		"basepath": testDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := c.(*mbtilescache.MBTilesCache)
+
+	tile := tegola.Tile{Z: 10, X: 579, Y: 395}
+
+	//	a point in the middle of the tile
+	bbox := tile.BoundingBox()
+	layer := mvt.Layer{Name: "pois"}
+	layer.AddFeatures(mvt.Feature{
+		Tags:     map[string]interface{}{"name": "Acropolis"},
+		Geometry: basic.Point{(bbox.Minx + bbox.Maxx) / 2, (bbox.Miny + bbox.Maxy) / 2},
+	})
+	var mvtTile mvt.Tile
+	if err := mvtTile.AddLayers(&layer); err != nil {
+		t.Fatal(err)
+	}
+	data, err := mvtTile.Encode(context.Background(), bbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mc.Set(&cache.Key{MapName: "athens", Z: tile.Z, X: tile.X, Y: tile.Y}, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.SetMetadata("athens", tilejson.TileJSON{
+		MinZoom:      0,
+		MaxZoom:      14,
+		VectorLayers: []tilejson.VectorLayer{{ID: "pois", MinZoom: 0, MaxZoom: 14}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := mbtiles.NewProvider(map[string]interface{}{
+		mbtiles.ConfigKeyFilePath: filepath.Join(testDir, "athens.mbtiles"),
+	})
+	if err != nil {
+		t.Fatalf("unable to create provider: %v", err)
+	}
+	defer p.(mvt.Closer).Close()
+
+	l, err := p.MVTLayer(context.Background(), "pois", tile, nil)
+	if err != nil {
+		t.Fatalf("unable to read layer: %v", err)
+	}
+	if f := l.Features(); len(f) != 1 || f[0].Tags["name"] != "Acropolis" {
+		t.Errorf("expected the Acropolis feature, got %v", f)
+	}
+}
@@ -0,0 +1,247 @@
+package memorycache
+
+import (
+	"container/list"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/util/dict"
+)
+
+var (
+	ErrNoLimit = errors.New("memorycache: max_bytes and max_entries can not both be 0")
+)
+
+const CacheType = "memory"
+
+const (
+	ConfigKeyMaxBytes   = "max_bytes"
+	ConfigKeyMaxEntries = "max_entries"
+	ConfigKeyTTL        = "ttl"
+)
+
+const (
+	//	64 MB
+	DefaultMaxBytes = 64 << 20
+)
+
+//	counters are the hit, miss and eviction counters of a memory cache
+type counters struct {
+	hits      expvar.Int
+	misses    expvar.Int
+	evictions expvar.Int
+	entries   expvar.Int
+	bytes     expvar.Int
+}
+
+//	totals are the counters summed over all the memory caches. they are published
+//	with the expvar package (i.e. /debug/vars)
+var totals counters
+
+func init() {
+	cache.Register(CacheType, New)
+
+	metrics := expvar.NewMap("memory_cache")
+	metrics.Set("hits", &totals.hits)
+	metrics.Set("misses", &totals.misses)
+	metrics.Set("evictions", &totals.evictions)
+	metrics.Set("entries", &totals.entries)
+	metrics.Set("bytes", &totals.bytes)
+}
+
+//	New instantiates a MemoryCache. The config expects the following params:
+//
+//		max_bytes (int): the max size, in bytes, of the cached tiles. the least recently used tiles are evicted beyond it. defaults to 64 MB. 0 is no limit
+//		max_entries (int): the max number of cached tiles. the least recently used tiles are evicted beyond it. defaults to 0, no limit
+//		ttl (int): the number of seconds a tile is cached for. defaults to 0, until it's evicted
+//
+func New(config map[string]interface{}) (cache.Interface, error) {
+	var err error
+
+	//	parse the config
+	c := dict.M(config)
+
+	maxBytes := int64(DefaultMaxBytes)
+	if maxBytes, err = c.Int64(ConfigKeyMaxBytes, &maxBytes); err != nil {
+		return nil, err
+	}
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("memorycache: %v (%v) can not be negative", ConfigKeyMaxBytes, maxBytes)
+	}
+
+	var maxEntries int64
+	if maxEntries, err = c.Int64(ConfigKeyMaxEntries, &maxEntries); err != nil {
+		return nil, err
+	}
+	if maxEntries < 0 {
+		return nil, fmt.Errorf("memorycache: %v (%v) can not be negative", ConfigKeyMaxEntries, maxEntries)
+	}
+
+	//	an unbounded cache would grow until the process runs out of memory
+	if maxBytes == 0 && maxEntries == 0 {
+		return nil, ErrNoLimit
+	}
+
+	var ttl int64
+	if ttl, err = c.Int64(ConfigKeyTTL, &ttl); err != nil {
+		return nil, err
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("memorycache: %v (%v) can not be negative", ConfigKeyTTL, ttl)
+	}
+
+	mc := &MemoryCache{
+		MaxBytes:   maxBytes,
+		MaxEntries: int(maxEntries),
+		TTL:        time.Duration(ttl) * time.Second,
+	}
+
+	return mc, nil
+}
+
+//	MemoryCache keeps tiles in memory, evicting the least recently used tiles once its limits are reached.
+//	It's safe for concurrent use.
+type MemoryCache struct {
+	//	MaxBytes is the max size of the cached tiles. 0 is no limit
+	MaxBytes int64
+	//	MaxEntries is the max number of cached tiles. 0 is no limit
+	MaxEntries int
+	//	TTL is how long a tile is cached for. 0 caches the tile until it's evicted
+	TTL time.Duration
+
+	mu sync.Mutex
+	//	the cached tiles, the most recently used at the front
+	lru *list.List
+	//	the elements of lru keyed by the cache key
+	entries map[string]*list.Element
+	//	the size of the cached tiles
+	size int64
+
+	metrics counters
+}
+
+//	entry is a cached tile
+type entry struct {
+	key string
+	val []byte
+	//	zero if the tile does not expire
+	expires time.Time
+}
+
+//	Stats are the counters of a MemoryCache
+type Stats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int64
+	Bytes     int64
+}
+
+//	Stats returns the cache's counters
+func (mc *MemoryCache) Stats() Stats {
+	return Stats{
+		Hits:      mc.metrics.hits.Value(),
+		Misses:    mc.metrics.misses.Value(),
+		Evictions: mc.metrics.evictions.Value(),
+		Entries:   mc.metrics.entries.Value(),
+		Bytes:     mc.metrics.bytes.Value(),
+	}
+}
+
+//	Get returns the cached tile and marks it as the most recently used. expired tiles are misses.
+//	the returned bytes are shared with the cache and must not be modified.
+func (mc *MemoryCache) Get(key *cache.Key) ([]byte, bool, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	el, ok := mc.entries[key.String()]
+	if !ok {
+		count(&mc.metrics.misses, &totals.misses, 1)
+		return nil, false, nil
+	}
+
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		mc.remove(el)
+		count(&mc.metrics.misses, &totals.misses, 1)
+		return nil, false, nil
+	}
+
+	mc.lru.MoveToFront(el)
+	count(&mc.metrics.hits, &totals.hits, 1)
+
+	return e.val, true, nil
+}
+
+//	Set caches a copy of the tile, evicting the least recently used tiles to stay within the limits.
+//	tiles larger than MaxBytes are not cached.
+func (mc *MemoryCache) Set(key *cache.Key, val []byte) error {
+	if mc.MaxBytes > 0 && int64(len(val)) > mc.MaxBytes {
+		return nil
+	}
+
+	//	the caller may reuse val (i.e. a bytes.Buffer)
+	e := entry{
+		key: key.String(),
+		val: append([]byte(nil), val...),
+	}
+	if mc.TTL > 0 {
+		e.expires = time.Now().Add(mc.TTL)
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.lru == nil {
+		mc.lru = list.New()
+		mc.entries = map[string]*list.Element{}
+	}
+
+	//	replace an existing tile
+	if el, ok := mc.entries[e.key]; ok {
+		mc.remove(el)
+	}
+
+	mc.entries[e.key] = mc.lru.PushFront(&e)
+	mc.size += int64(len(e.val))
+	count(&mc.metrics.entries, &totals.entries, 1)
+	count(&mc.metrics.bytes, &totals.bytes, int64(len(e.val)))
+
+	//	evict the least recently used tiles
+	for (mc.MaxBytes > 0 && mc.size > mc.MaxBytes) || (mc.MaxEntries > 0 && mc.lru.Len() > mc.MaxEntries) {
+		mc.remove(mc.lru.Back())
+		count(&mc.metrics.evictions, &totals.evictions, 1)
+	}
+
+	return nil
+}
+
+func (mc *MemoryCache) Purge(key *cache.Key) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if el, ok := mc.entries[key.String()]; ok {
+		mc.remove(el)
+	}
+
+	return nil
+}
+
+//	count adds delta to a counter of the cache and to its total over all the memory caches
+func count(c, total *expvar.Int, delta int64) {
+	c.Add(delta)
+	total.Add(delta)
+}
+
+//	remove removes the element from the cache. mc.mu must be held.
+func (mc *MemoryCache) remove(el *list.Element) {
+	e := mc.lru.Remove(el).(*entry)
+	delete(mc.entries, e.key)
+	mc.size -= int64(len(e.val))
+	count(&mc.metrics.entries, &totals.entries, -1)
+	count(&mc.metrics.bytes, &totals.bytes, -int64(len(e.val)))
+}
@@ -0,0 +1,292 @@
+package memorycache_test
+
+import (
+	"expvar"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/cache/memorycache"
+)
+
+func TestNew(t *testing.T) {
+	testcases := []struct {
+		config     map[string]interface{}
+		maxBytes   int64
+		maxEntries int
+		ttl        time.Duration
+		err        error
+	}{
+		{
+			config:   map[string]interface{}{},
+			maxBytes: memorycache.DefaultMaxBytes,
+		},
+		{
+			config: map[string]interface{}{
+				"max_bytes":   int64(1024),
+				"max_entries": int64(10),
+				"ttl":         int64(60),
+			},
+			maxBytes:   1024,
+			maxEntries: 10,
+			ttl:        time.Minute,
+		},
+		{
+			config: map[string]interface{}{
+				"max_bytes":   int64(0),
+				"max_entries": int64(10),
+			},
+			maxEntries: 10,
+		},
+		{
+			config: map[string]interface{}{
+				"max_bytes": int64(0),
+			},
+			err: memorycache.ErrNoLimit,
+		},
+		{
+			config: map[string]interface{}{
+				"max_entries": int64(-1),
+			},
+			err: fmt.Errorf("memorycache: max_entries (-1) can not be negative"),
+		},
+		{
+			config: map[string]interface{}{
+				"ttl": "foo",
+			},
+			err: fmt.Errorf("ttl value needs to be of type int64. Value is of type string"),
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := memorycache.New(tc.config)
+		if tc.err != nil {
+			if err == nil || err.Error() != tc.err.Error() {
+				t.Errorf("testcase (%v) failed. expected err (%v) got (%v)", i, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		mc := output.(*memorycache.MemoryCache)
+		if mc.MaxBytes != tc.maxBytes || mc.MaxEntries != tc.maxEntries || mc.TTL != tc.ttl {
+			t.Errorf("testcase (%v) failed. expected (%v, %v, %v) does not match output (%v, %v, %v)", i, tc.maxBytes, tc.maxEntries, tc.ttl, mc.MaxBytes, mc.MaxEntries, mc.TTL)
+		}
+	}
+}
+
+func TestSetGetPurge(t *testing.T) {
+	mc, err := memorycache.New(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := cache.Key{MapName: "osm", Z: 0, X: 1, Y: 2}
+	val := []byte("\x53\x69\x6c\x61\x73")
+
+	if err := mc.Set(&key, val); err != nil {
+		t.Fatalf("set err: %v", err)
+	}
+	//	the cache keeps a copy
+	val[0] = 0
+
+	output, hit, err := mc.Get(&key)
+	if err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	if !hit {
+		t.Fatal("expected a cache hit")
+	}
+	if !reflect.DeepEqual(output, []byte("\x53\x69\x6c\x61\x73")) {
+		t.Errorf("expected (%v) got (%v)", []byte("\x53\x69\x6c\x61\x73"), output)
+	}
+
+	//	keys with other params are other tiles
+	paramsKey := key
+	paramsKey.Params = "class=B"
+	if _, hit, _ := mc.Get(&paramsKey); hit {
+		t.Error("expected a cache miss for a key with params")
+	}
+
+	if err := mc.Purge(&key); err != nil {
+		t.Fatalf("purge err: %v", err)
+	}
+	if _, hit, _ := mc.Get(&key); hit {
+		t.Error("expected a cache miss after purge")
+	}
+
+	expected := memorycache.Stats{Hits: 1, Misses: 2}
+	if stats := mc.(*memorycache.MemoryCache).Stats(); stats != expected {
+		t.Errorf("expected stats (%+v) got (%+v)", expected, stats)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	testcases := []struct {
+		config map[string]interface{}
+		//	the sizes of the tiles set with the keys 0/0/0, 0/0/1 ...
+		sizes []int
+		//	the Y of the keys still cached
+		cached []int
+		stats  memorycache.Stats
+	}{
+		{
+			config: map[string]interface{}{"max_entries": int64(2)},
+			sizes:  []int{10, 10, 10},
+			cached: []int{1, 2},
+			stats:  memorycache.Stats{Evictions: 1, Entries: 2, Bytes: 20},
+		},
+		{
+			config: map[string]interface{}{"max_bytes": int64(25)},
+			sizes:  []int{10, 10, 10},
+			cached: []int{1, 2},
+			stats:  memorycache.Stats{Evictions: 1, Entries: 2, Bytes: 20},
+		},
+		{
+			config: map[string]interface{}{"max_bytes": int64(25)},
+			sizes:  []int{10, 10, 20},
+			cached: []int{2},
+			stats:  memorycache.Stats{Evictions: 2, Entries: 1, Bytes: 20},
+		},
+		{
+			//	tiles larger than max_bytes are not cached
+			config: map[string]interface{}{"max_bytes": int64(25)},
+			sizes:  []int{10, 30},
+			cached: []int{0},
+			stats:  memorycache.Stats{Entries: 1, Bytes: 10},
+		},
+	}
+
+	for i, tc := range testcases {
+		mc, err := memorycache.New(tc.config)
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		for y, size := range tc.sizes {
+			if err := mc.Set(&cache.Key{Y: y}, make([]byte, size)); err != nil {
+				t.Errorf("testcase (%v) failed. set err: %v", i, err)
+			}
+		}
+
+		//	check the stats before the Get calls count as hits and misses
+		if stats := mc.(*memorycache.MemoryCache).Stats(); stats != tc.stats {
+			t.Errorf("testcase (%v) failed. expected stats (%+v) got (%+v)", i, tc.stats, stats)
+		}
+
+		var cached []int
+		for y := range tc.sizes {
+			if _, hit, _ := mc.Get(&cache.Key{Y: y}); hit {
+				cached = append(cached, y)
+			}
+		}
+		if !reflect.DeepEqual(cached, tc.cached) {
+			t.Errorf("testcase (%v) failed. expected cached (%v) got (%v)", i, tc.cached, cached)
+		}
+	}
+
+	//	reading a tile makes it the most recently used
+	mc, err := memorycache.New(map[string]interface{}{"max_entries": int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.Set(&cache.Key{Y: 0}, []byte{0})
+	mc.Set(&cache.Key{Y: 1}, []byte{1})
+	mc.Get(&cache.Key{Y: 0})
+	mc.Set(&cache.Key{Y: 2}, []byte{2})
+
+	if _, hit, _ := mc.Get(&cache.Key{Y: 0}); !hit {
+		t.Error("expected the recently read tile to be cached")
+	}
+	if _, hit, _ := mc.Get(&cache.Key{Y: 1}); hit {
+		t.Error("expected the least recently used tile to be evicted")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	mc := &memorycache.MemoryCache{
+		MaxEntries: 10,
+		TTL:        20 * time.Millisecond,
+	}
+
+	key := cache.Key{Z: 1}
+	if err := mc.Set(&key, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit, _ := mc.Get(&key); !hit {
+		t.Error("expected a cache hit before the ttl")
+	}
+
+	time.Sleep(mc.TTL)
+	if _, hit, _ := mc.Get(&key); hit {
+		t.Error("expected a cache miss after the ttl")
+	}
+	if stats := mc.Stats(); stats.Entries != 0 {
+		t.Errorf("expected the expired tile to be removed, got %v entries", stats.Entries)
+	}
+}
+
+//	the published metrics are the sum of the counters of all the memory caches
+func TestMetrics(t *testing.T) {
+	published := func(name string) int64 {
+		return expvar.Get("memory_cache").(*expvar.Map).Get(name).(*expvar.Int).Value()
+	}
+	hits, entries := published("hits"), published("entries")
+
+	for i := 0; i < 2; i++ {
+		mc, err := memorycache.New(map[string]interface{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key := cache.Key{Z: 1}
+		if err := mc.Set(&key, []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, hit, _ := mc.Get(&key); !hit {
+			t.Fatal("expected a cache hit")
+		}
+	}
+
+	if got := published("hits") - hits; got != 2 {
+		t.Errorf("hits, expected 2 got %v", got)
+	}
+	if got := published("entries") - entries; got != 2 {
+		t.Errorf("entries, expected 2 got %v", got)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	mc, err := memorycache.New(map[string]interface{}{"max_entries": int64(50)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := cache.Key{X: i, Y: j}
+				mc.Set(&key, []byte{byte(j)})
+				mc.Get(&key)
+				if j%3 == 0 {
+					mc.Purge(&key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := mc.(*memorycache.MemoryCache).Stats(); stats.Entries > 50 || stats.Entries != stats.Bytes {
+		t.Errorf("expected at most 50 one byte entries, got %+v", stats)
+	}
+}
@@ -0,0 +1,202 @@
+package rediscache
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/util/dict"
+)
+
+const CacheType = "redis"
+
+const (
+	ConfigKeyAddress   = "address"
+	ConfigKeyPassword  = "password"
+	ConfigKeyDB        = "db"
+	ConfigKeyKeyPrefix = "key_prefix"
+	ConfigKeyTTL       = "ttl"
+	ConfigKeyMapTTLs   = "map_ttls"
+	ConfigKeyMaxZoom   = "max_zoom"
+	ConfigKeyPoolSize  = "pool_size"
+)
+
+const (
+	DefaultAddress = "127.0.0.1:6379"
+)
+
+func init() {
+	cache.Register(CacheType, New)
+}
+
+//	New instantiates a RedisCache and checks the server can be reached. The config expects the following params:
+//
+//		address (string): the host:port of the redis server. defaults to 127.0.0.1:6379
+//		password (string): the password of the redis server. defaults to no password
+//		db (int): the redis database to use. defaults to 0
+//		key_prefix (string): a prefix added to the keys of the cached tiles. helpful so a server can be shared by several caches
+//		ttl (int): the number of seconds a tile is cached for. defaults to 0, no expiry
+//		map_ttls (map[string]int): the ttl of the tiles of a map, keyed by map name. overrides ttl
+//		max_zoom (int): max zoom to use the cache. beyond this zoom cache Set() calls will be ignored
+//		pool_size (int): the max number of connections to the redis server. defaults to 10 per CPU
+//
+func New(config map[string]interface{}) (cache.Interface, error) {
+	var err error
+
+	rc := RedisCache{
+		MapTTLs: map[string]time.Duration{},
+	}
+
+	//	parse the config
+	c := dict.M(config)
+
+	address := DefaultAddress
+	if address, err = c.String(ConfigKeyAddress, &address); err != nil {
+		return nil, err
+	}
+
+	password := ""
+	if password, err = c.String(ConfigKeyPassword, &password); err != nil {
+		return nil, err
+	}
+
+	var db int64
+	if db, err = c.Int64(ConfigKeyDB, &db); err != nil {
+		return nil, err
+	}
+
+	var poolSize int64
+	if poolSize, err = c.Int64(ConfigKeyPoolSize, &poolSize); err != nil {
+		return nil, err
+	}
+	if poolSize < 0 {
+		return nil, fmt.Errorf("rediscache: %v (%v) can not be negative", ConfigKeyPoolSize, poolSize)
+	}
+
+	keyPrefix := ""
+	if rc.KeyPrefix, err = c.String(ConfigKeyKeyPrefix, &keyPrefix); err != nil {
+		return nil, err
+	}
+
+	var ttl int64
+	if ttl, err = c.Int64(ConfigKeyTTL, &ttl); err != nil {
+		return nil, err
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("rediscache: %v (%v) can not be negative", ConfigKeyTTL, ttl)
+	}
+	rc.TTL = time.Duration(ttl) * time.Second
+
+	if _, ok := c[ConfigKeyMapTTLs]; ok {
+		mapTTLs, err := c.Dict(ConfigKeyMapTTLs)
+		if err != nil {
+			return nil, err
+		}
+		for name := range mapTTLs {
+			//	a default is needed for Int64 to reject values of the wrong type
+			var ttl int64
+			if ttl, err = mapTTLs.Int64(name, &ttl); err != nil {
+				return nil, fmt.Errorf("rediscache: %v: %v", ConfigKeyMapTTLs, err)
+			}
+			if ttl < 0 {
+				return nil, fmt.Errorf("rediscache: %v (%v) for map (%v) can not be negative", ConfigKeyMapTTLs, ttl, name)
+			}
+			rc.MapTTLs[name] = time.Duration(ttl) * time.Second
+		}
+	}
+
+	if _, ok := c[ConfigKeyMaxZoom]; ok {
+		var maxZoom int64
+		if maxZoom, err = c.Int64(ConfigKeyMaxZoom, &maxZoom); err != nil {
+			return nil, err
+		}
+		if maxZoom < 0 {
+			return nil, fmt.Errorf("rediscache: %v (%v) can not be negative", ConfigKeyMaxZoom, maxZoom)
+		}
+		mz := uint(maxZoom)
+		rc.MaxZoom = &mz
+	}
+
+	rc.client = redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: password,
+		DB:       int(db),
+		PoolSize: int(poolSize),
+	})
+
+	//	fail fast if the server can't be reached
+	if err = rc.client.Ping().Err(); err != nil {
+		rc.client.Close()
+		return nil, fmt.Errorf("rediscache: unable to reach the redis server (%v): %v", address, err)
+	}
+
+	return &rc, nil
+}
+
+//	RedisCache caches tiles in a redis server, which can be shared by several tegola instances.
+//	The connections to the server are pooled.
+type RedisCache struct {
+	//	KeyPrefix is added to the keys of the cached tiles
+	KeyPrefix string
+	//	TTL is how long a tile is cached for. 0 is no expiry
+	TTL time.Duration
+	//	MapTTLs override the TTL of the tiles of a map, keyed by map name
+	MapTTLs map[string]time.Duration
+	//	MaxZoom is the max zoom of the cached tiles. Set() calls for tiles beyond it are ignored
+	MaxZoom *uint
+
+	client *redis.Client
+}
+
+//	key returns the redis key of the tile. the key is "/" separated on every OS (key.String() uses the OS path separator)
+func (rc *RedisCache) key(key *cache.Key) string {
+	var params string
+	if key.Params != "" {
+		params = url.QueryEscape(key.Params)
+	}
+
+	return rc.KeyPrefix + path.Join(key.MapName, key.LayerName, params, strconv.Itoa(key.Z), strconv.Itoa(key.X), strconv.Itoa(key.Y))
+}
+
+//	ttl returns the ttl of the tile
+func (rc *RedisCache) ttl(key *cache.Key) time.Duration {
+	if ttl, ok := rc.MapTTLs[key.MapName]; ok {
+		return ttl
+	}
+	return rc.TTL
+}
+
+func (rc *RedisCache) Get(key *cache.Key) ([]byte, bool, error) {
+	val, err := rc.client.Get(rc.key(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+func (rc *RedisCache) Set(key *cache.Key, val []byte) error {
+	//	check for maxzoom
+	if rc.MaxZoom != nil && key.Z > int(*rc.MaxZoom) {
+		return nil
+	}
+
+	return rc.client.Set(rc.key(key), val, rc.ttl(key)).Err()
+}
+
+func (rc *RedisCache) Purge(key *cache.Key) error {
+	return rc.client.Del(rc.key(key)).Err()
+}
+
+//	Close closes the connections to the redis server
+func (rc *RedisCache) Close() error {
+	return rc.client.Close()
+}
@@ -0,0 +1,262 @@
+package rediscache_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/cache/rediscache"
+)
+
+func TestNew(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	maxZoom := uint(9)
+
+	testcases := []struct {
+		config    map[string]interface{}
+		keyPrefix string
+		ttl       time.Duration
+		mapTTLs   map[string]time.Duration
+		maxZoom   *uint
+		err       error
+	}{
+		{
+			config: map[string]interface{}{
+				"address": s.Addr(),
+			},
+			mapTTLs: map[string]time.Duration{},
+		},
+		{
+			config: map[string]interface{}{
+				"address":    s.Addr(),
+				"key_prefix": "tegola:",
+				"ttl":        int64(3600),
+				"map_ttls": map[string]interface{}{
+					"osm": int64(60),
+				},
+				"max_zoom":  int64(9),
+				"pool_size": int64(20),
+			},
+			keyPrefix: "tegola:",
+			ttl:       time.Hour,
+			mapTTLs:   map[string]time.Duration{"osm": time.Minute},
+			maxZoom:   &maxZoom,
+		},
+		{
+			config: map[string]interface{}{
+				"address": s.Addr(),
+				"ttl":     "foo",
+			},
+			err: fmt.Errorf("ttl value needs to be of type int64. Value is of type string"),
+		},
+		{
+			config: map[string]interface{}{
+				"address": s.Addr(),
+				"map_ttls": map[string]interface{}{
+					"osm": int64(-1),
+				},
+			},
+			err: fmt.Errorf("rediscache: map_ttls (-1) for map (osm) can not be negative"),
+		},
+		{
+			config: map[string]interface{}{
+				"address": s.Addr(),
+				"map_ttls": map[string]interface{}{
+					"osm": "foo",
+				},
+			},
+			err: fmt.Errorf("rediscache: map_ttls: osm value needs to be of type int64. Value is of type string"),
+		},
+		{
+			config: map[string]interface{}{
+				"address":  s.Addr(),
+				"max_zoom": "foo",
+			},
+			err: fmt.Errorf("max_zoom value needs to be of type int64. Value is of type string"),
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := rediscache.New(tc.config)
+		if tc.err != nil {
+			if err == nil || err.Error() != tc.err.Error() {
+				t.Errorf("testcase (%v) failed. expected err (%v) got (%v)", i, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		rc := output.(*rediscache.RedisCache)
+		if rc.KeyPrefix != tc.keyPrefix || rc.TTL != tc.ttl || !reflect.DeepEqual(rc.MapTTLs, tc.mapTTLs) || !reflect.DeepEqual(rc.MaxZoom, tc.maxZoom) {
+			t.Errorf("testcase (%v) failed. expected (%v, %v, %v, %v) does not match output (%v, %v, %v, %v)", i, tc.keyPrefix, tc.ttl, tc.mapTTLs, tc.maxZoom, rc.KeyPrefix, rc.TTL, rc.MapTTLs, rc.MaxZoom)
+		}
+		rc.Close()
+	}
+
+	//	the server must be reachable
+	addr := s.Addr()
+	s.Close()
+	if _, err := rediscache.New(map[string]interface{}{"address": addr}); err == nil {
+		t.Error("expected an error for an unreachable server, got nil")
+	}
+}
+
+func TestSetGetPurge(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rc, err := rediscache.New(map[string]interface{}{
+		"address":    s.Addr(),
+		"key_prefix": "tegola:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.(*rediscache.RedisCache).Close()
+
+	key := cache.Key{MapName: "osm", LayerName: "roads", Params: "class=B", Z: 1, X: 0, Y: 1}
+	val := []byte("\x53\x69\x6c\x61\x73")
+
+	if err := rc.Set(&key, val); err != nil {
+		t.Fatalf("set err: %v", err)
+	}
+
+	//	the key is prefixed and "/" separated
+	stored, err := s.Get("tegola:osm/roads/class%3DB/1/0/1")
+	if err != nil {
+		t.Fatalf("expected the prefixed key to be set, err: %v", err)
+	}
+	if stored != string(val) {
+		t.Errorf("expected (%v) got (%v)", val, []byte(stored))
+	}
+
+	output, hit, err := rc.Get(&key)
+	if err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	if !hit || !reflect.DeepEqual(output, val) {
+		t.Errorf("expected a hit with (%v) got (%v, %v)", val, hit, output)
+	}
+
+	if err := rc.Purge(&key); err != nil {
+		t.Fatalf("purge err: %v", err)
+	}
+	if _, hit, _ := rc.Get(&key); hit {
+		t.Error("expected a cache miss after purge")
+	}
+
+	//	purging a missing tile is not an error
+	if err := rc.Purge(&key); err != nil {
+		t.Errorf("purge err: %v", err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rc, err := rediscache.New(map[string]interface{}{
+		"address": s.Addr(),
+		"ttl":     int64(3600),
+		"map_ttls": map[string]interface{}{
+			"osm":    int64(60),
+			"static": int64(0),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.(*rediscache.RedisCache).Close()
+
+	testcases := []struct {
+		key      cache.Key
+		redisKey string
+		ttl      time.Duration
+	}{
+		{
+			key:      cache.Key{MapName: "osm", Z: 1},
+			redisKey: "osm/1/0/0",
+			ttl:      time.Minute,
+		},
+		{
+			key:      cache.Key{MapName: "airspace", Z: 1},
+			redisKey: "airspace/1/0/0",
+			ttl:      time.Hour,
+		},
+		{
+			//	a ttl of 0 does not expire
+			key:      cache.Key{MapName: "static", Z: 1},
+			redisKey: "static/1/0/0",
+			ttl:      0,
+		},
+	}
+
+	for i, tc := range testcases {
+		if err := rc.Set(&tc.key, []byte{1}); err != nil {
+			t.Errorf("testcase (%v) failed. set err: %v", i, err)
+			continue
+		}
+		if ttl := s.TTL(tc.redisKey); ttl != tc.ttl {
+			t.Errorf("testcase (%v) failed. expected ttl (%v) got (%v)", i, tc.ttl, ttl)
+		}
+	}
+
+	//	expired tiles are misses
+	s.FastForward(time.Minute)
+	if _, hit, _ := rc.Get(&testcases[0].key); hit {
+		t.Error("expected a cache miss after the ttl")
+	}
+	if _, hit, _ := rc.Get(&testcases[1].key); !hit {
+		t.Error("expected a cache hit before the ttl")
+	}
+}
+
+func TestMaxZoom(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rc, err := rediscache.New(map[string]interface{}{
+		"address":  s.Addr(),
+		"max_zoom": int64(5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.(*rediscache.RedisCache).Close()
+
+	for z := 4; z <= 6; z++ {
+		key := cache.Key{MapName: "osm", Z: z}
+		if err := rc.Set(&key, []byte{1}); err != nil {
+			t.Fatalf("set err: %v", err)
+		}
+
+		_, hit, err := rc.Get(&key)
+		if err != nil {
+			t.Fatalf("get err: %v", err)
+		}
+		if expected := z <= 5; hit != expected {
+			t.Errorf("zoom (%v) expected hit (%v) got (%v)", z, expected, hit)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+//	Package tieredcache provides a cache made of other caches (i.e. memory in front of file in front of S3).
+//	Reads fall through the tiers, in order, and refill the faster tiers. Writes and purges go to all the tiers.
+package tieredcache
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/mapbox/tilejson"
+	"github.com/airmap/tegola/util/dict"
+)
+
+var (
+	ErrMissingTiers = errors.New("tieredcache: missing required param 'tiers'")
+)
+
+const CacheType = "tiered"
+
+const (
+	ConfigKeyTiers = "tiers"
+	ConfigKeyType  = "type"
+)
+
+func init() {
+	cache.Register(CacheType, New)
+}
+
+//	New instantiates a TieredCache. The config expects the following params:
+//
+//		tiers ([]map[string]interface{}): the configs of the caches making up the tiers, fastest first. each
+//			tier is built by cache.For with its own config, so the cache types must be registered.
+//			type (string): the cache type of the tier (i.e. "memory", "file" or "s3")
+//
+func New(config map[string]interface{}) (cache.Interface, error) {
+	c := dict.M(config)
+
+	tiers, ok := c[ConfigKeyTiers].([]map[string]interface{})
+	if !ok || len(tiers) == 0 {
+		return nil, ErrMissingTiers
+	}
+
+	tc := TieredCache{}
+	for i := range tiers {
+		tier, err := newTier(tiers[i])
+		if err != nil {
+			return nil, fmt.Errorf("tieredcache: tier (%v) %v", i, err)
+		}
+		tc.Tiers = append(tc.Tiers, tier)
+	}
+
+	return &tc, nil
+}
+
+//	newTier builds the cache of a tier from its config
+func newTier(config map[string]interface{}) (cache.Interface, error) {
+	cType, err := dict.M(config).String(ConfigKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.For(cType, config)
+}
+
+type TieredCache struct {
+	//	Tiers are the caches making up the tiered cache, fastest first
+	Tiers []cache.Interface
+}
+
+//	Get reads the tiers in order until one of them has the tile, then writes the tile to the
+//	tiers in front of it. A tier that errors is treated as a miss so the slower tiers are still read.
+func (tc *TieredCache) Get(key *cache.Key) ([]byte, bool, error) {
+	for i, tier := range tc.Tiers {
+		val, hit, err := tier.Get(key)
+		if err != nil {
+			log.Printf("tieredcache: error reading tier (%v) for key (%v): %v", i, key.String(), err)
+			continue
+		}
+		if !hit {
+			continue
+		}
+
+		//	refill the faster tiers
+		for j := 0; j < i; j++ {
+			if err := tc.Tiers[j].Set(key, val); err != nil {
+				log.Printf("tieredcache: error refilling tier (%v) for key (%v): %v", j, key.String(), err)
+			}
+		}
+
+		return val, true, nil
+	}
+
+	return nil, false, nil
+}
+
+//	Set writes the tile to all the tiers. All the tiers are written to even if one of them errors.
+func (tc *TieredCache) Set(key *cache.Key, val []byte) error {
+	var errs []error
+	for _, tier := range tc.Tiers {
+		if err := tier.Set(key, val); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return tierErrors("set", errs)
+}
+
+//	Purge removes the tile from all the tiers. All the tiers are purged even if one of them errors.
+func (tc *TieredCache) Purge(key *cache.Key) error {
+	var errs []error
+	for _, tier := range tc.Tiers {
+		if err := tier.Purge(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return tierErrors("purge", errs)
+}
+
+//	SetMetadata passes the map's tileJSON to the tiers implementing cache.MetadataSetter
+func (tc *TieredCache) SetMetadata(mapName string, tileJSON tilejson.TileJSON) error {
+	var errs []error
+	for _, tier := range tc.Tiers {
+		ms, ok := tier.(cache.MetadataSetter)
+		if !ok {
+			continue
+		}
+		if err := ms.SetMetadata(mapName, tileJSON); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return tierErrors("set metadata", errs)
+}
+
+//	Close closes the tiers implementing cache.Closer. All the tiers are closed even if one of them errors.
+func (tc *TieredCache) Close() error {
+	var errs []error
+	for _, tier := range tc.Tiers {
+		c, ok := tier.(cache.Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return tierErrors("close", errs)
+}
+
+//	tierErrors returns the errors of the tiers as a single error, or nil if there are none
+func tierErrors(op string, errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("tieredcache: %v: %v", op, errs[0])
+	default:
+		return fmt.Errorf("tieredcache: %v: %v (and %v more tier errors)", op, errs[0], len(errs)-1)
+	}
+}
@@ -0,0 +1,247 @@
+package tieredcache_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/cache/filecache"
+	_ "github.com/airmap/tegola/cache/mbtilescache"
+	"github.com/airmap/tegola/cache/memorycache"
+	"github.com/airmap/tegola/cache/tieredcache"
+	"github.com/airmap/tegola/mapbox/tilejson"
+)
+
+//	failingCache errors on every call
+type failingCache struct{}
+
+var errFailing = errors.New("failing cache")
+
+func (failingCache) Get(key *cache.Key) ([]byte, bool, error) { return nil, false, errFailing }
+func (failingCache) Set(key *cache.Key, val []byte) error     { return errFailing }
+func (failingCache) Purge(key *cache.Key) error               { return errFailing }
+
+func init() {
+	cache.Register("test-failing", func(map[string]interface{}) (cache.Interface, error) {
+		return failingCache{}, nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tieredcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testcases := []struct {
+		config    map[string]interface{}
+		tierTypes []string
+		expectErr bool
+	}{
+		{
+			config: map[string]interface{}{
+				"tiers": []map[string]interface{}{
+					{"type": "memory"},
+					{"type": "file", "basepath": dir},
+				},
+			},
+			tierTypes: []string{"*memorycache.MemoryCache", "*filecache.Filecache"},
+		},
+		{
+			config:    map[string]interface{}{},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				"tiers": []map[string]interface{}{},
+			},
+			expectErr: true,
+		},
+		{
+			//	missing type
+			config: map[string]interface{}{
+				"tiers": []map[string]interface{}{
+					{"basepath": dir},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			//	the tier's own config is validated
+			config: map[string]interface{}{
+				"tiers": []map[string]interface{}{
+					{"type": "memory"},
+					{"type": "file"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				"tiers": []map[string]interface{}{
+					{"type": "unknown"},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := tieredcache.New(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		var tierTypes []string
+		for _, tier := range output.(*tieredcache.TieredCache).Tiers {
+			tierTypes = append(tierTypes, reflect.TypeOf(tier).String())
+		}
+		if !reflect.DeepEqual(tierTypes, tc.tierTypes) {
+			t.Errorf("testcase (%v) failed. expected tiers (%v) got (%v)", i, tc.tierTypes, tierTypes)
+		}
+	}
+}
+
+func TestSetGetPurge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tieredcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mem := &memorycache.MemoryCache{MaxEntries: 10}
+	file := &filecache.Filecache{Basepath: dir}
+	tc := tieredcache.TieredCache{Tiers: []cache.Interface{mem, file}}
+
+	key := cache.Key{MapName: "osm", Z: 1, X: 0, Y: 1}
+	val := []byte("\x53\x69\x6c\x61\x73")
+
+	//	writes go to all the tiers
+	if err := tc.Set(&key, val); err != nil {
+		t.Fatalf("set err: %v", err)
+	}
+	for i, tier := range tc.Tiers {
+		if _, hit, _ := tier.Get(&key); !hit {
+			t.Errorf("expected tier (%v) to have the tile", i)
+		}
+	}
+
+	//	reads from the slower tiers refill the faster ones
+	if err := mem.Purge(&key); err != nil {
+		t.Fatal(err)
+	}
+	output, hit, err := tc.Get(&key)
+	if err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	if !hit || !reflect.DeepEqual(output, val) {
+		t.Errorf("expected a hit with (%v) got (%v, %v)", val, hit, output)
+	}
+	if output, hit, _ := mem.Get(&key); !hit || !reflect.DeepEqual(output, val) {
+		t.Errorf("expected the memory tier to be refilled with (%v) got (%v, %v)", val, hit, output)
+	}
+
+	//	purges reach all the tiers
+	if err := tc.Purge(&key); err != nil {
+		t.Fatalf("purge err: %v", err)
+	}
+	for i, tier := range tc.Tiers {
+		if _, hit, _ := tier.Get(&key); hit {
+			t.Errorf("expected tier (%v) to be purged", i)
+		}
+	}
+	if _, hit, _ := tc.Get(&key); hit {
+		t.Error("expected a cache miss after purge")
+	}
+}
+
+func TestFailingTier(t *testing.T) {
+	mem := &memorycache.MemoryCache{MaxEntries: 10}
+	tc, err := tieredcache.New(map[string]interface{}{
+		"tiers": []map[string]interface{}{
+			{"type": "test-failing"},
+			{"type": "memory"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.(*tieredcache.TieredCache).Tiers[1] = mem
+
+	key := cache.Key{Z: 1}
+	val := []byte{1, 2, 3}
+
+	//	the other tiers are still written to and the error is reported
+	if err := tc.Set(&key, val); err == nil {
+		t.Error("expected the failing tier's set error")
+	}
+	if _, hit, _ := mem.Get(&key); !hit {
+		t.Error("expected the memory tier to have the tile")
+	}
+
+	//	a failing tier is a miss
+	output, hit, err := tc.Get(&key)
+	if err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	if !hit || !reflect.DeepEqual(output, val) {
+		t.Errorf("expected a hit with (%v) got (%v, %v)", val, hit, output)
+	}
+
+	if err := tc.Purge(&key); err == nil {
+		t.Error("expected the failing tier's purge error")
+	}
+	if _, hit, _ := mem.Get(&key); hit {
+		t.Error("expected the memory tier to be purged")
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tieredcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := tieredcache.New(map[string]interface{}{
+		"tiers": []map[string]interface{}{
+			{"type": "memory"},
+			{"type": "mbtiles", "basepath": dir},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := c.(*tieredcache.TieredCache)
+	defer tc.Close()
+
+	//	the tiers without metadata are skipped
+	if err := tc.SetMetadata("osm", tilejson.TileJSON{MaxZoom: 14}); err != nil {
+		t.Fatalf("set metadata err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "osm.mbtiles")); err != nil {
+		t.Errorf("expected the metadata to be written to the mbtiles tier, err: %v", err)
+	}
+
+	//	errors of the tiers are returned
+	if err := tc.SetMetadata("../osm", tilejson.TileJSON{}); err == nil {
+		t.Error("expected an error, got nil")
+	}
+
+	//	the tiers with open files are closed
+	if err := tc.Close(); err != nil {
+		t.Errorf("close err: %v", err)
+	}
+}
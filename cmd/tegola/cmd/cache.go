@@ -267,6 +267,10 @@ var cacheCmd = &cobra.Command{
 
 		//	wait for the workers to complete any remaining jobs
 		wg.Wait()
+
+		//	release the providers' and the cache's connections and files
+		closeProviders(providers)
+		closeCache(atlas.GetCache())
 	},
 }
 
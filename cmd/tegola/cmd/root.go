@@ -16,8 +16,14 @@ import (
 	"github.com/airmap/tegola/config"
 	"github.com/airmap/tegola/mvt"
 	"github.com/airmap/tegola/mvt/provider"
+	_ "github.com/airmap/tegola/provider/composite"
 	_ "github.com/airmap/tegola/provider/debug"
+	_ "github.com/airmap/tegola/provider/geojson"
+	_ "github.com/airmap/tegola/provider/gpkg"
+	_ "github.com/airmap/tegola/provider/mbtiles"
 	_ "github.com/airmap/tegola/provider/postgis"
+	_ "github.com/airmap/tegola/provider/shapefile"
+	_ "github.com/airmap/tegola/provider/upstream"
 )
 
 var (
@@ -26,6 +32,8 @@ var (
 	Version = "version not set"
 	//	parsed config
 	conf config.Config
+	//	initialized providers keyed by name
+	providers map[string]mvt.Provider
 )
 
 func init() {
@@ -72,7 +80,7 @@ func initConfig() {
 	}
 
 	//	init our providers
-	providers, err := initProviders(conf.Providers)
+	providers, err = initProviders(conf.Providers)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -90,6 +98,10 @@ func initConfig() {
 		}
 		if cache != nil {
 			atlas.SetCache(cache)
+
+			if err = setCacheMetadata(cache); err != nil {
+				log.Fatal(err)
+			}
 		}
 	}
 }
@@ -110,6 +122,22 @@ func initCache(config map[string]interface{}) (cache.Interface, error) {
 	return cache.For(cType, config)
 }
 
+//	setCacheMetadata passes the tileJSON of the maps to a cache storing it with the tiles (see cache.MetadataSetter)
+func setCacheMetadata(c cache.Interface) error {
+	ms, ok := c.(cache.MetadataSetter)
+	if !ok {
+		return nil
+	}
+
+	for _, m := range atlas.AllMaps() {
+		if err := ms.SetMetadata(m.Name, m.TileJSON()); err != nil {
+			return fmt.Errorf("error setting the cache metadata of map (%v): %v", m.Name, err)
+		}
+	}
+
+	return nil
+}
+
 //	initMaps registers maps with our server
 func initMaps(maps []config.Map, providers map[string]mvt.Provider) error {
 
@@ -123,6 +151,13 @@ func initMaps(maps []config.Map, providers map[string]mvt.Provider) error {
 			newMap.Bounds = [4]float64{m.Bounds[0], m.Bounds[1], m.Bounds[2], m.Bounds[3]}
 		}
 
+		if m.DebugGridSpacing != 0 {
+			var err error
+			if newMap, err = newMap.SetDebugGridSpacing(m.DebugGridSpacing); err != nil {
+				return fmt.Errorf("map (%v) debug_grid_spacing: %v", m.Name, err)
+			}
+		}
+
 		//	iterate our layers
 		for _, l := range m.Layers {
 			//	split our provider name (provider.layer) into [provider,layer]
@@ -148,12 +183,23 @@ func initMaps(maps []config.Map, providers map[string]mvt.Provider) error {
 			//	confirm our providerLayer name is registered
 			var found bool
 			var layerGeomType tegola.Geometry
+			var layerFields map[string]string
+			var nativeLayer bool
 			for i := range layerInfos {
 				if layerInfos[i].Name() == providerLayer[1] {
 					found = true
 
 					//	read the layerGeomType
 					layerGeomType = layerInfos[i].GeomType()
+
+					//	read the attribute schema if the provider knows it
+					if lf, ok := layerInfos[i].(mvt.LayerFields); ok {
+						layerFields = lf.Fields()
+					}
+
+					if nl, ok := layerInfos[i].(mvt.NativeLayer); ok {
+						nativeLayer = nl.NativeMVT()
+					}
 				}
 			}
 			if !found {
@@ -167,17 +213,23 @@ func initMaps(maps []config.Map, providers map[string]mvt.Provider) error {
 				if !ok {
 					return fmt.Errorf("'default_tags' for 'provider_layer' (%v) should be a TOML table", l.ProviderLayer)
 				}
+				//	the features of layers encoded by the provider can't be tagged
+				if nativeLayer {
+					return fmt.Errorf("'default_tags' can not be used with 'provider_layer' (%v) as it's encoded by the provider", l.ProviderLayer)
+				}
 			}
 
 			//	add our layer to our layers slice
 			newMap.Layers = append(newMap.Layers, atlas.Layer{
 				Name:              l.Name,
 				ProviderLayerName: providerLayer[1],
+				ProviderName:      providerLayer[0],
 				MinZoom:           l.MinZoom,
 				MaxZoom:           l.MaxZoom,
 				Provider:          provider,
 				DefaultTags:       defaultTags,
 				GeomType:          layerGeomType,
+				Fields:            layerFields,
 			})
 		}
 
@@ -188,11 +240,16 @@ func initMaps(maps []config.Map, providers map[string]mvt.Provider) error {
 	return nil
 }
 
-func initProviders(providers []map[string]interface{}) (map[string]mvt.Provider, error) {
-	var err error
-
+func initProviders(providers []map[string]interface{}) (registeredProviders map[string]mvt.Provider, err error) {
 	//	holder for registered providers
-	registeredProviders := map[string]mvt.Provider{}
+	registeredProviders = map[string]mvt.Provider{}
+
+	//	release the providers that were set up if one of them fails
+	defer func() {
+		if err != nil {
+			closeProviders(registeredProviders)
+		}
+	}()
 
 	//	iterate providers
 	for _, p := range providers {
@@ -232,7 +289,46 @@ func initProviders(providers []map[string]interface{}) (map[string]mvt.Provider,
 
 		//	add the provider to our map of registered providers
 		registeredProviders[pname] = prov
+
+		//	limit the provider's concurrent calls and errors, if configured
+		if prov, err = provider.Limit(pname, prov, p); err != nil {
+			return registeredProviders, fmt.Errorf("provider (%v): %v", pname, err)
+		}
+		registeredProviders[pname] = prov
+
+		//	make the provider available to the providers defined after it (i.e. composite)
+		if err = provider.Add(pname, prov); err != nil {
+			return registeredProviders, err
+		}
 	}
 
 	return registeredProviders, err
 }
+
+//	closeProviders releases the resources (i.e. connection pools) of the providers implementing mvt.Closer
+func closeProviders(providers map[string]mvt.Provider) {
+	for name, p := range providers {
+		provider.Remove(name)
+
+		c, ok := p.(mvt.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := c.Close(); err != nil {
+			log.Printf("error closing provider (%v): %v", name, err)
+		}
+	}
+}
+
+//	closeCache releases the resources (i.e. open files) of the cache if it implements cache.Closer
+func closeCache(c cache.Interface) {
+	cl, ok := c.(cache.Closer)
+	if !ok {
+		return
+	}
+
+	if err := cl.Close(); err != nil {
+		log.Printf("error closing cache: %v", err)
+	}
+}
@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/spf13/cobra"
+	"github.com/airmap/tegola/atlas"
 	"github.com/airmap/tegola/server"
 )
 
 var (
 	serverPort      string
 	defaultHTTPPort = ":8080"
+	//	how long requests in progress have to complete when the server is shut down
+	shutdownTimeout = 30 * time.Second
 )
 
 var serverCmd = &cobra.Command{
@@ -26,8 +36,35 @@ var serverCmd = &cobra.Command{
 		//	set our server version
 		server.Version = Version
 		server.HostName = conf.Webserver.HostName
+		server.DebugVars = conf.Webserver.DebugVars
+		server.Providers = providers
+
+		//	shut down in order when interrupted or terminated (i.e. during a rolling deploy)
+		stopped := make(chan struct{})
+		go func() {
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			sig := <-sigs
+
+			log.Printf("received %v, shutting down tegola server", sig)
+
+			//	stop accepting requests and let the requests in progress complete
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Printf("error shutting down tegola server: %v", err)
+			}
+
+			//	release the providers' and the cache's connections and files
+			closeProviders(providers)
+			closeCache(atlas.GetCache())
+
+			close(stopped)
+		}()
 
 		//	start our webserver
 		server.Start(serverPort)
+
+		<-stopped
 	},
 }
@@ -58,6 +58,8 @@ type Webserver struct {
 	Port      string `toml:"port"`
 	LogFile   string `toml:"log_file"`
 	LogFormat string `toml:"log_format"`
+	//	serve the server's metrics at /debug/vars
+	DebugVars bool `toml:"debug_vars"`
 }
 
 // A Map represents a map in the Tegola Config file.
@@ -67,6 +69,9 @@ type Map struct {
 	Bounds      []float64  `toml:"bounds"`
 	Center      [3]float64 `toml:"center"`
 	Layers      []MapLayer `toml:"layers"`
+	//	the spacing, in tile coordinates, of the grid lines of the debug-tile-grid layer added
+	//	with ?debug=true. Defaults to the debug provider's spacing.
+	DebugGridSpacing int `toml:"debug_grid_spacing"`
 }
 
 type MapLayer struct {
@@ -79,8 +84,49 @@ type MapLayer struct {
 	DefaultTags   interface{} `toml:"default_tags"`
 }
 
+type ErrUndefinedSourceProvider struct {
+	CompositeProvider string
+	SourceProvider    string
+}
+
+func (e ErrUndefinedSourceProvider) Error() string {
+	return fmt.Sprintf("config: provider (%v) used by composite provider (%v) must be defined before it", e.SourceProvider, e.CompositeProvider)
+}
+
 //	checks the config for issues
 func (c *Config) Validate() error {
+	//	layers from several providers are merged with a composite provider as map layers sharing a name can't overlap.
+	//	check the composite providers' sources are defined before them so they can be set up in order
+	definedProviders := map[string]bool{}
+	for _, p := range c.Providers {
+		name, _ := p["name"].(string)
+
+		if typ, _ := p["type"].(string); typ == "composite" {
+			layers, _ := p["layers"].([]map[string]interface{})
+			for _, l := range layers {
+				sources, _ := l["sources"].([]map[string]interface{})
+				for _, src := range sources {
+					providerLayer, _ := src["provider_layer"].(string)
+
+					//	split the provider layer (syntax is provider.layer)
+					plParts := strings.Split(providerLayer, ".")
+					if len(plParts) != 2 {
+						return ErrInvalidProviderLayerName{
+							ProviderLayerName: providerLayer,
+						}
+					}
+					if !definedProviders[plParts[0]] {
+						return ErrUndefinedSourceProvider{
+							CompositeProvider: name,
+							SourceProvider:    plParts[0],
+						}
+					}
+				}
+			}
+		}
+
+		definedProviders[name] = true
+	}
 
 	//	check for map layer name / zoom collisions
 	//	map of layers to providers
@@ -499,6 +499,88 @@ func TestValidate(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			//	a composite provider merging layers of the providers defined before it
+			config: config.Config{
+				Providers: []map[string]interface{}{
+					{
+						"name": "provider1",
+						"type": "debug",
+					},
+					{
+						"name": "provider2",
+						"type": "debug",
+					},
+					{
+						"name": "composite",
+						"type": "composite",
+						"layers": []map[string]interface{}{
+							{
+								"name": "outlines",
+								"sources": []map[string]interface{}{
+									{"provider_layer": "provider1.debug-tile-outline"},
+									{"provider_layer": "provider2.debug-tile-outline"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: nil,
+		},
+		{
+			//	the composite provider is defined before one of its sources
+			config: config.Config{
+				Providers: []map[string]interface{}{
+					{
+						"name": "provider1",
+						"type": "debug",
+					},
+					{
+						"name": "composite",
+						"type": "composite",
+						"layers": []map[string]interface{}{
+							{
+								"name": "outlines",
+								"sources": []map[string]interface{}{
+									{"provider_layer": "provider1.debug-tile-outline"},
+									{"provider_layer": "provider2.debug-tile-outline"},
+								},
+							},
+						},
+					},
+					{
+						"name": "provider2",
+						"type": "debug",
+					},
+				},
+			},
+			expected: config.ErrUndefinedSourceProvider{
+				CompositeProvider: "composite",
+				SourceProvider:    "provider2",
+			},
+		},
+		{
+			config: config.Config{
+				Providers: []map[string]interface{}{
+					{
+						"name": "composite",
+						"type": "composite",
+						"layers": []map[string]interface{}{
+							{
+								"name": "outlines",
+								"sources": []map[string]interface{}{
+									{"provider_layer": "debug-tile-outline"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: config.ErrInvalidProviderLayerName{
+				ProviderLayerName: "debug-tile-outline",
+			},
+		},
 	}
 
 	for i, tc := range testcases {
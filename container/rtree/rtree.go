@@ -0,0 +1,152 @@
+//	Package rtree provides a static R-tree which is bulk loaded using the
+//	Sort-Tile-Recursive (STR) algorithm. The tree is built once from a set of
+//	bounding boxes and can then be queried concurrently.
+package rtree
+
+import (
+	"math"
+	"sort"
+)
+
+//	DefaultNodeSize is the max number of entries of a node used by New
+const DefaultNodeSize = 16
+
+//	BBox is a bounding box in the order minx, miny, maxx, maxy
+type BBox [4]float64
+
+//	Intersects reports if the two bounding boxes intersect. Touching edges are considered intersecting.
+func (b BBox) Intersects(o BBox) bool {
+	return b[0] <= o[2] && b[2] >= o[0] && b[1] <= o[3] && b[3] >= o[1]
+}
+
+//	Extend returns a bounding box that contains both bounding boxes
+func (b BBox) Extend(o BBox) BBox {
+	return BBox{
+		math.Min(b[0], o[0]),
+		math.Min(b[1], o[1]),
+		math.Max(b[2], o[2]),
+		math.Max(b[3], o[3]),
+	}
+}
+
+type node struct {
+	bbox BBox
+	//	the index of the item in the slice provided to New. only set for leaf entries.
+	item     int
+	children []node
+}
+
+//	Tree is a static R-tree. The zero value is an empty tree.
+type Tree struct {
+	root *node
+	size int
+}
+
+//	New builds a tree from the provided bounding boxes. The values returned by Search
+//	are the indexes of the bounding boxes in the provided slice.
+func New(bboxes []BBox) *Tree {
+	return NewWithNodeSize(bboxes, DefaultNodeSize)
+}
+
+//	NewWithNodeSize builds a tree where each node has at most nodeSize entries.
+func NewWithNodeSize(bboxes []BBox, nodeSize int) *Tree {
+	if nodeSize < 2 {
+		nodeSize = 2
+	}
+
+	t := Tree{size: len(bboxes)}
+	if len(bboxes) == 0 {
+		return &t
+	}
+
+	entries := make([]node, len(bboxes))
+	for i := range bboxes {
+		entries[i] = node{bbox: bboxes[i], item: i}
+	}
+
+	//	pack each level until we are left with a single node
+	for {
+		entries = pack(entries, nodeSize)
+		if len(entries) == 1 {
+			break
+		}
+	}
+
+	t.root = &entries[0]
+	return &t
+}
+
+//	pack groups the entries into parent nodes of at most nodeSize entries
+//	using the Sort-Tile-Recursive algorithm.
+func pack(entries []node, nodeSize int) []node {
+	numNodes := int(math.Ceil(float64(len(entries)) / float64(nodeSize)))
+	numSlices := int(math.Ceil(math.Sqrt(float64(numNodes))))
+	sliceSize := numSlices * nodeSize
+
+	//	sort by the x center and cut into vertical slices
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].bbox[0]+entries[i].bbox[2] < entries[j].bbox[0]+entries[j].bbox[2]
+	})
+
+	parents := make([]node, 0, numNodes)
+	for i := 0; i < len(entries); i += sliceSize {
+		slice := entries[i:min(i+sliceSize, len(entries))]
+
+		//	sort each slice by the y center and group into nodes
+		sort.Slice(slice, func(i, j int) bool {
+			return slice[i].bbox[1]+slice[i].bbox[3] < slice[j].bbox[1]+slice[j].bbox[3]
+		})
+
+		for j := 0; j < len(slice); j += nodeSize {
+			children := slice[j:min(j+nodeSize, len(slice))]
+
+			n := node{bbox: children[0].bbox, children: children}
+			for _, c := range children[1:] {
+				n.bbox = n.bbox.Extend(c.bbox)
+			}
+			parents = append(parents, n)
+		}
+	}
+
+	return parents
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+//	Len returns the number of items in the tree
+func (t *Tree) Len() int {
+	return t.size
+}
+
+//	Search calls fn with the index of each item whose bounding box intersects bbox.
+//	If fn returns false the search is stopped.
+func (t *Tree) Search(bbox BBox, fn func(item int) bool) {
+	if t.root == nil {
+		return
+	}
+	search(t.root, bbox, fn)
+}
+
+func search(n *node, bbox BBox, fn func(item int) bool) bool {
+	if !n.bbox.Intersects(bbox) {
+		return true
+	}
+
+	//	leaf entry
+	if n.children == nil {
+		return fn(n.item)
+	}
+
+	for i := range n.children {
+		if !search(&n.children[i], bbox, fn) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+package rtree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func bruteForce(bboxes []BBox, q BBox) (items []int) {
+	for i := range bboxes {
+		if bboxes[i].Intersects(q) {
+			items = append(items, i)
+		}
+	}
+	return items
+}
+
+func TestSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	randBBox := func(size float64) BBox {
+		x, y := r.Float64()*1000, r.Float64()*1000
+		return BBox{x, y, x + r.Float64()*size, y + r.Float64()*size}
+	}
+
+	testcases := []struct {
+		items    int
+		nodeSize int
+	}{
+		{items: 0, nodeSize: 16},
+		{items: 1, nodeSize: 16},
+		{items: 16, nodeSize: 16},
+		{items: 17, nodeSize: 16},
+		{items: 1000, nodeSize: 16},
+		{items: 1000, nodeSize: 4},
+		{items: 5000, nodeSize: 9},
+	}
+
+	for i, tc := range testcases {
+		bboxes := make([]BBox, tc.items)
+		for j := range bboxes {
+			bboxes[j] = randBBox(20)
+		}
+
+		tree := NewWithNodeSize(bboxes, tc.nodeSize)
+		if tree.Len() != tc.items {
+			t.Errorf("testcase (%v) failed. expected len (%v) got (%v)", i, tc.items, tree.Len())
+		}
+
+		for q := 0; q < 50; q++ {
+			query := randBBox(200)
+
+			var got []int
+			tree.Search(query, func(item int) bool {
+				got = append(got, item)
+				return true
+			})
+			sort.Ints(got)
+
+			expected := bruteForce(bboxes, query)
+			if len(got) != len(expected) {
+				t.Errorf("testcase (%v) query (%v) failed. expected (%v) items got (%v)", i, query, len(expected), len(got))
+				continue
+			}
+			for k := range expected {
+				if got[k] != expected[k] {
+					t.Errorf("testcase (%v) query (%v) failed. expected (%v) got (%v)", i, query, expected, got)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestSearchStop(t *testing.T) {
+	bboxes := []BBox{
+		{0, 0, 1, 1},
+		{0, 0, 2, 2},
+		{0, 0, 3, 3},
+	}
+
+	var count int
+	New(bboxes).Search(BBox{0, 0, 1, 1}, func(item int) bool {
+		count++
+		return false
+	})
+
+	if count != 1 {
+		t.Errorf("expected search to stop after (1) item, got (%v)", count)
+	}
+}
@@ -127,6 +127,9 @@ type VectorLayer struct {
 	// OPTIONAL. Default: null
 	// possible values include: "point", "line", "polygon", "unknown"
 	GeometryType GeomType `json:"geometry_type,omitempty"`
+	// OPTIONAL. Default: {}
+	// an object mapping the attribute names of the layer's features to their type (i.e. "String", "Number" or "Boolean")
+	Fields map[string]string `json:"fields,omitempty"`
 	// OPTIONAL. Default: 0. >= 0, <= 22.
 	// An integer specifying the minimum zoom level.
 	MinZoom int `json:"minzoom"`
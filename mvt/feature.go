@@ -133,6 +133,11 @@ func encodeZigZag(i int64) uint32 {
 	return uint32((i << 1) ^ (i >> 31))
 }
 
+// decodeZigZag reverses the ZigZag encoding of encodeZigZag.
+func decodeZigZag(u uint32) int64 {
+	return int64(int32(u>>1) ^ -int32(u&1))
+}
+
 // cursor reprsents the current position, this is needed to encode the geometry.
 // 0,0 is the origin, it which is the top-left most part of the tile.
 type cursor struct {
@@ -648,6 +653,144 @@ func encodeGeometry(ctx context.Context, geom tegola.Geometry, extent tegola.Bou
 	}
 }
 
+// decodeGeometry decodes the command stream of a vector tile feature into a geometry
+// in tile coordinates. It is the reverse of encodeGeometry.
+func decodeGeometry(gtype vectorTile.Tile_GeomType, geom []uint32) (basic.Geometry, error) {
+	var x, y int64
+	var lines []basic.Line
+
+	for i := 0; i < len(geom); {
+		cmd := Command(geom[i])
+		i++
+
+		switch cmd.ID() {
+		case cmdMoveTo, cmdLineTo:
+			if i+2*cmd.Count() > len(geom) {
+				return nil, fmt.Errorf("%v is missing parameters", cmd)
+			}
+			for j := 0; j < cmd.Count(); j++ {
+				x += decodeZigZag(geom[i])
+				y += decodeZigZag(geom[i+1])
+				i += 2
+
+				pt := basic.Point{float64(x), float64(y)}
+
+				//	every MoveTo starts a new point, line or ring
+				if cmd.ID() == cmdMoveTo {
+					lines = append(lines, basic.Line{pt})
+					continue
+				}
+				if len(lines) == 0 {
+					return nil, fmt.Errorf("%v before a MoveTo command", cmd)
+				}
+				lines[len(lines)-1] = append(lines[len(lines)-1], pt)
+			}
+		case cmdClosePath:
+			//	rings are not explicitly closed by repeating the first point
+		default:
+			return nil, fmt.Errorf("%v is not a valid command", cmd)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	switch gtype {
+	case vectorTile.Tile_POINT:
+		var mp basic.MultiPoint
+		for _, l := range lines {
+			mp = append(mp, l...)
+		}
+		if len(mp) == 1 {
+			return mp[0], nil
+		}
+		return mp, nil
+
+	case vectorTile.Tile_LINESTRING:
+		if len(lines) == 1 {
+			return lines[0], nil
+		}
+		return basic.MultiLine(lines), nil
+
+	case vectorTile.Tile_POLYGON:
+		mp := ringsToPolygons(lines)
+		switch len(mp) {
+		case 0:
+			return nil, nil
+		case 1:
+			return mp[0], nil
+		default:
+			return mp, nil
+		}
+
+	default:
+		return nil, ErrUnknownGeometryType
+	}
+}
+
+// ringsToPolygons groups rings into polygons using their winding order. A ring with the
+// same winding order as the first ring starts a new polygon, any other ring is an interior
+// ring of the current polygon. Rings with no area are dropped.
+func ringsToPolygons(rings []basic.Line) (mp basic.MultiPolygon) {
+	var exteriorSign float64
+	for _, r := range rings {
+		area := ringArea(r)
+		if area == 0 {
+			continue
+		}
+		if exteriorSign == 0 {
+			exteriorSign = area
+		}
+		if (area > 0) == (exteriorSign > 0) {
+			mp = append(mp, basic.Polygon{r})
+			continue
+		}
+		mp[len(mp)-1] = append(mp[len(mp)-1], r)
+	}
+	return mp
+}
+
+// ringArea returns twice the signed area of the ring using the surveyor's formula.
+// In tile coordinates (y pointing down) exterior rings are positive.
+func ringArea(ring basic.Line) (area float64) {
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return area
+}
+
+// featureFromVTileFeature decodes a vector tile feature. The geometry is in tile coordinates.
+func featureFromVTileFeature(keys []string, vals []interface{}, vtf *vectorTile.Tile_Feature) (f Feature, err error) {
+	if vtf.Id != nil {
+		id := *vtf.Id
+		f.ID = &id
+	}
+
+	tags := vtf.GetTags()
+	if len(tags)%2 != 0 {
+		return f, fmt.Errorf("Feature has an odd number of tags (%v)", len(tags))
+	}
+	f.Tags = make(map[string]interface{}, len(tags)/2)
+	for i := 0; i < len(tags); i += 2 {
+		kidx, vidx := int(tags[i]), int(tags[i+1])
+		if kidx >= len(keys) {
+			return f, fmt.Errorf("Feature key index (%v) out of range", kidx)
+		}
+		if vidx >= len(vals) {
+			return f, fmt.Errorf("Feature value index (%v) out of range", vidx)
+		}
+		f.Tags[keys[kidx]] = vals[vidx]
+	}
+
+	if f.Geometry, err = decodeGeometry(vtf.GetType(), vtf.GetGeometry()); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
 // keyvalMapsFromFeatures returns a key map and value map, to help with the translation
 // to mapbox tile format. In the Tile format, the Tile contains a mapping of all the unique
 // keys and values, and then each feature contains a vector map to these two. This is an
@@ -1,6 +1,7 @@
 package mvt
 
 import (
+	"reflect"
 	"testing"
 
 	"context"
@@ -234,3 +235,85 @@ func TestNormalizePoint(t *testing.T) {
 		continue
 	}
 }
+
+func TestDecodeGeometry(t *testing.T) {
+	//	test cases are from the examples of the vector tile spec
+	//	https://github.com/mapbox/vector-tile-spec/tree/master/2.1#435-example-geometry-encodings
+	testcases := []struct {
+		typ      vectorTile.Tile_GeomType
+		geo      []uint32
+		expected basic.Geometry
+		eerr     bool
+	}{
+		{ // 0
+			typ:      vectorTile.Tile_POINT,
+			geo:      []uint32{9, 50, 34},
+			expected: basic.Point{25, 17},
+		},
+		{ // 1
+			typ:      vectorTile.Tile_POINT,
+			geo:      []uint32{17, 10, 14, 3, 9},
+			expected: basic.MultiPoint{basic.Point{5, 7}, basic.Point{3, 2}},
+		},
+		{ // 2
+			typ:      vectorTile.Tile_LINESTRING,
+			geo:      []uint32{9, 4, 4, 18, 0, 16, 16, 0},
+			expected: basic.Line{basic.Point{2, 2}, basic.Point{2, 10}, basic.Point{10, 10}},
+		},
+		{ // 3
+			typ: vectorTile.Tile_LINESTRING,
+			geo: []uint32{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8},
+			expected: basic.MultiLine{
+				basic.Line{basic.Point{2, 2}, basic.Point{2, 10}, basic.Point{10, 10}},
+				basic.Line{basic.Point{1, 1}, basic.Point{3, 5}},
+			},
+		},
+		{ // 4
+			typ: vectorTile.Tile_POLYGON,
+			geo: []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15},
+			expected: basic.Polygon{
+				basic.Line{basic.Point{3, 6}, basic.Point{8, 12}, basic.Point{20, 34}},
+			},
+		},
+		{ // 5
+			typ: vectorTile.Tile_POLYGON,
+			geo: []uint32{9, 0, 0, 26, 20, 0, 0, 20, 19, 0, 15, 9, 22, 2, 26, 18, 0, 0, 18, 17, 0, 15, 9, 4, 13, 26, 0, 8, 8, 0, 0, 7, 15},
+			expected: basic.MultiPolygon{
+				basic.Polygon{
+					basic.Line{basic.Point{0, 0}, basic.Point{10, 0}, basic.Point{10, 10}, basic.Point{0, 10}},
+				},
+				basic.Polygon{
+					basic.Line{basic.Point{11, 11}, basic.Point{20, 11}, basic.Point{20, 20}, basic.Point{11, 20}},
+					basic.Line{basic.Point{13, 13}, basic.Point{13, 17}, basic.Point{17, 17}, basic.Point{17, 13}},
+				},
+			},
+		},
+		{ // 6 missing parameters
+			typ:  vectorTile.Tile_POINT,
+			geo:  []uint32{17, 10, 14, 3},
+			eerr: true,
+		},
+		{ // 7 LineTo without a MoveTo
+			typ:  vectorTile.Tile_LINESTRING,
+			geo:  []uint32{18, 0, 16, 16, 0},
+			eerr: true,
+		},
+	}
+
+	for i, tcase := range testcases {
+		got, err := decodeGeometry(tcase.typ, tcase.geo)
+		if tcase.eerr {
+			if err == nil {
+				t.Errorf("Test %v: Expected an error, got none.", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tcase.expected, got) {
+			t.Errorf("Test %v: Expected geometry %#v got %#v.", i, tcase.expected, got)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"context"
 
 	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
 	"github.com/airmap/tegola/mvt/vector_tile"
 )
 
@@ -56,6 +57,10 @@ type Layer struct {
 	DontSimplify bool
 	// MaxSimplificationZoom is the zoom level at which point simplification is turned off. if value is zero Max is set to 14. If you do not want to simplify at any level set DontSimplify to true.
 	MaxSimplificationZoom uint
+	// Raw is the layer already encoded, as a vector tile holding just the layer (i.e. by PostGIS ST_AsMVT).
+	// When set the layer's features are ignored and the raw bytes are added to the encoded tile as they are,
+	// other than the layer's name being set to Name.
+	Raw []byte
 }
 
 func valMapToVTileValue(valMap []interface{}) (vt []*vectorTile.Tile_Value) {
@@ -65,6 +70,112 @@ func valMapToVTileValue(valMap []interface{}) (vt []*vectorTile.Tile_Value) {
 	return vt
 }
 
+// vectorTileValueToValue returns the go value of a vector tile value. nil is returned
+// if the value is not set.
+func vectorTileValueToValue(tv *vectorTile.Tile_Value) interface{} {
+	switch {
+	case tv == nil:
+		return nil
+	case tv.StringValue != nil:
+		return *tv.StringValue
+	case tv.FloatValue != nil:
+		return *tv.FloatValue
+	case tv.DoubleValue != nil:
+		return *tv.DoubleValue
+	case tv.IntValue != nil:
+		return *tv.IntValue
+	case tv.UintValue != nil:
+		return *tv.UintValue
+	case tv.SintValue != nil:
+		return *tv.SintValue
+	case tv.BoolValue != nil:
+		return *tv.BoolValue
+	default:
+		return nil
+	}
+}
+
+// layerFromVTileLayer decodes a vector tile layer. Feature geometries are in tile
+// coordinates, between 0 and the layer extent.
+func layerFromVTileLayer(vtl *vectorTile.Tile_Layer) (*Layer, error) {
+	l := &Layer{
+		Name: vtl.GetName(),
+	}
+	if vtl.Extent != nil {
+		l.SetExtent(int(*vtl.Extent))
+	}
+
+	vals := make([]interface{}, len(vtl.Values))
+	for i := range vtl.Values {
+		vals[i] = vectorTileValueToValue(vtl.Values[i])
+	}
+
+	for i, vtf := range vtl.Features {
+		f, err := featureFromVTileFeature(vtl.Keys, vals, vtf)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding feature (%v) of layer (%v): %v", i, l.Name, err)
+		}
+		//	features are added directly as ids are not guaranteed to be unique in a tile
+		l.features = append(l.features, f)
+	}
+
+	return l, nil
+}
+
+//WebMercatorLayer returns a copy of a decoded layer (see Decode) named name, with the geometries of its
+// features converted from tile coordinates to webmercator for the tile of the given extent, and the default
+// tags (dtags) added to the tags of the features. Features without a geometry are dropped, the other features
+// are all kept as ids are not guaranteed to be unique in a tile.
+func (l *Layer) WebMercatorLayer(ctx context.Context, name string, extent tegola.BoundingBox, dtags map[string]interface{}) (*Layer, error) {
+	layer := &Layer{
+		Name:     name,
+		features: make([]Feature, 0, len(l.features)),
+	}
+
+	//	the tile's y axis is flipped, Miny is the top of the tile
+	xspan := (extent.Maxx - extent.Minx) / float64(l.Extent())
+	yspan := (extent.Maxy - extent.Miny) / float64(l.Extent())
+
+	toWebMercator := func(coords ...float64) ([]float64, error) {
+		return []float64{
+			extent.Minx + coords[0]*xspan,
+			extent.Miny + coords[1]*yspan,
+		}, nil
+	}
+
+	for i, f := range l.features {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if f.Geometry == nil {
+			continue
+		}
+
+		g, err := basic.ApplyToPoints(f.Geometry, toWebMercator)
+		if err != nil {
+			return nil, fmt.Errorf("Error converting feature (%v) of layer (%v): %v", i, l.Name, err)
+		}
+
+		//	the feature's tags override the default tags
+		tags := make(map[string]interface{}, len(dtags)+len(f.Tags))
+		for k, v := range dtags {
+			tags[k] = v
+		}
+		for k, v := range f.Tags {
+			tags[k] = v
+		}
+
+		layer.features = append(layer.features, Feature{
+			ID:       f.ID,
+			Tags:     tags,
+			Geometry: g.Geometry,
+		})
+	}
+
+	return layer, nil
+}
+
 // VTileLayer returns a vectorTile Tile_Layer object that represents this layer.
 func (l *Layer) VTileLayer(ctx context.Context, extent tegola.BoundingBox) (*vectorTile.Tile_Layer, error) {
 	kmap, vmap, err := keyvalMapsFromFeatures(l.features)
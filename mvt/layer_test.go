@@ -233,3 +233,47 @@ func TestLayer(t *testing.T) {
 
 	}
 }
+
+func TestLayerWebMercatorLayer(t *testing.T) {
+	newID := func(id uint64) *uint64 { return &id }
+
+	//	a decoded layer, with repeated ids
+	l := &Layer{
+		Name: "decoded",
+		features: []Feature{
+			{ID: newID(0), Geometry: basic.Point{0, 0}, Tags: map[string]interface{}{"name": "a"}},
+			{ID: newID(0), Geometry: basic.Point{128, 256}, Tags: map[string]interface{}{"name": "b", "class": "poi"}},
+			{ID: newID(1)},
+			{ID: newID(2), Geometry: basic.Line{{0, 256}, {256, 0}}},
+		},
+	}
+	l.SetExtent(256)
+
+	max := 20037508.34
+	tile := tegola.Tile{Z: 1, X: 0, Y: 0}
+
+	got, err := l.WebMercatorLayer(context.Background(), "layer", tile.BoundingBox(), map[string]interface{}{"class": "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Feature{
+		{ID: newID(0), Geometry: basic.Point{-max, max}, Tags: map[string]interface{}{"name": "a", "class": "default"}},
+		{ID: newID(0), Geometry: basic.Point{-max / 2, 0}, Tags: map[string]interface{}{"name": "b", "class": "poi"}},
+		{ID: newID(2), Geometry: basic.Line{{-max, 0}, {0, max}}, Tags: map[string]interface{}{"class": "default"}},
+	}
+
+	if got.Name != "layer" {
+		t.Errorf("name, expected layer got %v", got.Name)
+	}
+	if !reflect.DeepEqual(got.Features(), expected) {
+		t.Errorf("features, expected %v got %v", expected, got.Features())
+	}
+
+	//	canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.WebMercatorLayer(ctx, "layer", tile.BoundingBox(), nil); err != context.Canceled {
+		t.Errorf("canceled context, expected %v got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,50 @@
+package mvt
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+//	Params are request parameters (i.e. query string values) passed to providers
+//	for filtering the features of a layer. Providers only use the parameters
+//	their layers declare.
+type Params map[string]string
+
+//	Encode returns the params as a URL encoded query string sorted by name.
+//	The output is stable so it can be used in cache keys.
+func (p Params) Encode() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, url.QueryEscape(name)+"="+url.QueryEscape(p[name]))
+	}
+
+	return strings.Join(parts, "&")
+}
+
+//	ParamsProvider is implemented by providers with layers that accept request parameters
+type ParamsProvider interface {
+	//	LayerParams returns the names of the request parameters the layer accepts
+	LayerParams(providerLayerName string) []string
+}
+
+type paramsKey struct{}
+
+//	ContextWithParams returns a copy of the context carrying the request parameters
+//	down to the providers' MVTLayer calls.
+func ContextWithParams(ctx context.Context, params Params) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+//	ParamsFromContext returns the request parameters carried by the context, if any
+func ParamsFromContext(ctx context.Context) Params {
+	params, _ := ctx.Value(paramsKey{}).(Params)
+	return params
+}
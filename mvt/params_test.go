@@ -0,0 +1,45 @@
+package mvt
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParamsEncode(t *testing.T) {
+	testcases := []struct {
+		params   Params
+		expected string
+	}{
+		{
+			params:   Params{},
+			expected: "",
+		},
+		{
+			params:   Params{"class": "B", "after": "2026-01-01"},
+			expected: "after=2026-01-01&class=B",
+		},
+		{
+			params:   Params{"name": "a&b=c d"},
+			expected: "name=a%26b%3Dc+d",
+		},
+	}
+
+	for i, tc := range testcases {
+		if output := tc.params.Encode(); output != tc.expected {
+			t.Errorf("testcase (%v) failed. expected (%v) got (%v)", i, tc.expected, output)
+		}
+	}
+}
+
+func TestParamsContext(t *testing.T) {
+	if params := ParamsFromContext(context.Background()); params != nil {
+		t.Errorf("expected nil params got (%v)", params)
+	}
+
+	params := Params{"class": "B"}
+	ctx := ContextWithParams(context.Background(), params)
+	if output := ParamsFromContext(ctx); !reflect.DeepEqual(output, params) {
+		t.Errorf("expected params (%v) got (%v)", params, output)
+	}
+}
@@ -16,8 +16,50 @@ type Provider interface {
 	Layers() ([]LayerInfo, error)
 }
 
+//	BatchProvider is implemented by providers that can fetch several layers of a tile in one go
+//	(i.e. on a single database connection)
+type BatchProvider interface {
+	//	MVTLayers returns the layers in the order of the provider layer names. tags are the
+	//	default tags of each layer, in the same order.
+	MVTLayers(ctx context.Context, providerLayerNames []string, tile tegola.Tile, tags []map[string]interface{}) ([]*Layer, error)
+}
+
+//	Closer is implemented by providers holding resources (i.e. connection pools or open files)
+//	that need to be released when the provider is no longer used
+type Closer interface {
+	//	Close releases the provider's resources. The provider can not be used after it's closed.
+	Close() error
+}
+
+//	HealthChecker is implemented by providers that can report if they are able to serve tiles
+type HealthChecker interface {
+	//	Health returns an error if the provider can't serve tiles (i.e. its database is down)
+	Health(ctx context.Context) error
+}
+
 type LayerInfo interface {
 	Name() string
 	GeomType() tegola.Geometry
 	SRID() int
 }
+
+//	The attribute types reported by LayerFields. They match the types
+//	a feature's tags can be encoded as.
+const (
+	FieldTypeString  = "String"
+	FieldTypeNumber  = "Number"
+	FieldTypeBoolean = "Boolean"
+)
+
+//	LayerFields is implemented by LayerInfo types that know the attribute schema of their layer
+type LayerFields interface {
+	//	Fields returns the layer's attribute names mapped to their type (i.e. FieldTypeString)
+	Fields() map[string]string
+}
+
+//	NativeLayer is implemented by LayerInfo types whose layers can be encoded by the provider (see Layer.Raw).
+//	The features of these layers can't be changed, so default tags can't be added to them.
+type NativeLayer interface {
+	//	NativeMVT reports whether the layer is encoded by the provider
+	NativeMVT() bool
+}
@@ -0,0 +1,408 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/util/dict"
+)
+
+//	The config keys, common to all providers, limiting the provider's MVTLayer calls
+const (
+	ConfigKeyMaxConcurrency   = "max_concurrency"
+	ConfigKeyQueueTimeout     = "queue_timeout"
+	ConfigKeyBreakerThreshold = "breaker_threshold"
+	ConfigKeyBreakerTimeout   = "breaker_timeout"
+)
+
+const (
+	//	seconds the circuit breaker stays open before a call is let through to test the provider
+	DefaultBreakerTimeout = 30
+)
+
+var (
+	//	ErrQueueTimeout is returned when a call waited longer than the queue timeout for one of the provider's concurrency slots
+	ErrQueueTimeout = errors.New("timed out waiting for the provider")
+	//	ErrCircuitOpen is returned, without calling the provider, while the provider's circuit breaker is open
+	ErrCircuitOpen = errors.New("provider circuit breaker is open")
+)
+
+//	metrics holds the metrics of the limited providers keyed by provider name. they are
+//	published with the expvar package (i.e. /debug/vars)
+var metrics = expvar.NewMap("providers")
+
+//	the states of a circuit breaker
+type breakerState int
+
+const (
+	//	calls are let through
+	breakerClosed breakerState = iota
+	//	calls fail fast with ErrCircuitOpen
+	breakerOpen
+	//	a single call is let through to test the provider
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+//	Limit wraps the provider to limit its concurrent MVTLayer calls and to fail fast, with a circuit breaker,
+//	while the provider keeps erroring. The provider is returned as it is if the config does not set any limits.
+//	The following config params are supported:
+//
+//		max_concurrency (int) — Optional. The max number of concurrent MVTLayer calls. Calls over the limit are queued. Default is 0, no limit.
+//		queue_timeout (int) — Optional. The max number of seconds a call is queued for. Default is 0, the call waits until its request is canceled.
+//		breaker_threshold (int) — Optional. The number of consecutive errors that opens the circuit breaker. Default is 0, no circuit breaker.
+//		breaker_timeout (int) — Optional. The number of seconds the circuit breaker stays open before a call is let through to test the provider. Default is 30.
+//
+func Limit(name string, p mvt.Provider, config map[string]interface{}) (mvt.Provider, error) {
+	c := dict.M(config)
+
+	var maxConcurrency int64
+	maxConcurrency, err := c.Int64(ConfigKeyMaxConcurrency, &maxConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	if maxConcurrency < 0 {
+		return nil, fmt.Errorf("%v (%v) can not be negative", ConfigKeyMaxConcurrency, maxConcurrency)
+	}
+
+	var queueTimeout int64
+	if queueTimeout, err = c.Int64(ConfigKeyQueueTimeout, &queueTimeout); err != nil {
+		return nil, err
+	}
+	if queueTimeout < 0 {
+		return nil, fmt.Errorf("%v (%v) can not be negative", ConfigKeyQueueTimeout, queueTimeout)
+	}
+
+	var threshold int64
+	if threshold, err = c.Int64(ConfigKeyBreakerThreshold, &threshold); err != nil {
+		return nil, err
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("%v (%v) can not be negative", ConfigKeyBreakerThreshold, threshold)
+	}
+
+	breakerTimeout := int64(DefaultBreakerTimeout)
+	if breakerTimeout, err = c.Int64(ConfigKeyBreakerTimeout, &breakerTimeout); err != nil {
+		return nil, err
+	}
+	if breakerTimeout <= 0 {
+		return nil, fmt.Errorf("%v (%v) must be greater than 0", ConfigKeyBreakerTimeout, breakerTimeout)
+	}
+
+	if maxConcurrency == 0 && threshold == 0 {
+		return p, nil
+	}
+
+	lp := Limited{
+		name:             name,
+		provider:         p,
+		queueTimeout:     time.Duration(queueTimeout) * time.Second,
+		breakerThreshold: int(threshold),
+		breakerTimeout:   time.Duration(breakerTimeout) * time.Second,
+	}
+	if maxConcurrency > 0 {
+		lp.slots = make(chan struct{}, maxConcurrency)
+	}
+
+	//	publish the provider's metrics
+	m := new(expvar.Map).Init()
+	m.Set("state", &lp.metrics.state)
+	m.Set("in_flight", &lp.metrics.inFlight)
+	m.Set("queued", &lp.metrics.queued)
+	m.Set("queue_timeouts", &lp.metrics.queueTimeouts)
+	m.Set("errors", &lp.metrics.errors)
+	m.Set("short_circuited", &lp.metrics.shortCircuited)
+	lp.metrics.state.Set(breakerClosed.String())
+	metrics.Set(name, m)
+
+	//	pass through the fetching of several layers in one go and the health checks
+	_, batch := p.(mvt.BatchProvider)
+	_, health := p.(mvt.HealthChecker)
+	switch {
+	case batch && health:
+		return &limitedBatchHealth{limitedBatch{&lp}}, nil
+	case batch:
+		return &limitedBatch{&lp}, nil
+	case health:
+		return &limitedHealth{&lp}, nil
+	}
+
+	return &lp, nil
+}
+
+//	Limited is a provider with limits on its MVTLayer calls (see Limit). The optional provider
+//	interfaces (i.e. mvt.Closer) are passed through to the wrapped provider. mvt.BatchProvider and
+//	mvt.HealthChecker are only implemented by the wrappers returned by Limit for providers implementing them.
+type Limited struct {
+	name     string
+	provider mvt.Provider
+
+	//	a slot is held for each MVTLayer call in progress. nil if the calls are not limited
+	slots chan struct{}
+	//	0 waits for a slot until the call's context is done
+	queueTimeout time.Duration
+
+	//	0 disables the circuit breaker
+	breakerThreshold int
+	breakerTimeout   time.Duration
+
+	//	the state of the circuit breaker
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	//	true while the half-open test call is in progress
+	testing bool
+	//	incremented on each state change so the results of calls started in an earlier state are ignored
+	generation uint64
+
+	metrics struct {
+		state          expvar.String
+		inFlight       expvar.Int
+		queued         expvar.Int
+		queueTimeouts  expvar.Int
+		errors         expvar.Int
+		shortCircuited expvar.Int
+	}
+}
+
+//	Provider returns the wrapped provider
+func (l *Limited) Provider() mvt.Provider {
+	return l.provider
+}
+
+func (l *Limited) Layers() ([]mvt.LayerInfo, error) {
+	return l.provider.Layers()
+}
+
+func (l *Limited) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (layer *mvt.Layer, err error) {
+	err = l.call(ctx, func() error {
+		layer, err = l.provider.MVTLayer(ctx, layerName, tile, dtags)
+		return err
+	})
+	return layer, err
+}
+
+//	call runs fn, which calls the provider, once the circuit breaker and a concurrency slot allow it
+func (l *Limited) call(ctx context.Context, fn func() error) error {
+	gen, err := l.allow()
+	if err != nil {
+		l.metrics.shortCircuited.Add(1)
+		return err
+	}
+
+	if err = l.acquire(ctx); err != nil {
+		l.done(gen, nil, true)
+		return err
+	}
+	l.metrics.inFlight.Add(1)
+
+	err = fn()
+
+	l.metrics.inFlight.Add(-1)
+	l.release()
+
+	//	errors caused by the request being canceled are not the provider's
+	canceled := err != nil && (err == context.Canceled || ctx.Err() != nil)
+	if err != nil && !canceled {
+		l.metrics.errors.Add(1)
+	}
+	l.done(gen, err, canceled)
+
+	return err
+}
+
+//	acquire waits for a concurrency slot
+func (l *Limited) acquire(ctx context.Context) error {
+	if l.slots == nil {
+		return nil
+	}
+
+	//	don't bother with the queue if there's a free slot
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.metrics.queued.Add(1)
+	defer l.metrics.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		l.metrics.queueTimeouts.Add(1)
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limited) release() {
+	if l.slots == nil {
+		return
+	}
+	<-l.slots
+}
+
+//	allow returns ErrCircuitOpen if the circuit breaker is open, otherwise the
+//	generation of the breaker's state the call is made in
+func (l *Limited) allow() (uint64, error) {
+	if l.breakerThreshold == 0 {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch l.state {
+	case breakerOpen:
+		if time.Since(l.openedAt) < l.breakerTimeout {
+			return 0, ErrCircuitOpen
+		}
+		l.setState(breakerHalfOpen)
+		l.testing = true
+	case breakerHalfOpen:
+		//	only one call tests the provider
+		if l.testing {
+			return 0, ErrCircuitOpen
+		}
+		l.testing = true
+	}
+
+	return l.generation, nil
+}
+
+//	done records the result of a call with the circuit breaker. ignore is set when the
+//	call didn't reach the provider or failed because its request was canceled.
+func (l *Limited) done(gen uint64, err error, ignore bool) {
+	if l.breakerThreshold == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if gen != l.generation {
+		return
+	}
+	if l.state == breakerHalfOpen {
+		l.testing = false
+	}
+
+	switch {
+	case ignore:
+	case err == nil:
+		l.failures = 0
+		if l.state != breakerClosed {
+			l.setState(breakerClosed)
+			log.Printf("provider (%v) circuit breaker closed", l.name)
+		}
+	default:
+		l.failures++
+		if l.state == breakerHalfOpen || l.failures >= l.breakerThreshold {
+			l.openedAt = time.Now()
+			l.setState(breakerOpen)
+			log.Printf("provider (%v) circuit breaker opened for %v after %v consecutive errors. last error: %v", l.name, l.breakerTimeout, l.failures, err)
+		}
+	}
+}
+
+//	setState changes the state of the circuit breaker. l.mu must be held.
+func (l *Limited) setState(s breakerState) {
+	l.state = s
+	l.generation++
+	l.metrics.state.Set(s.String())
+	if s == breakerHalfOpen {
+		log.Printf("provider (%v) circuit breaker half-open, testing the provider", l.name)
+	}
+}
+
+//	LayerParams passes through the request parameters of the wrapped provider's layers (see mvt.ParamsProvider)
+func (l *Limited) LayerParams(layerName string) []string {
+	pp, ok := l.provider.(mvt.ParamsProvider)
+	if !ok {
+		return nil
+	}
+	return pp.LayerParams(layerName)
+}
+
+//	Close closes the wrapped provider if it implements mvt.Closer
+func (l *Limited) Close() error {
+	c, ok := l.provider.(mvt.Closer)
+	if !ok {
+		return nil
+	}
+	return c.Close()
+}
+
+//	health reports the provider unhealthy while the circuit breaker is open, otherwise it returns
+//	the health of the wrapped provider
+func (l *Limited) health(ctx context.Context) error {
+	l.mu.Lock()
+	open := l.state == breakerOpen
+	l.mu.Unlock()
+	if open {
+		return ErrCircuitOpen
+	}
+
+	return l.provider.(mvt.HealthChecker).Health(ctx)
+}
+
+//	limitedHealth is a Limited provider wrapping a mvt.HealthChecker
+type limitedHealth struct {
+	*Limited
+}
+
+func (l *limitedHealth) Health(ctx context.Context) error {
+	return l.health(ctx)
+}
+
+//	limitedBatch is a Limited provider wrapping a mvt.BatchProvider. A MVTLayers call holds a single concurrency slot.
+type limitedBatch struct {
+	*Limited
+}
+
+func (l *limitedBatch) MVTLayers(ctx context.Context, layerNames []string, tile tegola.Tile, tags []map[string]interface{}) (layers []*mvt.Layer, err error) {
+	err = l.call(ctx, func() error {
+		layers, err = l.provider.(mvt.BatchProvider).MVTLayers(ctx, layerNames, tile, tags)
+		return err
+	})
+	return layers, err
+}
+
+//	limitedBatchHealth is a Limited provider wrapping a mvt.BatchProvider which is also a mvt.HealthChecker
+type limitedBatchHealth struct {
+	limitedBatch
+}
+
+func (l *limitedBatchHealth) Health(ctx context.Context) error {
+	return l.health(ctx)
+}
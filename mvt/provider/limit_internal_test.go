@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
+)
+
+//	testProvider blocks its MVTLayer calls until release is closed and returns err
+type testProvider struct {
+	mu      sync.Mutex
+	calls   int
+	err     error
+	release chan struct{}
+}
+
+func (tp *testProvider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (*mvt.Layer, error) {
+	tp.mu.Lock()
+	tp.calls++
+	err, release := tp.err, tp.release
+	tp.mu.Unlock()
+
+	if release != nil {
+		<-release
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mvt.Layer{Name: layerName}, nil
+}
+
+func (tp *testProvider) Layers() ([]mvt.LayerInfo, error) {
+	return nil, nil
+}
+
+func (tp *testProvider) setErr(err error) {
+	tp.mu.Lock()
+	tp.err = err
+	tp.mu.Unlock()
+}
+
+//	testBatchProvider can fetch several layers in one go
+type testBatchProvider struct {
+	testProvider
+}
+
+func (tp *testBatchProvider) MVTLayers(ctx context.Context, layerNames []string, tile tegola.Tile, tags []map[string]interface{}) ([]*mvt.Layer, error) {
+	return nil, nil
+}
+
+//	testHealthProvider reports its health
+type testHealthProvider struct {
+	testProvider
+}
+
+func (tp *testHealthProvider) Health(ctx context.Context) error {
+	return nil
+}
+
+//	testBatchHealthProvider can fetch several layers in one go and reports its health
+type testBatchHealthProvider struct {
+	testBatchProvider
+}
+
+func (tp *testBatchHealthProvider) Health(ctx context.Context) error {
+	return nil
+}
+
+func TestLimit(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		limited   bool
+		expectErr bool
+	}{
+		{
+			config: map[string]interface{}{},
+		},
+		{
+			config:  map[string]interface{}{ConfigKeyMaxConcurrency: int64(4)},
+			limited: true,
+		},
+		{
+			config:  map[string]interface{}{ConfigKeyBreakerThreshold: int64(5)},
+			limited: true,
+		},
+		{
+			config:    map[string]interface{}{ConfigKeyMaxConcurrency: int64(-1)},
+			expectErr: true,
+		},
+		{
+			config:    map[string]interface{}{ConfigKeyMaxConcurrency: "4"},
+			expectErr: true,
+		},
+		{
+			config:    map[string]interface{}{ConfigKeyBreakerThreshold: int64(5), ConfigKeyBreakerTimeout: int64(0)},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		tp := &testProvider{}
+		p, err := Limit("test", tp, tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("[%v] expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+
+		if _, ok := p.(*Limited); ok != tc.limited {
+			t.Errorf("[%v] limited, expected %v got %T", i, tc.limited, p)
+		}
+	}
+
+	//	fetching several layers in one go and the health checks are only passed through
+	//	for the providers implementing them
+	passThrough := []struct {
+		provider mvt.Provider
+		batch    bool
+		health   bool
+	}{
+		{provider: &testProvider{}},
+		{provider: &testBatchProvider{}, batch: true},
+		{provider: &testHealthProvider{}, health: true},
+		{provider: &testBatchHealthProvider{}, batch: true, health: true},
+	}
+	for i, tc := range passThrough {
+		p, err := Limit("test-pass-through", tc.provider, map[string]interface{}{ConfigKeyMaxConcurrency: int64(4)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := p.(mvt.BatchProvider); ok != tc.batch {
+			t.Errorf("[%v] mvt.BatchProvider, expected %v got %T", i, tc.batch, p)
+		}
+		if _, ok := p.(mvt.HealthChecker); ok != tc.health {
+			t.Errorf("[%v] mvt.HealthChecker, expected %v got %T", i, tc.health, p)
+		}
+	}
+}
+
+func TestLimitedQueue(t *testing.T) {
+	tp := &testProvider{release: make(chan struct{})}
+	p, err := Limit("test-queue", tp, map[string]interface{}{ConfigKeyMaxConcurrency: int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := p.(*Limited)
+	l.queueTimeout = 20 * time.Millisecond
+
+	//	fill the slots
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.MVTLayer(context.Background(), "layer", tegola.Tile{}, nil); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	for l.metrics.inFlight.Value() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := l.MVTLayer(context.Background(), "layer", tegola.Tile{}, nil); err != ErrQueueTimeout {
+		t.Errorf("expected %v got %v", ErrQueueTimeout, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.MVTLayer(ctx, "layer", tegola.Tile{}, nil); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+
+	//	queued calls run once a slot is released
+	l.queueTimeout = 0
+	done := make(chan error)
+	go func() {
+		_, err := l.MVTLayer(context.Background(), "layer", tegola.Tile{}, nil)
+		done <- err
+	}()
+	for l.metrics.queued.Value() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(tp.release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	wg.Wait()
+
+	if tp.calls != 3 {
+		t.Errorf("expected 3 provider calls got %v", tp.calls)
+	}
+	if l.metrics.queueTimeouts.Value() != 1 {
+		t.Errorf("expected 1 queue timeout got %v", l.metrics.queueTimeouts.Value())
+	}
+}
+
+func TestLimitedBreaker(t *testing.T) {
+	tp := &testHealthProvider{testProvider{err: errors.New("database down")}}
+	p, err := Limit("test-breaker", tp, map[string]interface{}{ConfigKeyBreakerThreshold: int64(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := p.(*limitedHealth)
+	l.breakerTimeout = 20 * time.Millisecond
+
+	call := func() error {
+		_, err := l.MVTLayer(context.Background(), "layer", tegola.Tile{}, nil)
+		return err
+	}
+
+	//	the breaker opens after 3 consecutive errors
+	for i := 0; i < 3; i++ {
+		if err := call(); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("[%v] expected the provider's error got %v", i, err)
+		}
+	}
+	if err := call(); err != ErrCircuitOpen {
+		t.Fatalf("expected %v got %v", ErrCircuitOpen, err)
+	}
+	if err := l.Health(context.Background()); err != ErrCircuitOpen {
+		t.Errorf("health, expected %v got %v", ErrCircuitOpen, err)
+	}
+	if tp.calls != 3 {
+		t.Errorf("expected 3 provider calls got %v", tp.calls)
+	}
+
+	//	a failed test call opens the breaker again
+	time.Sleep(l.breakerTimeout)
+	if err := call(); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("expected the provider's error got %v", err)
+	}
+	if err := call(); err != ErrCircuitOpen {
+		t.Fatalf("expected %v got %v", ErrCircuitOpen, err)
+	}
+
+	//	a successful test call closes the breaker
+	tp.setErr(nil)
+	time.Sleep(l.breakerTimeout)
+	for i := 0; i < 2; i++ {
+		if err := call(); err != nil {
+			t.Fatalf("[%v] unexpected error: %v", i, err)
+		}
+	}
+	if got := l.metrics.state.Value(); got != breakerClosed.String() {
+		t.Errorf("state, expected %v got %v", breakerClosed, got)
+	}
+
+	//	canceled requests are not counted as errors
+	tp.setErr(context.Canceled)
+	for i := 0; i < 5; i++ {
+		if err := call(); err != context.Canceled {
+			t.Fatalf("[%v] expected %v got %v", i, context.Canceled, err)
+		}
+	}
+	if got := l.metrics.state.Value(); got != breakerClosed.String() {
+		t.Errorf("state, expected %v got %v", breakerClosed, got)
+	}
+}
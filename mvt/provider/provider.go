@@ -3,6 +3,7 @@ package provider
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/airmap/tegola/mvt"
 )
@@ -46,3 +47,41 @@ func For(name string, config map[string]interface{}) (mvt.Provider, error) {
 	}
 	return p(config)
 }
+
+//	the providers that have been initialized keyed by the name they're configured with
+var (
+	instances   map[string]mvt.Provider
+	instancesMu sync.RWMutex
+)
+
+// Add records an initialized provider under its configured name so providers built on other providers (i.e. composite) can look it up.
+func Add(name string, p mvt.Provider) error {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+
+	if instances == nil {
+		instances = make(map[string]mvt.Provider)
+	}
+	if _, ok := instances[name]; ok {
+		return fmt.Errorf("provider (%v) already registered!", name)
+	}
+	instances[name] = p
+	return nil
+}
+
+// Lookup returns the initialized provider added under the name.
+func Lookup(name string) (mvt.Provider, bool) {
+	instancesMu.RLock()
+	defer instancesMu.RUnlock()
+
+	p, ok := instances[name]
+	return p, ok
+}
+
+// Remove removes the provider added under the name (i.e. when it's closed).
+func Remove(name string) {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+
+	delete(instances, name)
+}
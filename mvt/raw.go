@@ -0,0 +1,100 @@
+package mvt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+//	the protobuf keys, field number << 3 | wire type, of the vector tile fields a raw layer is read through
+const (
+	//	Tile.layers
+	tileLayersKey = 3<<3 | wireTypeBytes
+	//	Layer.name
+	layerNameKey = 1<<3 | wireTypeBytes
+)
+
+//	the protobuf wire types
+const (
+	wireTypeVarint  = 0
+	wireTypeFixed64 = 1
+	wireTypeBytes   = 2
+	wireTypeFixed32 = 5
+)
+
+var errMalformedRawLayer = errors.New("malformed protobuf")
+
+//	setRawLayerName returns the raw layer, a vector tile holding a single layer, with the layer's name
+//	set to name. The layer is returned as it is if it already has the name or it's empty (i.e. ST_AsMVT
+//	returns no bytes when there aren't any features).
+func setRawLayerName(raw []byte, name string) ([]byte, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+
+	key, n := proto.DecodeVarint(raw)
+	if n == 0 || key != tileLayersKey {
+		return nil, fmt.Errorf("expected a vector tile with a single layer: %v", errMalformedRawLayer)
+	}
+	size, m := proto.DecodeVarint(raw[n:])
+	if m == 0 || size != uint64(len(raw)-n-m) {
+		return nil, fmt.Errorf("expected a vector tile with a single layer: %v", errMalformedRawLayer)
+	}
+	layer := raw[n+m:]
+
+	//	the layer's fields, other than its name
+	var fields []byte
+	for i := 0; i < len(layer); {
+		start := i
+
+		key, n := proto.DecodeVarint(layer[i:])
+		if n == 0 {
+			return nil, errMalformedRawLayer
+		}
+		i += n
+
+		switch key & 7 {
+		case wireTypeVarint:
+			if _, n = proto.DecodeVarint(layer[i:]); n == 0 {
+				return nil, errMalformedRawLayer
+			}
+			i += n
+		case wireTypeFixed64:
+			i += 8
+		case wireTypeBytes:
+			l, n := proto.DecodeVarint(layer[i:])
+			if n == 0 || l > uint64(len(layer)-i-n) {
+				return nil, errMalformedRawLayer
+			}
+			i += n + int(l)
+
+			if key == layerNameKey {
+				if string(layer[i-int(l):i]) == name {
+					return raw, nil
+				}
+				continue
+			}
+		case wireTypeFixed32:
+			i += 4
+		default:
+			return nil, errMalformedRawLayer
+		}
+		if i > len(layer) {
+			return nil, errMalformedRawLayer
+		}
+
+		fields = append(fields, layer[start:i]...)
+	}
+
+	b := proto.NewBuffer(nil)
+	b.EncodeVarint(layerNameKey)
+	b.EncodeStringBytes(name)
+	named := append(b.Bytes(), fields...)
+
+	b = proto.NewBuffer(nil)
+	b.EncodeVarint(tileLayersKey)
+	b.EncodeRawBytes(named)
+
+	return b.Bytes(), nil
+}
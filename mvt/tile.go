@@ -1,10 +1,15 @@
 package mvt
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 
 	"context"
 
+	"github.com/golang/protobuf/proto"
+
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/mvt/vector_tile"
 )
@@ -41,9 +46,14 @@ func (t *Tile) Layers() (l []Layer) {
 
 //VTile returns a tile object according to the Google Protobuff def. This function
 // does the hard work of converting everything to the standard.
+// Raw layers are not included (see Encode and EncodeVTile).
 func (t *Tile) VTile(ctx context.Context, extent tegola.BoundingBox) (vt *vectorTile.Tile, err error) {
 	vt = new(vectorTile.Tile)
 	for _, l := range t.layers {
+		if l.Raw != nil {
+			continue
+		}
+
 		vtl, err := l.VTileLayer(ctx, extent)
 		if err != nil {
 			switch err {
@@ -58,8 +68,90 @@ func (t *Tile) VTile(ctx context.Context, extent tegola.BoundingBox) (vt *vector
 	return vt, nil
 }
 
-//TODO: What is this functions suppose to do?
-//TileFromVTile will return a Tile object from the given vectorTile Tile object
+//rawLayers returns the raw layers of the tile (see Layer.Raw), with their names set, concatenated.
+// The protobuf encoding of a vector tile allows the layers to be appended to an encoded tile.
+func (t *Tile) rawLayers() ([]byte, error) {
+	var raw []byte
+	for _, l := range t.layers {
+		if l.Raw == nil {
+			continue
+		}
+
+		lraw, err := setRawLayerName(l.Raw, l.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading raw layer (%v): %v", l.Name, err)
+		}
+		raw = append(raw, lraw...)
+	}
+	return raw, nil
+}
+
+//Encode returns the protobuf encoding of the tile, with the raw layers appended to the encoded layers.
+func (t *Tile) Encode(ctx context.Context, extent tegola.BoundingBox) ([]byte, error) {
+	vt, err := t.VTile(ctx, extent)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.EncodeVTile(vt)
+}
+
+//EncodeVTile returns the protobuf encoding of vt, a vector tile returned by VTile, with the raw layers
+// of the tile appended. Layers can be added to vt before it's encoded.
+func (t *Tile) EncodeVTile(vt *vectorTile.Tile) ([]byte, error) {
+	data, err := proto.Marshal(vt)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := t.rawLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, raw...), nil
+}
+
+//TileFromVTile will return a Tile object from the given vectorTile Tile object. The geometries
+// of the features are in tile coordinates, between 0 and the extent of their layer, with the origin
+// at the top-left of the tile.
 func TileFromVTile(t *vectorTile.Tile) (*Tile, error) {
-	return nil, nil
+	if t == nil {
+		return nil, nil
+	}
+
+	tile := new(Tile)
+	for i, vtl := range t.Layers {
+		l, err := layerFromVTileLayer(vtl)
+		if err != nil {
+			return nil, err
+		}
+		if err = tile.AddLayers(l); err != nil {
+			return nil, fmt.Errorf("Error adding layer (%v): %v", i, err)
+		}
+	}
+	return tile, nil
+}
+
+//Decode will return a Tile object from the protobuf encoding of a vector tile, as
+// returned by the tile endpoints or stored in a cache. gzip compressed data is decompressed.
+func Decode(data []byte) (*Tile, error) {
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		if data, err = ioutil.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+
+	var vt vectorTile.Tile
+	if err := proto.Unmarshal(data, &vt); err != nil {
+		return nil, err
+	}
+
+	return TileFromVTile(&vt)
 }
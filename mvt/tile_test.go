@@ -0,0 +1,344 @@
+package mvt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+
+	"context"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+)
+
+func TestTileFromVTile(t *testing.T) {
+	// with this bounding box tile coordinates are the same as the input coordinates
+	baseBBox := tegola.BoundingBox{
+		Minx: 0,
+		Miny: 0,
+		Maxx: 4096,
+		Maxy: 4096,
+	}
+	newID := func(id uint64) *uint64 { return &id }
+
+	testcases := []struct {
+		features []Feature
+		// the expected geometry types of the decoded features
+		geomTypes []tegola.Geometry
+		// the expected tags of the decoded features. nil means the same as the input tags.
+		tags []map[string]interface{}
+	}{
+		{ // 0
+			features: []Feature{
+				{
+					ID:       newID(1),
+					Geometry: basic.Point{25, 17},
+					Tags: map[string]interface{}{
+						"string":  "value",
+						"int64":   int64(-10),
+						"uint64":  uint64(10),
+						"float32": float32(1.5),
+						"float64": float64(2.25),
+						"bool":    true,
+					},
+				},
+				{
+					ID:       newID(2),
+					Geometry: basic.MultiPoint{basic.Point{5, 7}, basic.Point{3, 2}},
+					Tags: map[string]interface{}{
+						"int32": int32(-3),
+						"uint8": uint8(3),
+					},
+				},
+			},
+			geomTypes: []tegola.Geometry{basic.Point{}, basic.MultiPoint{}},
+			tags: []map[string]interface{}{
+				nil,
+				// smaller ints are encoded as sint values
+				{"int32": int64(-3), "uint8": int64(3)},
+			},
+		},
+		{ // 1
+			features: []Feature{
+				{
+					ID:       newID(1),
+					Geometry: basic.Line{basic.Point{2, 2}, basic.Point{2, 10}, basic.Point{10, 10}},
+					Tags:     map[string]interface{}{"class": "road"},
+				},
+				{
+					ID: newID(2),
+					Geometry: basic.MultiLine{
+						basic.Line{basic.Point{2, 2}, basic.Point{2, 10}, basic.Point{10, 10}},
+						basic.Line{basic.Point{100, 100}, basic.Point{300, 500}},
+					},
+					Tags: map[string]interface{}{"class": "rail"},
+				},
+			},
+			geomTypes: []tegola.Geometry{basic.Line{}, basic.MultiLine{}},
+		},
+		{ // 2
+			features: []Feature{
+				//	polygon with a hole
+				{
+					ID: newID(1),
+					Geometry: basic.Polygon{
+						basic.Line{basic.Point{0, 0}, basic.Point{100, 0}, basic.Point{100, 100}, basic.Point{0, 100}},
+						basic.Line{basic.Point{20, 20}, basic.Point{20, 80}, basic.Point{80, 80}, basic.Point{80, 20}},
+					},
+					Tags: map[string]interface{}{"class": "park"},
+				},
+				{
+					ID: newID(2),
+					Geometry: basic.MultiPolygon{
+						basic.Polygon{
+							basic.Line{basic.Point{200, 200}, basic.Point{300, 200}, basic.Point{300, 300}, basic.Point{200, 300}},
+						},
+						basic.Polygon{
+							basic.Line{basic.Point{400, 400}, basic.Point{600, 400}, basic.Point{600, 600}, basic.Point{400, 600}},
+							basic.Line{basic.Point{450, 450}, basic.Point{450, 550}, basic.Point{550, 550}, basic.Point{550, 450}},
+						},
+					},
+					Tags: map[string]interface{}{"class": "lake"},
+				},
+			},
+			geomTypes: []tegola.Geometry{basic.Polygon{}, basic.MultiPolygon{}},
+		},
+	}
+
+	for i, tcase := range testcases {
+		layer := Layer{
+			Name:         "test",
+			DontSimplify: true,
+		}
+		layer.AddFeatures(tcase.features...)
+
+		var tile Tile
+		if err := tile.AddLayers(&layer); err != nil {
+			t.Fatalf("Test %v: Unexpected error: %v", i, err)
+		}
+
+		vt, err := tile.VTile(context.Background(), baseBBox)
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error encoding the tile: %v", i, err)
+			continue
+		}
+
+		decoded, err := TileFromVTile(vt)
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error decoding the tile: %v", i, err)
+			continue
+		}
+
+		layers := decoded.Layers()
+		if len(layers) != 1 {
+			t.Errorf("Test %v: Expected 1 layer got %v.", i, len(layers))
+			continue
+		}
+		if layers[0].Name != layer.Name {
+			t.Errorf("Test %v: Expected layer name %v got %v.", i, layer.Name, layers[0].Name)
+		}
+		if layers[0].Extent() != layer.Extent() {
+			t.Errorf("Test %v: Expected layer extent %v got %v.", i, layer.Extent(), layers[0].Extent())
+		}
+
+		features := layers[0].Features()
+		if len(features) != len(tcase.features) {
+			t.Errorf("Test %v: Expected %v features got %v.", i, len(tcase.features), len(features))
+			continue
+		}
+
+		for j, f := range features {
+			if f.ID == nil || *f.ID != *tcase.features[j].ID {
+				t.Errorf("Test %v: Feature %v expected id %v got %v.", i, j, *tcase.features[j].ID, f.ID)
+			}
+
+			tags := tcase.features[j].Tags
+			if tcase.tags != nil && tcase.tags[j] != nil {
+				tags = tcase.tags[j]
+			}
+			if !reflect.DeepEqual(tags, f.Tags) {
+				t.Errorf("Test %v: Feature %v expected tags %v got %v.", i, j, tags, f.Tags)
+			}
+
+			if reflect.TypeOf(f.Geometry) != reflect.TypeOf(tcase.geomTypes[j]) {
+				t.Errorf("Test %v: Feature %v expected geometry type %T got %T.", i, j, tcase.geomTypes[j], f.Geometry)
+			}
+		}
+
+		// encoding the decoded tile should give us the same command streams
+		rvt, err := decoded.VTile(context.Background(), baseBBox)
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error re-encoding the tile: %v", i, err)
+			continue
+		}
+		for j := range vt.Layers[0].Features {
+			expected, got := vt.Layers[0].Features[j], rvt.Layers[0].Features[j]
+			if !reflect.DeepEqual(expected.Geometry, got.Geometry) {
+				t.Errorf("Test %v: Feature %v expected geometry %v got %v.", i, j, expected.Geometry, got.Geometry)
+			}
+			if expected.GetType() != got.GetType() {
+				t.Errorf("Test %v: Feature %v expected geometry type %v got %v.", i, j, expected.GetType(), got.GetType())
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	id := uint64(1)
+	layer := Layer{
+		Name: "test",
+	}
+	layer.AddFeatures(Feature{
+		ID:       &id,
+		Geometry: basic.Point{25, 17},
+		Tags:     map[string]interface{}{"name": "point"},
+	})
+
+	var tile Tile
+	if err := tile.AddLayers(&layer); err != nil {
+		t.Fatal(err)
+	}
+	vt, err := tile.VTile(context.Background(), tegola.BoundingBox{Maxx: 4096, Maxy: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(vt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+
+	testcases := []struct {
+		data []byte
+		eerr bool
+	}{
+		{data: data},
+		{data: buf.Bytes()},
+		{data: []byte("not a tile"), eerr: true},
+	}
+
+	for i, tcase := range testcases {
+		decoded, err := Decode(tcase.data)
+		if tcase.eerr {
+			if err == nil {
+				t.Errorf("Test %v: Expected an error, got none.", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error: %v", i, err)
+			continue
+		}
+
+		layers := decoded.Layers()
+		if len(layers) != 1 || len(layers[0].Features()) != 1 {
+			t.Errorf("Test %v: Expected 1 layer with 1 feature got %v.", i, layers)
+			continue
+		}
+		f := layers[0].Features()[0]
+		if !reflect.DeepEqual(f.Geometry, basic.Point{25, 17}) {
+			t.Errorf("Test %v: Expected geometry %v got %v.", i, basic.Point{25, 17}, f.Geometry)
+		}
+		if f.Tags["name"] != "point" {
+			t.Errorf("Test %v: Expected tag name to be point got %v.", i, f.Tags["name"])
+		}
+	}
+}
+
+func TestDecodeZigZag(t *testing.T) {
+	for _, i := range []int64{0, -1, 1, -2, 2, 4095, -4095, 2147483647, -2147483648} {
+		if got := decodeZigZag(encodeZigZag(i)); got != i {
+			t.Errorf("Expected %v got %v.", i, got)
+		}
+	}
+}
+
+func TestEncodeRawLayers(t *testing.T) {
+	extent := tegola.BoundingBox{Maxx: 4096, Maxy: 4096}
+
+	//	a layer encoded elsewhere (i.e. by PostGIS ST_AsMVT)
+	id := uint64(7)
+	source := Layer{Name: "source"}
+	source.AddFeatures(Feature{
+		ID:       &id,
+		Geometry: basic.Point{25, 17},
+		Tags:     map[string]interface{}{"name": "raw"},
+	})
+	var sourceTile Tile
+	if err := sourceTile.AddLayers(&source); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := sourceTile.Encode(context.Background(), extent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		raw      []byte
+		name     string
+		expected []string
+		eerr     bool
+	}{
+		{raw: raw, name: "places", expected: []string{"roads", "places"}},
+		{raw: raw, name: "source", expected: []string{"roads", "source"}},
+		//	ST_AsMVT returns no bytes when there aren't any features
+		{raw: []byte{}, name: "places", expected: []string{"roads"}},
+		{raw: append(append([]byte{}, raw...), raw...), name: "places", eerr: true},
+		{raw: []byte("not a tile"), name: "places", eerr: true},
+	}
+
+	for i, tcase := range testcases {
+		roads := Layer{Name: "roads"}
+		roads.AddFeatures(Feature{
+			Geometry: basic.Line{{0, 0}, {100, 100}},
+		})
+
+		var tile Tile
+		if err := tile.AddLayers(&roads, &Layer{Name: tcase.name, Raw: tcase.raw}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := tile.Encode(context.Background(), extent)
+		if tcase.eerr {
+			if err == nil {
+				t.Errorf("Test %v: Expected an error, got none.", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error: %v", i, err)
+			continue
+		}
+
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Errorf("Test %v: Unexpected error decoding: %v", i, err)
+			continue
+		}
+
+		var names []string
+		for _, l := range decoded.Layers() {
+			names = append(names, l.Name)
+		}
+		if !reflect.DeepEqual(names, tcase.expected) {
+			t.Errorf("Test %v: Expected layers %v got %v.", i, tcase.expected, names)
+			continue
+		}
+
+		//	the raw layer's features are passed through
+		if len(decoded.Layers()) == 2 {
+			l := decoded.Layers()[1]
+			features := l.Features()
+			if len(features) != 1 || *features[0].ID != id || features[0].Tags["name"] != "raw" {
+				t.Errorf("Test %v: Expected the raw feature got %v.", i, features)
+			}
+		}
+	}
+}
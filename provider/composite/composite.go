@@ -0,0 +1,374 @@
+//	Package composite provides a data provider whose layers are the union of the layers of other
+//	providers (i.e. the same feature class split over several databases and files). The source
+//	layers are queried concurrently and their features are merged into a single layer.
+package composite
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
+)
+
+const Name = "composite"
+
+const (
+	//	the number of high bits of a feature id holding the source's id namespace
+	IDNamespaceBits = 16
+	//	the largest id_namespace
+	MaxIDNamespace = 1<<IDNamespaceBits - 1
+)
+
+const (
+	ConfigKeyLayers        = "layers"
+	ConfigKeyLayerName     = "name"
+	ConfigKeyNamespaceIDs  = "namespace_ids"
+	ConfigKeySources       = "sources"
+	ConfigKeyProviderLayer = "provider_layer"
+	ConfigKeyIDNamespace   = "id_namespace"
+	ConfigKeyRenameTags    = "rename_tags"
+	ConfigKeyDropTags      = "drop_tags"
+	ConfigKeyTags          = "tags"
+)
+
+func init() {
+	provider.Register(Name, NewProvider)
+}
+
+// Provider provides the composite data provider.
+type Provider struct {
+	// map of layer name and corrosponding sources
+	layers     map[string]Layer
+	firstlayer string
+}
+
+//	NewProvider Setups and returns a new composite provider or an error; if something is wrong.
+//	The providers the layers are made of must have been initialized, and added with provider.Add,
+//	before this provider (i.e. they come before it in the config). This means that the Provider
+//	expects the following fields to exists in the provided map[string]interface{} map:
+//
+//		layers (map[string]struct{})  — This is map of layers keyed by the layer name.
+//			namespace_ids (bool) — Prefix the feature ids with the id namespace of their source so features
+//				from different sources with the same id are not dropped as duplicates. Defaults to false.
+//			sources ([]map[string]struct{}) — The provider layers merged into the layer.
+//				provider_layer (string) — The provider layer in the provider.layer format.
+//				id_namespace (int) — The id namespace of the source. Defaults to the source's position (starting at 1).
+//				rename_tags (map[string]string) — Tags to rename keyed by their name in the source.
+//				drop_tags ([]string) — Tags of the source to drop.
+//				tags (map[string]interface{}) — Tags added to every feature of the source.
+//
+func NewProvider(config map[string]interface{}) (mvt.Provider, error) {
+	// Validate the config to make sure it has the values I care about and the types for those values.
+	c := dict.M(config)
+
+	layers, ok := c[ConfigKeyLayers].([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected %v to be a []map[string]interface{}", ConfigKeyLayers)
+	}
+
+	p := Provider{
+		layers: make(map[string]Layer),
+	}
+	lyrsSeen := make(map[string]int)
+
+	for i, v := range layers {
+		vc := dict.M(v)
+
+		lname, err := vc.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if j, ok := lyrsSeen[lname]; ok {
+			return nil, fmt.Errorf("%v layer name is duplicated in both layer %v and layer %v", lname, i, j)
+		}
+		lyrsSeen[lname] = i
+		if i == 0 {
+			p.firstlayer = lname
+		}
+
+		var namespaceIDs bool
+		if val, ok := vc[ConfigKeyNamespaceIDs]; ok {
+			if namespaceIDs, ok = val.(bool); !ok {
+				return nil, fmt.Errorf("For layer (%v) %v : %v value needs to be of type bool. Value is of type %T", i, lname, ConfigKeyNamespaceIDs, val)
+			}
+		}
+
+		sources, ok := vc[ConfigKeySources].([]map[string]interface{})
+		if !ok || len(sources) == 0 {
+			return nil, fmt.Errorf("For layer (%v) %v : %v is required", i, lname, ConfigKeySources)
+		}
+
+		l := Layer{
+			name: lname,
+			srid: tegola.WebMercator,
+		}
+		namespaces := map[uint64]string{}
+
+		for j := range sources {
+			src, err := newSource(sources[j], j)
+			if err != nil {
+				return nil, fmt.Errorf("For layer (%v) %v : %v (%v) %v", i, lname, ConfigKeySources, j, err)
+			}
+
+			if !namespaceIDs {
+				src.idNamespace = 0
+			} else if other, ok := namespaces[src.idNamespace]; ok {
+				return nil, fmt.Errorf("For layer (%v) %v : %v (%v) is used by both %v and %v", i, lname, ConfigKeyIDNamespace, src.idNamespace, other, src.providerLayer)
+			} else {
+				namespaces[src.idNamespace] = src.providerLayer
+			}
+
+			l.sources = append(l.sources, src)
+		}
+
+		l.setSchema()
+
+		p.layers[lname] = l
+	}
+
+	return &p, nil
+}
+
+//	newSource reads the config of a source and looks up its provider and layer
+func newSource(config map[string]interface{}, i int) (source, error) {
+	c := dict.M(config)
+	var src source
+
+	var err error
+	if src.providerLayer, err = c.String(ConfigKeyProviderLayer, nil); err != nil {
+		return src, err
+	}
+
+	//	split the provider layer (syntax is provider.layer)
+	parts := strings.Split(src.providerLayer, ".")
+	if len(parts) != 2 {
+		return src, fmt.Errorf("invalid %v (%v). expected the format provider.layer", ConfigKeyProviderLayer, src.providerLayer)
+	}
+	src.layerName = parts[1]
+
+	var ok bool
+	if src.provider, ok = provider.Lookup(parts[0]); !ok {
+		return src, fmt.Errorf("provider (%v) not found. it must be defined before the %v provider", parts[0], Name)
+	}
+
+	infos, err := src.provider.Layers()
+	if err != nil {
+		return src, fmt.Errorf("error fetching layer info from provider (%v): %v", parts[0], err)
+	}
+	for j := range infos {
+		if infos[j].Name() == src.layerName {
+			src.info = infos[j]
+			break
+		}
+	}
+	if src.info == nil {
+		return src, fmt.Errorf("layer (%v) is not registered with provider (%v)", src.layerName, parts[0])
+	}
+	//	native layers are encoded by their provider (see mvt.Layer.Raw) so they have no features to merge
+	if nl, ok := src.info.(mvt.NativeLayer); ok && nl.NativeMVT() {
+		return src, fmt.Errorf("layer (%v) of provider (%v) is encoded by the provider and can't be a source", src.layerName, parts[0])
+	}
+
+	ns := int64(i + 1)
+	if ns, err = c.Int64(ConfigKeyIDNamespace, &ns); err != nil {
+		return src, err
+	}
+	if ns < 0 || ns > MaxIDNamespace {
+		return src, fmt.Errorf("%v (%v) must be between 0 and %v", ConfigKeyIDNamespace, ns, MaxIDNamespace)
+	}
+	src.idNamespace = uint64(ns)
+
+	if val, ok := c[ConfigKeyRenameTags]; ok {
+		rename, ok := val.(map[string]interface{})
+		if !ok {
+			return src, fmt.Errorf("%v value needs to be a table. Value is of type %T", ConfigKeyRenameTags, val)
+		}
+
+		src.renameTags = make(map[string]string, len(rename))
+		for from, to := range rename {
+			if src.renameTags[from], ok = to.(string); !ok {
+				return src, fmt.Errorf("%v (%v) value needs to be of type string. Value is of type %T", ConfigKeyRenameTags, from, to)
+			}
+		}
+	}
+
+	drop, err := c.StringSlice(ConfigKeyDropTags)
+	if err != nil {
+		return src, err
+	}
+	if len(drop) > 0 {
+		src.dropTags = make(map[string]bool, len(drop))
+		for _, name := range drop {
+			src.dropTags[name] = true
+		}
+	}
+
+	if val, ok := c[ConfigKeyTags]; ok {
+		if src.tags, ok = val.(map[string]interface{}); !ok {
+			return src, fmt.Errorf("%v value needs to be a table. Value is of type %T", ConfigKeyTags, val)
+		}
+	}
+
+	return src, nil
+}
+
+//	setSchema sets the geometry type and fields of the layer from its sources. The geometry
+//	type is only known when the sources agree on it.
+func (l *Layer) setSchema() {
+	for i, src := range l.sources {
+		geomType := src.info.GeomType()
+		switch {
+		case i == 0:
+			l.geomType = geomType
+		case reflect.TypeOf(geomType) != reflect.TypeOf(l.geomType):
+			l.geomType = nil
+		}
+
+		fields := src.fields()
+		if len(fields) == 0 {
+			continue
+		}
+		if l.fields == nil {
+			l.fields = map[string]string{}
+		}
+		//	the first type seen for a field wins
+		for name, typ := range fields {
+			if _, ok := l.fields[name]; !ok {
+				l.fields[name] = typ
+			}
+		}
+	}
+}
+
+func (p *Provider) Layer(name string) (Layer, bool) {
+	if name == "" {
+		return p.layers[p.firstlayer], true
+	}
+	plyr, ok := p.layers[name]
+	return plyr, ok
+}
+
+func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
+	var ls []mvt.LayerInfo
+
+	for i := range p.layers {
+		ls = append(ls, p.layers[i])
+	}
+
+	return ls, nil
+}
+
+//	LayerParams returns the names of the request parameters accepted by any of the layer's sources
+func (p *Provider) LayerParams(layerName string) []string {
+	seen := map[string]bool{}
+	var names []string
+
+	plyr, _ := p.Layer(layerName)
+	for _, src := range plyr.sources {
+		pp, ok := src.provider.(mvt.ParamsProvider)
+		if !ok {
+			continue
+		}
+
+		for _, name := range pp.LayerParams(src.layerName) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+//	MVTLayer queries the sources of the layer concurrently and merges their features in the order
+//	the sources are configured. An error from any of the sources cancels the others and is returned.
+func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (*mvt.Layer, error) {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return nil, fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	//	canceled when one of the sources fails
+	srcCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*mvt.Layer, len(plyr.sources))
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var srcErr error
+
+	for i := range plyr.sources {
+		wg.Add(1)
+		go func(i int, src source) {
+			defer wg.Done()
+
+			l, err := src.provider.MVTLayer(srcCtx, src.layerName, tile, nil)
+			if err != nil {
+				once.Do(func() {
+					srcErr = fmt.Errorf("source (%v): %v", src.providerLayer, err)
+					cancel()
+				})
+				return
+			}
+			results[i] = l
+		}(i, plyr.sources[i])
+	}
+	wg.Wait()
+
+	//	the request was canceled. the sources' errors are a result of it
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if srcErr != nil {
+		return nil, srcErr
+	}
+
+	layer := mvt.Layer{
+		Name:         layerName,
+		DontSimplify: true,
+	}
+
+	var features []mvt.Feature
+	for i, src := range plyr.sources {
+		l := results[i]
+		//	the source has no layer for the tile
+		if l == nil {
+			continue
+		}
+
+		//	only skip simplification if all the sources do
+		layer.DontSimplify = layer.DontSimplify && l.DontSimplify
+		if l.MaxSimplificationZoom > layer.MaxSimplificationZoom {
+			layer.MaxSimplificationZoom = l.MaxSimplificationZoom
+		}
+
+		for _, f := range l.Features() {
+			//	copy our default tags to a tags map
+			tags := make(map[string]interface{}, len(dtags)+len(f.Tags))
+			for k, v := range dtags {
+				tags[k] = v
+			}
+			//	add the normalized feature tags to our map
+			for k, v := range src.normalize(f.Tags) {
+				tags[k] = v
+			}
+
+			f.Tags = tags
+			f.ID = src.namespaceID(f.ID)
+			features = append(features, f)
+		}
+	}
+	layer.AddFeatures(features...)
+
+	return &layer, nil
+}
@@ -0,0 +1,441 @@
+package composite_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/provider/composite"
+)
+
+//	testLayer is the layer info of a testProvider layer
+type testLayer struct {
+	name     string
+	geomType tegola.Geometry
+	fields   map[string]string
+	native   bool
+}
+
+func (l testLayer) Name() string              { return l.name }
+func (l testLayer) GeomType() tegola.Geometry { return l.geomType }
+func (l testLayer) SRID() int                 { return tegola.WebMercator }
+func (l testLayer) Fields() map[string]string { return l.fields }
+func (l testLayer) NativeMVT() bool           { return l.native }
+
+//	testProvider returns the same features for every tile
+type testProvider struct {
+	layer    testLayer
+	features []mvt.Feature
+	params   []string
+	err      error
+}
+
+func (tp *testProvider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, tags map[string]interface{}) (*mvt.Layer, error) {
+	if tp.err != nil {
+		return nil, tp.err
+	}
+	//	providers may return no layer for a tile without features
+	if tp.features == nil {
+		return nil, nil
+	}
+
+	layer := mvt.Layer{Name: layerName}
+	layer.AddFeatures(tp.features...)
+	return &layer, nil
+}
+
+func (tp *testProvider) Layers() ([]mvt.LayerInfo, error) {
+	return []mvt.LayerInfo{tp.layer}, nil
+}
+
+func (tp *testProvider) LayerParams(layerName string) []string {
+	return tp.params
+}
+
+func id(i uint64) *uint64 {
+	return &i
+}
+
+func init() {
+	provider.Add("db1", &testProvider{
+		layer: testLayer{
+			name:     "restricted",
+			geomType: basic.Polygon{},
+			fields:   map[string]string{"kind": mvt.FieldTypeString, "internal": mvt.FieldTypeNumber},
+		},
+		features: []mvt.Feature{
+			{ID: id(1), Tags: map[string]interface{}{"kind": "military", "internal": int64(7)}, Geometry: basic.Point{1, 1}},
+			{ID: id(2), Tags: map[string]interface{}{"kind": "prison"}, Geometry: basic.Point{2, 2}},
+		},
+		params: []string{"class"},
+	})
+	provider.Add("db2", &testProvider{
+		layer: testLayer{
+			name:     "areas",
+			geomType: basic.Polygon{},
+			fields:   map[string]string{"class": mvt.FieldTypeString},
+		},
+		features: []mvt.Feature{
+			{ID: id(1), Tags: map[string]interface{}{"class": "park"}, Geometry: basic.Point{3, 3}},
+		},
+		params: []string{"floor", "class"},
+	})
+	provider.Add("file", &testProvider{
+		layer: testLayer{
+			name:     "zones",
+			geomType: basic.MultiPolygon{},
+		},
+		features: []mvt.Feature{
+			{Tags: map[string]interface{}{"name": "zone"}, Geometry: basic.Point{4, 4}},
+		},
+	})
+	provider.Add("empty", &testProvider{
+		layer: testLayer{
+			name:     "areas",
+			geomType: basic.Polygon{},
+		},
+	})
+	provider.Add("native", &testProvider{
+		layer: testLayer{
+			name:     "areas",
+			geomType: basic.Polygon{},
+			native:   true,
+		},
+	})
+	provider.Add("broken", &testProvider{
+		layer: testLayer{name: "areas"},
+		err:   errors.New("connection refused"),
+	})
+}
+
+func TestNewProvider(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		expectErr bool
+		geomType  tegola.Geometry
+		fields    map[string]string
+	}{
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{
+								composite.ConfigKeyProviderLayer: "db1.restricted",
+								composite.ConfigKeyRenameTags:    map[string]interface{}{"kind": "class"},
+								composite.ConfigKeyDropTags:      []interface{}{"internal"},
+							},
+							{
+								composite.ConfigKeyProviderLayer: "db2.areas",
+								composite.ConfigKeyTags:          map[string]interface{}{"source": "db2", "priority": int64(2)},
+							},
+						},
+					},
+				},
+			},
+			geomType: basic.Polygon{},
+			fields: map[string]string{
+				"class":    mvt.FieldTypeString,
+				"source":   mvt.FieldTypeString,
+				"priority": mvt.FieldTypeNumber,
+			},
+		},
+		//	the sources don't agree on the geometry type
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.restricted"},
+							{composite.ConfigKeyProviderLayer: "file.zones"},
+						},
+					},
+				},
+			},
+			fields: map[string]string{"kind": mvt.FieldTypeString, "internal": mvt.FieldTypeNumber},
+		},
+		//	undefined provider
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db3.restricted"},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	undefined layer
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.areas"},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	native layers are encoded by their provider and have no features to merge
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.restricted"},
+							{composite.ConfigKeyProviderLayer: "native.areas"},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	no sources
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{composite.ConfigKeyLayerName: "restricted"},
+				},
+			},
+			expectErr: true,
+		},
+		//	duplicate id namespace
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName:    "restricted",
+						composite.ConfigKeyNamespaceIDs: true,
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.restricted", composite.ConfigKeyIDNamespace: int64(2)},
+							{composite.ConfigKeyProviderLayer: "db2.areas"},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	id namespace out of range
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.restricted", composite.ConfigKeyIDNamespace: int64(composite.MaxIDNamespace + 1)},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		p, err := composite.NewProvider(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("[%v] expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+
+		layers, err := p.Layers()
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+		if len(layers) != 1 {
+			t.Errorf("[%v] expected 1 layer got %v", i, len(layers))
+			continue
+		}
+
+		if reflect.TypeOf(layers[0].GeomType()) != reflect.TypeOf(tc.geomType) {
+			t.Errorf("[%v] geometry type, expected %T got %T", i, tc.geomType, layers[0].GeomType())
+		}
+		if layers[0].SRID() != tegola.WebMercator {
+			t.Errorf("[%v] srid, expected %v got %v", i, tegola.WebMercator, layers[0].SRID())
+		}
+		if fields := layers[0].(mvt.LayerFields).Fields(); !reflect.DeepEqual(fields, tc.fields) {
+			t.Errorf("[%v] fields, expected %v got %v", i, tc.fields, fields)
+		}
+	}
+}
+
+func TestMVTLayer(t *testing.T) {
+	type feature struct {
+		id   *uint64
+		tags map[string]interface{}
+	}
+
+	testcases := []struct {
+		config    map[string]interface{}
+		dtags     map[string]interface{}
+		expected  []feature
+		expectErr bool
+	}{
+		//	features with the same id are dropped as duplicates
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{
+								composite.ConfigKeyProviderLayer: "db1.restricted",
+								composite.ConfigKeyRenameTags:    map[string]interface{}{"kind": "class"},
+								composite.ConfigKeyDropTags:      []interface{}{"internal"},
+							},
+							{
+								composite.ConfigKeyProviderLayer: "db2.areas",
+								composite.ConfigKeyTags:          map[string]interface{}{"source": "db2"},
+							},
+							{composite.ConfigKeyProviderLayer: "file.zones"},
+						},
+					},
+				},
+			},
+			dtags: map[string]interface{}{"class": "unknown", "layer": "restricted"},
+			expected: []feature{
+				{id: id(1), tags: map[string]interface{}{"class": "military", "layer": "restricted"}},
+				{id: id(2), tags: map[string]interface{}{"class": "prison", "layer": "restricted"}},
+				{tags: map[string]interface{}{"class": "unknown", "layer": "restricted", "name": "zone"}},
+			},
+		},
+		//	namespaced ids
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName:    "restricted",
+						composite.ConfigKeyNamespaceIDs: true,
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.restricted"},
+							{composite.ConfigKeyProviderLayer: "db2.areas", composite.ConfigKeyIDNamespace: int64(5)},
+							{composite.ConfigKeyProviderLayer: "file.zones"},
+						},
+					},
+				},
+			},
+			expected: []feature{
+				{id: id(1<<48 | 1), tags: map[string]interface{}{"kind": "military", "internal": int64(7)}},
+				{id: id(1<<48 | 2), tags: map[string]interface{}{"kind": "prison"}},
+				{id: id(5<<48 | 1), tags: map[string]interface{}{"class": "park"}},
+				{tags: map[string]interface{}{"name": "zone"}},
+			},
+		},
+		//	a source without a layer for the tile
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "empty.areas"},
+							{composite.ConfigKeyProviderLayer: "db2.areas"},
+						},
+					},
+				},
+			},
+			expected: []feature{
+				{id: id(1), tags: map[string]interface{}{"class": "park"}},
+			},
+		},
+		//	a failed source fails the layer
+		{
+			config: map[string]interface{}{
+				composite.ConfigKeyLayers: []map[string]interface{}{
+					{
+						composite.ConfigKeyLayerName: "restricted",
+						composite.ConfigKeySources: []map[string]interface{}{
+							{composite.ConfigKeyProviderLayer: "db1.restricted"},
+							{composite.ConfigKeyProviderLayer: "broken.areas"},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		p, err := composite.NewProvider(tc.config)
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+
+		layer, err := p.MVTLayer(context.Background(), "restricted", tegola.Tile{Z: 1, X: 1, Y: 1}, tc.dtags)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("[%v] expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+
+		var output []feature
+		for _, f := range layer.Features() {
+			output = append(output, feature{id: f.ID, tags: f.Tags})
+		}
+
+		if len(output) != len(tc.expected) {
+			t.Errorf("[%v] expected %v features got %v", i, len(tc.expected), len(output))
+			continue
+		}
+		for j := range output {
+			if !reflect.DeepEqual(output[j].id, tc.expected[j].id) {
+				t.Errorf("[%v] feature (%v) id, expected %v got %v", i, j, tc.expected[j].id, output[j].id)
+			}
+			if !reflect.DeepEqual(output[j].tags, tc.expected[j].tags) {
+				t.Errorf("[%v] feature (%v) tags, expected %v got %v", i, j, tc.expected[j].tags, output[j].tags)
+			}
+		}
+	}
+}
+
+func TestLayerParams(t *testing.T) {
+	p, err := composite.NewProvider(map[string]interface{}{
+		composite.ConfigKeyLayers: []map[string]interface{}{
+			{
+				composite.ConfigKeyLayerName: "restricted",
+				composite.ConfigKeySources: []map[string]interface{}{
+					{composite.ConfigKeyProviderLayer: "db1.restricted"},
+					{composite.ConfigKeyProviderLayer: "db2.areas"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := p.(mvt.ParamsProvider).LayerParams("restricted")
+	sort.Strings(params)
+
+	if expected := []string{"class", "floor"}; !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v got %v", expected, params)
+	}
+}
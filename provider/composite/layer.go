@@ -0,0 +1,132 @@
+package composite
+
+import (
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
+)
+
+//	the bits of a feature id below the id namespace
+const idMask = 1<<(64-IDNamespaceBits) - 1
+
+// Layer is the union of the layers of other providers.
+type Layer struct {
+	// The Name of the layer
+	name string
+	// The provider layers the features are read from, in the order they are merged
+	sources []source
+	// GeomType is the type of geometry of the sources. nil if the sources don't agree.
+	geomType tegola.Geometry
+	// The SRID of the features. The providers return features in webmercator.
+	srid int
+	// The attribute names of the sources, after normalization, mapped to their type
+	fields map[string]string
+}
+
+func (l Layer) Name() string {
+	return l.name
+}
+
+func (l Layer) GeomType() tegola.Geometry {
+	return l.geomType
+}
+
+func (l Layer) SRID() int {
+	return l.srid
+}
+
+//	Fields returns the layer's attribute names mapped to their type. nil if none of the sources know their attributes.
+func (l Layer) Fields() map[string]string {
+	return l.fields
+}
+
+//	source is a provider layer merged into a composite layer
+type source struct {
+	// The provider layer in the provider.layer format
+	providerLayer string
+	// The name of the layer in the provider
+	layerName string
+	provider  mvt.Provider
+	info      mvt.LayerInfo
+	// Prefixed to the feature ids. 0 leaves the ids as they are.
+	idNamespace uint64
+	// Tags to rename keyed by their name in the source
+	renameTags map[string]string
+	// Tags of the source to drop
+	dropTags map[string]bool
+	// Tags added to every feature of the source
+	tags map[string]interface{}
+}
+
+//	normalize returns the tags of a feature with the source's tags dropped, renamed and added.
+//	Renamed tags replace the feature's tags with the same name, and the added tags replace both.
+func (s source) normalize(tags map[string]interface{}) map[string]interface{} {
+	if s.dropTags == nil && s.renameTags == nil && s.tags == nil {
+		return tags
+	}
+
+	normalized := make(map[string]interface{}, len(tags)+len(s.tags))
+	for k, v := range tags {
+		if s.dropTags[k] {
+			continue
+		}
+		if _, ok := s.renameTags[k]; ok {
+			continue
+		}
+		normalized[k] = v
+	}
+	for from, to := range s.renameTags {
+		if v, ok := tags[from]; ok && !s.dropTags[from] {
+			normalized[to] = v
+		}
+	}
+	for k, v := range s.tags {
+		normalized[k] = v
+	}
+
+	return normalized
+}
+
+//	namespaceID prefixes the feature id with the source's id namespace. Ids that don't fit
+//	below the namespace are removed as they could collide with the ids of other sources.
+func (s source) namespaceID(id *uint64) *uint64 {
+	if id == nil || s.idNamespace == 0 {
+		return id
+	}
+	if *id&^idMask != 0 {
+		return nil
+	}
+
+	nsid := s.idNamespace<<(64-IDNamespaceBits) | *id
+	return &nsid
+}
+
+//	fields returns the attribute schema of the source's layer after normalization. nil if it's not known.
+func (s source) fields() map[string]string {
+	lf, ok := s.info.(mvt.LayerFields)
+	if !ok {
+		return nil
+	}
+
+	fields := map[string]string{}
+	for name, typ := range lf.Fields() {
+		if s.dropTags[name] {
+			continue
+		}
+		if to, ok := s.renameTags[name]; ok {
+			name = to
+		}
+		fields[name] = typ
+	}
+	for k, v := range s.tags {
+		switch v.(type) {
+		case bool:
+			fields[k] = mvt.FieldTypeBoolean
+		case string:
+			fields[k] = mvt.FieldTypeString
+		default:
+			fields[k] = mvt.FieldTypeNumber
+		}
+	}
+
+	return fields
+}
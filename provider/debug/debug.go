@@ -9,8 +9,10 @@ import (
 
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/maths/makevalid"
 	"github.com/airmap/tegola/mvt"
 	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
 )
 
 const Name = "debug"
@@ -18,19 +20,62 @@ const Name = "debug"
 const (
 	LayerDebugTileOutline = "debug-tile-outline"
 	LayerDebugTileCenter  = "debug-tile-center"
+	//	the boundary geometries are clipped to when encoded
+	LayerDebugTileBuffer = "debug-tile-buffer"
+	//	a grid of the tile's coordinate space
+	LayerDebugTileGrid = "debug-tile-grid"
+	//	a point in the middle of the tile with the stats of the tile's other layers (see TileStats)
+	LayerDebugTileStats = "debug-tile-stats"
+)
+
+//	Layers are the names of the debug layers
+var Layers = []string{
+	LayerDebugTileOutline,
+	LayerDebugTileCenter,
+	LayerDebugTileBuffer,
+	LayerDebugTileGrid,
+	LayerDebugTileStats,
+}
+
+const (
+	//	the spacing of the grid lines in tile coordinates. 256 is a 16 by 16 grid for the default extent of 4096
+	DefaultGridSpacing = 256
+)
+
+const (
+	ConfigKeyGridSpacing = "grid_spacing"
 )
 
 func init() {
 	provider.Register(Name, NewProvider)
 }
 
-//	NewProvider Setups a debug provider. there are not currently any config params supported
+//	NewProvider Setups a debug provider. The following config params are supported:
+//
+//		grid_spacing (int) — Optional. The spacing of the debug-tile-grid lines in tile coordinates. Defaults to 256.
+//
 func NewProvider(config map[string]interface{}) (mvt.Provider, error) {
-	return &Provider{}, nil
+	c := dict.M(config)
+
+	spacing := int64(DefaultGridSpacing)
+	spacing, err := c.Int64(ConfigKeyGridSpacing, &spacing)
+	if err != nil {
+		return nil, err
+	}
+	if spacing <= 0 {
+		return nil, fmt.Errorf("%v (%v) must be greater than 0", ConfigKeyGridSpacing, spacing)
+	}
+
+	return &Provider{
+		gridSpacing: int(spacing),
+	}, nil
 }
 
 // Provider provides the debug provider
-type Provider struct{}
+type Provider struct {
+	//	the spacing of the grid lines in tile coordinates
+	gridSpacing int
+}
 
 func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (*mvt.Layer, error) {
 	var layer mvt.Layer
@@ -42,7 +87,7 @@ func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.T
 	ylen := ext.Maxy - ext.Miny
 
 	switch layerName {
-	case "debug-tile-outline":
+	case LayerDebugTileOutline:
 		//	debug outlines
 		layer = mvt.Layer{
 			Name:         LayerDebugTileOutline,
@@ -61,7 +106,7 @@ func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.T
 		}
 		layer.AddFeatures(debugOutline)
 
-	case "debug-tile-center":
+	case LayerDebugTileCenter:
 		//	debug center points
 		layer = mvt.Layer{
 			Name:         LayerDebugTileCenter,
@@ -78,6 +123,91 @@ func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.T
 			},
 		}
 		layer.AddFeatures(debugCenter)
+
+	case LayerDebugTileBuffer:
+		layer = mvt.Layer{
+			Name:         LayerDebugTileBuffer,
+			DontSimplify: true,
+		}
+
+		//	twice the buffer in webmercator. the polygon is clipped by the encoder, so its ring is the
+		//	boundary the tile's geometries are clipped to, whatever the rounding of the tile coordinates.
+		//	the tile's y axis is flipped, Miny is the top of the tile
+		buffer := 2 * xlen * makevalid.TileBuffer / tegola.DefaultExtent
+		minx, top := ext.Minx-buffer, ext.Miny+buffer
+		maxx, bottom := ext.Maxx+buffer, ext.Maxy-buffer
+
+		layer.AddFeatures(mvt.Feature{
+			Tags: map[string]interface{}{
+				"type":   "debug_buffer",
+				"buffer": int64(makevalid.TileBuffer),
+			},
+			//	lines are clipped inside the buffer by the encoder, polygons are clipped to it
+			Geometry: &basic.Polygon{
+				basic.Line{
+					basic.Point{minx, top},
+					basic.Point{maxx, top},
+					basic.Point{maxx, bottom},
+					basic.Point{minx, bottom},
+				},
+			},
+		})
+
+	case LayerDebugTileGrid:
+		layer = mvt.Layer{
+			Name:         LayerDebugTileGrid,
+			DontSimplify: true,
+		}
+
+		//	the spacing in webmercator
+		xstep := xlen * float64(p.gridSpacing) / tegola.DefaultExtent
+		ystep := ylen * float64(p.gridSpacing) / tegola.DefaultExtent
+
+		//	the tile edges are drawn by the outline layer
+		var lines basic.MultiLine
+		for i := 1; i*p.gridSpacing < tegola.DefaultExtent; i++ {
+			x := ext.Minx + float64(i)*xstep
+			y := ext.Miny + float64(i)*ystep
+
+			lines = append(lines,
+				basic.Line{basic.Point{x, ext.Miny}, basic.Point{x, ext.Maxy}},
+				basic.Line{basic.Point{ext.Minx, y}, basic.Point{ext.Maxx, y}},
+			)
+		}
+		if len(lines) == 0 {
+			break
+		}
+
+		layer.AddFeatures(mvt.Feature{
+			Tags: map[string]interface{}{
+				"type":    "debug_grid",
+				"spacing": int64(p.gridSpacing),
+			},
+			Geometry: lines,
+		})
+
+	case LayerDebugTileStats:
+		layer = mvt.Layer{
+			Name:         LayerDebugTileStats,
+			DontSimplify: true,
+		}
+
+		tags := map[string]interface{}{
+			"type": "debug_stats",
+			"zxy":  fmt.Sprintf("Z:%v, X:%v, Y:%v", tile.Z, tile.X, tile.Y),
+		}
+		//	the stats are only known when the layer is encoded with the rest of the tile's layers
+		if stats, ok := TileStatsFromContext(ctx); ok {
+			stats.setTags(tags)
+		}
+
+		layer.AddFeatures(mvt.Feature{
+			Tags: tags,
+			Geometry: &basic.Point{ //	middle of the tile
+				ext.Minx + (xlen / 2),
+				ext.Miny + (ylen / 2),
+			},
+		})
 	}
 
 	return &layer, nil
@@ -87,12 +217,27 @@ func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.T
 func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
 	layers := []Layer{
 		{
-			name:     "debug-tile-outline",
+			name:     LayerDebugTileOutline,
 			geomType: basic.Line{},
 			srid:     tegola.WebMercator,
 		},
 		{
-			name:     "debug-tile-center",
+			name:     LayerDebugTileCenter,
+			geomType: basic.Point{},
+			srid:     tegola.WebMercator,
+		},
+		{
+			name:     LayerDebugTileBuffer,
+			geomType: basic.Polygon{},
+			srid:     tegola.WebMercator,
+		},
+		{
+			name:     LayerDebugTileGrid,
+			geomType: basic.MultiLine{},
+			srid:     tegola.WebMercator,
+		},
+		{
+			name:     LayerDebugTileStats,
 			geomType: basic.Point{},
 			srid:     tegola.WebMercator,
 		},
@@ -106,3 +251,13 @@ func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
 
 	return ls, nil
 }
+
+//	Close does nothing as the debug provider does not hold any resources
+func (p *Provider) Close() error {
+	return nil
+}
+
+//	Health always returns nil as the debug provider generates its features
+func (p *Provider) Health(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,66 @@
+package debug_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/maths/makevalid"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/provider/debug"
+)
+
+func TestTileBuffer(t *testing.T) {
+	p, err := debug.NewProvider(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//	the ring of the buffer in tile coordinates, clockwise from the top left corner
+	min, max := float64(-makevalid.TileBuffer), float64(tegola.DefaultExtent+makevalid.TileBuffer)
+	expected := basic.Polygon{
+		basic.Line{{min, min}, {max, min}, {max, max}, {min, max}},
+	}
+
+	testcases := []tegola.Tile{
+		{Z: 0, X: 0, Y: 0},
+		{Z: 3, X: 2, Y: 3},
+		{Z: 14, X: 9268, Y: 6323},
+		{Z: 20, X: 593621, Y: 404689},
+	}
+
+	for i, tile := range testcases {
+		layer, err := p.MVTLayer(context.Background(), debug.LayerDebugTileBuffer, tile, nil)
+		if err != nil {
+			t.Fatalf("[%v] unexpected error: %v", i, err)
+		}
+
+		var mvtTile mvt.Tile
+		if err := mvtTile.AddLayers(layer); err != nil {
+			t.Fatalf("[%v] unexpected error: %v", i, err)
+		}
+		data, err := mvtTile.Encode(context.Background(), tile.BoundingBox())
+		if err != nil {
+			t.Fatalf("[%v] unexpected error: %v", i, err)
+		}
+
+		decoded, err := mvt.Decode(data)
+		if err != nil {
+			t.Fatalf("[%v] unexpected error: %v", i, err)
+		}
+		layers := decoded.Layers()
+		if len(layers) != 1 || len(layers[0].Features()) != 1 {
+			t.Fatalf("[%v] expected 1 layer with 1 feature, got %v", i, layers)
+		}
+
+		f := layers[0].Features()[0]
+		if !reflect.DeepEqual(f.Geometry, expected) {
+			t.Errorf("[%v] geometry, expected %v got %v", i, expected.GoString(), f.Geometry.(basic.Polygon).GoString())
+		}
+		if f.Tags["buffer"] != int64(makevalid.TileBuffer) {
+			t.Errorf("[%v] buffer tag, expected %v got %v", i, makevalid.TileBuffer, f.Tags["buffer"])
+		}
+	}
+}
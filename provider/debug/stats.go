@@ -0,0 +1,55 @@
+package debug
+
+import (
+	"context"
+	"time"
+)
+
+//	TileStats are the stats of a tile's layers shown by the debug-tile-stats layer
+type TileStats struct {
+	Layers []LayerStats
+	//	the time spent fetching the tile's layers from the providers
+	FetchTime time.Duration
+	//	the time spent encoding the tile's layers
+	EncodeTime time.Duration
+}
+
+//	LayerStats are the stats of an encoded layer
+type LayerStats struct {
+	Name string
+	//	the number of features returned by the provider
+	Fetched int
+	//	the number of features left after the layer was encoded (i.e. after clipping)
+	Features int
+	//	the size of the encoded layer
+	Bytes int
+}
+
+//	setTags adds the stats to the tags of a debug-tile-stats feature
+func (s TileStats) setTags(tags map[string]interface{}) {
+	var bytes int64
+	for _, l := range s.Layers {
+		tags[l.Name+".fetched"] = int64(l.Fetched)
+		tags[l.Name+".features"] = int64(l.Features)
+		tags[l.Name+".bytes"] = int64(l.Bytes)
+		bytes += int64(l.Bytes)
+	}
+
+	tags["bytes"] = bytes
+	tags["fetch_ms"] = s.FetchTime.Seconds() * 1000
+	tags["encode_ms"] = s.EncodeTime.Seconds() * 1000
+}
+
+type tileStatsKey struct{}
+
+//	ContextWithTileStats returns a copy of the context carrying the stats of the tile's
+//	other layers down to the debug-tile-stats MVTLayer call.
+func ContextWithTileStats(ctx context.Context, stats TileStats) context.Context {
+	return context.WithValue(ctx, tileStatsKey{}, stats)
+}
+
+//	TileStatsFromContext returns the tile stats carried by the context, if any
+func TileStatsFromContext(ctx context.Context) (TileStats, bool) {
+	stats, ok := ctx.Value(tileStatsKey{}).(TileStats)
+	return stats, ok
+}
@@ -0,0 +1,292 @@
+//	Package geojson provides a data provider which loads GeoJSON FeatureCollections
+//	or GeoJSON text sequences (https://tools.ietf.org/html/rfc8142) from disk into memory.
+//	The features of each layer are indexed with an R-tree for tile queries.
+package geojson
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/container/rtree"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
+)
+
+const Name = "geojson"
+
+// Provider provides the GeoJSON data provider.
+type Provider struct {
+	// map of layer name and corrosponding features
+	layers     map[string]Layer
+	firstlayer string
+}
+
+const (
+	DefaultSRID = tegola.WGS84
+)
+
+const (
+	ConfigKeyLayers      = "layers"
+	ConfigKeyLayerName   = "name"
+	ConfigKeyFilePath    = "filepath"
+	ConfigKeyFields      = "fields"
+	ConfigKeyGeomIDField = "id_fieldname"
+	ConfigKeySRID        = "srid"
+)
+
+func init() {
+	provider.Register(Name, NewProvider)
+}
+
+//	NewProvider Setups and returns a new GeoJSON provider or an error; if something
+//	is wrong. The function will validate the config and load the features of every
+//	layer into memory. This means that the Provider expects the following fields to
+//	exists in the provided map[string]interface{} map:
+//
+//		layers (map[string]struct{})  — This is map of layers keyed by the layer name.
+//     		filepath (string) — the path to the GeoJSON FeatureCollection or GeoJSON text sequence file.
+//     		fields ([]string) — This is a list of feature properties to encode as tags, if this is nil or empty we will use all properties.
+//     		id_fieldname (string) — The property to use as the feature id. Every feature must have an unsigned integer value.
+//     			Defaults to the feature's id member. Features without an unsigned integer id member are given their
+//     			position in the file (starting at 1). Feature ids must be unique.
+//     		srid (int) — The srid of the geometries in the file. Defaults to 4326. Only 4326 and 3857 are supported.
+//
+func NewProvider(config map[string]interface{}) (mvt.Provider, error) {
+	// Validate the config to make sure it has the values I care about and the types for those values.
+	c := dict.M(config)
+
+	layers, ok := c[ConfigKeyLayers].([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected %v to be a []map[string]interface{}", ConfigKeyLayers)
+	}
+
+	p := Provider{
+		layers: make(map[string]Layer),
+	}
+	lyrsSeen := make(map[string]int)
+
+	for i, v := range layers {
+		vc := dict.M(v)
+
+		lname, err := vc.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if j, ok := lyrsSeen[lname]; ok {
+			return nil, fmt.Errorf("%v layer name is duplicated in both layer %v and layer %v", lname, i, j)
+		}
+		lyrsSeen[lname] = i
+		if i == 0 {
+			p.firstlayer = lname
+		}
+
+		fpath, err := vc.String(ConfigKeyFilePath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+		if fpath == "" {
+			return nil, fmt.Errorf("For layer (%v) %v : %v value is required.", i, lname, ConfigKeyFilePath)
+		}
+
+		fields, err := vc.StringSlice(ConfigKeyFields)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v %v field had the following error: %v", i, lname, ConfigKeyFields, err)
+		}
+
+		var idfld string
+		idfld, err = vc.String(ConfigKeyGeomIDField, &idfld)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		srid := int64(DefaultSRID)
+		if srid, err = vc.Int64(ConfigKeySRID, &srid); err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+		if srid != tegola.WGS84 && srid != tegola.WebMercator {
+			return nil, fmt.Errorf("For layer (%v) %v : unsupported %v (%v). Only %v and %v are supported.", i, lname, ConfigKeySRID, srid, tegola.WGS84, tegola.WebMercator)
+		}
+
+		l := Layer{
+			name:     lname,
+			filepath: fpath,
+			idField:  idfld,
+			srid:     int(srid),
+		}
+
+		if err = l.load(fields); err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		p.layers[lname] = l
+	}
+
+	return &p, nil
+}
+
+//	load reads the layer's file, transforms the features into webmercator and builds
+//	the spatial index. If fields is not empty only those properties are kept as tags.
+func (l *Layer) load(fields []string) error {
+	data, err := ioutil.ReadFile(l.filepath)
+	if err != nil {
+		return fmt.Errorf("unable to read GeoJSON file (%v): %v", l.filepath, err)
+	}
+
+	rawFeatures, err := decodeFeatures(data)
+	if err != nil {
+		return fmt.Errorf("unable to decode GeoJSON file (%v): %v", l.filepath, err)
+	}
+
+	var keep map[string]bool
+	if len(fields) > 0 {
+		keep = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			keep[f] = true
+		}
+	}
+
+	var bboxes []rtree.BBox
+	mixed := false
+	//	the position of the features keyed by id
+	ids := map[uint64]int{}
+
+	for i, rf := range rawFeatures {
+		//	features without a geometry have nothing to render
+		if len(rf.Geometry) == 0 || string(rf.Geometry) == "null" {
+			continue
+		}
+
+		geom, err := basic.UnmarshalJSON(rf.Geometry)
+		if err != nil {
+			return fmt.Errorf("unable to decode geometry of feature (%v): %v", i, err)
+		}
+
+		f := feature{
+			id:   uint64(i + 1),
+			tags: map[string]interface{}{},
+		}
+
+		if l.idField != "" {
+			//	the configured id field has to be set on every feature
+			if f.id, err = gId(rf.Properties[l.idField]); err != nil {
+				return fmt.Errorf("feature (%v) has an invalid %v (%v) value (%v): %v", i, ConfigKeyGeomIDField, l.idField, rf.Properties[l.idField], err)
+			}
+		} else if gid, err := gId(rf.ID); err == nil {
+			f.id = gid
+		}
+
+		//	features with the same id would be dropped when the tile is encoded
+		if j, ok := ids[f.id]; ok {
+			return fmt.Errorf("features (%v) and (%v) have the same id (%v)", j, i, f.id)
+		}
+		ids[f.id] = i
+
+		for k, v := range rf.Properties {
+			if v == nil || k == l.idField || (keep != nil && !keep[k]) {
+				continue
+			}
+			value, err := transformVal(v)
+			if err != nil {
+				return fmt.Errorf("unable to convert property (%v) of feature (%v): %v", k, i, err)
+			}
+			f.tags[k] = value
+		}
+
+		if f.geometry, err = toWebMercator(l.srid, geom); err != nil {
+			return fmt.Errorf("unable to transform geometry of feature (%v) to webmercator from SRID (%v): %v", i, l.srid, err)
+		}
+
+		bbox, ok := bounds(f.geometry)
+		if !ok {
+			continue
+		}
+
+		//	track the geometry type of the layer. mixed geometries result in a nil type
+		switch {
+		case mixed:
+		case l.geomType == nil:
+			l.geomType = geomType(geom)
+		case reflect.TypeOf(l.geomType) != reflect.TypeOf(geom):
+			l.geomType = nil
+			mixed = true
+		}
+
+		l.features = append(l.features, f)
+		bboxes = append(bboxes, bbox)
+	}
+
+	l.index = rtree.New(bboxes)
+
+	return nil
+}
+
+func (p *Provider) Layer(name string) (Layer, bool) {
+	if name == "" {
+		return p.layers[p.firstlayer], true
+	}
+	plyr, ok := p.layers[name]
+	return plyr, ok
+}
+
+func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
+	var ls []mvt.LayerInfo
+
+	for i := range p.layers {
+		ls = append(ls, p.layers[i])
+	}
+
+	return ls, nil
+}
+
+func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (layer *mvt.Layer, err error) {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return nil, fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	layer = &mvt.Layer{
+		Name: layerName,
+	}
+
+	//	the tile's y axis is flipped so the min and max values are swapped
+	textent := tile.BoundingBox()
+	bbox := rtree.BBox{textent.Minx, textent.Maxy, textent.Maxx, textent.Miny}
+
+	plyr.index.Search(bbox, func(item int) bool {
+		// do a quick context check:
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+
+		f := plyr.features[item]
+
+		//	copy our default tags to a tags map
+		tags := map[string]interface{}{}
+		for k, v := range dtags {
+			tags[k] = v
+		}
+
+		//	add feature tags to our map
+		for k := range f.tags {
+			tags[k] = f.tags[k]
+		}
+
+		gid := f.id
+
+		// Add features to Layer
+		layer.AddFeatures(mvt.Feature{
+			ID:       &gid,
+			Tags:     tags,
+			Geometry: f.geometry,
+		})
+
+		return true
+	})
+
+	return layer, err
+}
@@ -0,0 +1,229 @@
+package geojson_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/provider/geojson"
+)
+
+//	the test files. places is a GeoJSON text sequence using both record separators and newline delimiters
+const (
+	testRoads  = "testfiles/roads.geojson"
+	testPlaces = "testfiles/places.geojsonl"
+)
+
+func TestNewProvider(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		expectErr bool
+		layers    map[string]tegola.Geometry
+	}{
+		{
+			config: map[string]interface{}{
+				geojson.ConfigKeyLayers: []map[string]interface{}{
+					{
+						geojson.ConfigKeyLayerName: "roads",
+						geojson.ConfigKeyFilePath:  testRoads,
+					},
+					{
+						geojson.ConfigKeyLayerName: "places",
+						geojson.ConfigKeyFilePath:  testPlaces,
+					},
+				},
+			},
+			layers: map[string]tegola.Geometry{
+				"roads": basic.Line{},
+				//	mixed geometries
+				"places": nil,
+			},
+		},
+		//	missing file
+		{
+			config: map[string]interface{}{
+				geojson.ConfigKeyLayers: []map[string]interface{}{
+					{
+						geojson.ConfigKeyLayerName: "roads",
+						geojson.ConfigKeyFilePath:  "testfiles/missing.geojson",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	missing id field
+		{
+			config: map[string]interface{}{
+				geojson.ConfigKeyLayers: []map[string]interface{}{
+					{
+						geojson.ConfigKeyLayerName:   "roads",
+						geojson.ConfigKeyFilePath:    testRoads,
+						geojson.ConfigKeyGeomIDField: "osm_id",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	id field which is not numeric
+		{
+			config: map[string]interface{}{
+				geojson.ConfigKeyLayers: []map[string]interface{}{
+					{
+						geojson.ConfigKeyLayerName:   "places",
+						geojson.ConfigKeyFilePath:    testPlaces,
+						geojson.ConfigKeyGeomIDField: "name",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	the position of the second feature is the id of the first
+		{
+			config: map[string]interface{}{
+				geojson.ConfigKeyLayers: []map[string]interface{}{
+					{
+						geojson.ConfigKeyLayerName: "pois",
+						geojson.ConfigKeyFilePath:  "testfiles/duplicate_ids.geojson",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	unsupported srid
+		{
+			config: map[string]interface{}{
+				geojson.ConfigKeyLayers: []map[string]interface{}{
+					{
+						geojson.ConfigKeyLayerName: "roads",
+						geojson.ConfigKeyFilePath:  testRoads,
+						geojson.ConfigKeySRID:      2100,
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		p, err := geojson.NewProvider(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		layers, err := p.Layers()
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if len(layers) != len(tc.layers) {
+			t.Errorf("testcase (%v) failed. expected (%v) layers, got (%v)", i, len(tc.layers), len(layers))
+			continue
+		}
+
+		for _, l := range layers {
+			if reflect.TypeOf(l.GeomType()) != reflect.TypeOf(tc.layers[l.Name()]) {
+				t.Errorf("testcase (%v) failed. layer (%v) expected geom type (%T) got (%T)", i, l.Name(), tc.layers[l.Name()], l.GeomType())
+			}
+			if l.SRID() != tegola.WGS84 {
+				t.Errorf("testcase (%v) failed. layer (%v) expected srid (%v) got (%v)", i, l.Name(), tegola.WGS84, l.SRID())
+			}
+		}
+	}
+}
+
+func TestMVTLayer(t *testing.T) {
+	p, err := geojson.NewProvider(map[string]interface{}{
+		geojson.ConfigKeyLayers: []map[string]interface{}{
+			{
+				geojson.ConfigKeyLayerName: "roads",
+				geojson.ConfigKeyFilePath:  testRoads,
+			},
+			{
+				geojson.ConfigKeyLayerName:   "places",
+				geojson.ConfigKeyFilePath:    testPlaces,
+				geojson.ConfigKeyGeomIDField: "osm_id",
+			},
+			{
+				geojson.ConfigKeyLayerName: "road_names",
+				geojson.ConfigKeyFilePath:  testRoads,
+				geojson.ConfigKeyFields:    []string{"name"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create provider: %v", err)
+	}
+
+	testcases := []struct {
+		layerName    string
+		tile         tegola.Tile
+		expectedTags map[uint64]map[string]interface{}
+	}{
+		{
+			layerName: "roads",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				1: {"name": "Ermou", "lanes": int64(2), "class": "road"},
+				2: {"name": "Athinas", "lanes": int64(4), "width": 12.5, "class": "road"},
+			},
+		},
+		{
+			layerName: "roads",
+			tile:      tegola.Tile{Z: 10, X: 312, Y: 601},
+			expectedTags: map[uint64]map[string]interface{}{
+				3: {"name": "Avenida Brasil", "lanes": int64(6), "oneway": true, "class": "road"},
+			},
+		},
+		{
+			layerName:    "roads",
+			tile:         tegola.Tile{Z: 10, X: 0, Y: 0},
+			expectedTags: map[uint64]map[string]interface{}{},
+		},
+		{
+			layerName: "places",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				10: {"name": "Acropolis", "tags": `{"tourism":"attraction"}`, "class": "road"},
+				11: {"name": "Plaka", "class": "road"},
+			},
+		},
+		{
+			layerName: "road_names",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				1: {"name": "Ermou", "class": "road"},
+				2: {"name": "Athinas", "class": "road"},
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		l, err := p.MVTLayer(context.Background(), tc.layerName, tc.tile, map[string]interface{}{"class": "road"})
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		features := l.Features()
+		if len(features) != len(tc.expectedTags) {
+			t.Errorf("testcase (%v) failed. expected (%v) features, got (%v)", i, len(tc.expectedTags), len(features))
+			continue
+		}
+
+		for _, f := range features {
+			if !reflect.DeepEqual(tc.expectedTags[*f.ID], f.Tags) {
+				t.Errorf("testcase (%v) failed. feature (%v) expected tags (%v) got (%v)", i, *f.ID, tc.expectedTags[*f.ID], f.Tags)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package geojson
+
+import (
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/container/rtree"
+)
+
+//	feature is a GeoJSON feature which has been transformed into webmercator
+type feature struct {
+	id       uint64
+	geometry tegola.Geometry
+	tags     map[string]interface{}
+}
+
+// Layer holds the features of a GeoJSON file along with their spatial index.
+type Layer struct {
+	// The Name of the layer
+	name string
+	// The path to the GeoJSON file
+	filepath string
+	// The ID field name. When empty the feature's id member is used.
+	idField string
+	// GeomType is the the type of geometry in the file. nil if the file contains mixed geometries.
+	geomType tegola.Geometry
+	// The SRID that the data in the file is stored in.
+	srid int
+	// The features of the file in webmercator
+	features []feature
+	// The spatial index of the features. The items are indexes into the features slice.
+	index *rtree.Tree
+}
+
+func (l Layer) Name() string {
+	return l.name
+}
+
+func (l Layer) GeomType() tegola.Geometry {
+	return l.geomType
+}
+
+func (l Layer) SRID() int {
+	return l.srid
+}
+
+func (l Layer) IDFieldName() string {
+	return l.idField
+}
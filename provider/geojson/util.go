@@ -0,0 +1,168 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/container/rtree"
+)
+
+//	the ASCII record separator which prefixes each record of a GeoJSON text sequence (RFC 8142)
+const recordSeparator = 0x1E
+
+//	rawFeature is used to decode GeoJSON objects. A FeatureCollection will populate
+//	the Features field, a Feature the ID, Geometry and Properties fields.
+type rawFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id"`
+	Geometry   json.RawMessage        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+	Features   []rawFeature           `json:"features"`
+}
+
+//	decodeFeatures reads the features of a GeoJSON FeatureCollection or a GeoJSON
+//	text sequence (either newline delimited or record separator prefixed)
+func decodeFeatures(data []byte) ([]rawFeature, error) {
+	//	record separators are not valid JSON but are valid whitespace between the records
+	data = bytes.Replace(data, []byte{recordSeparator}, []byte{'\n'}, -1)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	//	keep numbers as json.Number so integer properties are not converted to float64
+	dec.UseNumber()
+
+	var features []rawFeature
+	for {
+		var f rawFeature
+		err := dec.Decode(&f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.Type {
+		case "FeatureCollection":
+			features = append(features, f.Features...)
+		case "Feature":
+			features = append(features, f)
+		default:
+			return nil, fmt.Errorf("unsupported GeoJSON object type (%v)", f.Type)
+		}
+	}
+
+	return features, nil
+}
+
+//	toWebMercator transforms the geometry into webmercator. Unlike basic.ToWebMercator
+//	geometry collections are supported.
+func toWebMercator(srid int, geom tegola.Geometry) (tegola.Geometry, error) {
+	col, ok := geom.(tegola.Collection)
+	if !ok {
+		g, err := basic.ToWebMercator(srid, geom)
+		if err != nil {
+			return nil, err
+		}
+		return g.Geometry, nil
+	}
+
+	var c basic.Collection
+	for _, sg := range col.Geometries() {
+		g, err := toWebMercator(srid, sg)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, g.(basic.Geometry))
+	}
+
+	return c, nil
+}
+
+//	bounds returns the bounding box of the geometry. ok is false for empty geometries.
+func bounds(geom tegola.Geometry) (bbox rtree.BBox, ok bool) {
+	bbox = rtree.BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+	var walk func(g tegola.Geometry)
+	addPoints := func(pts []tegola.Point) {
+		for _, pt := range pts {
+			walk(pt)
+		}
+	}
+
+	walk = func(g tegola.Geometry) {
+		switch geo := g.(type) {
+		case tegola.Point:
+			bbox = bbox.Extend(rtree.BBox{geo.X(), geo.Y(), geo.X(), geo.Y()})
+			ok = true
+		case tegola.MultiPoint:
+			addPoints(geo.Points())
+		case tegola.LineString:
+			addPoints(geo.Subpoints())
+		case tegola.MultiLine:
+			for _, l := range geo.Lines() {
+				walk(l)
+			}
+		case tegola.Polygon:
+			//	the exterior ring contains the interior rings
+			if lines := geo.Sublines(); len(lines) > 0 {
+				walk(lines[0])
+			}
+		case tegola.MultiPolygon:
+			for _, p := range geo.Polygons() {
+				walk(p)
+			}
+		case tegola.Collection:
+			for _, sg := range geo.Geometries() {
+				walk(sg)
+			}
+		}
+	}
+	walk(geom)
+
+	return bbox, ok
+}
+
+//	geomType returns an empty geometry of the same type as the provided geometry
+func geomType(geom tegola.Geometry) tegola.Geometry {
+	return reflect.Zero(reflect.TypeOf(geom)).Interface().(tegola.Geometry)
+}
+
+//	transformVal converts GeoJSON property values into values that can be encoded as tags.
+//	Objects and arrays are encoded as JSON strings.
+func transformVal(val interface{}) (interface{}, error) {
+	switch vt := val.(type) {
+	case string, bool:
+		return vt, nil
+	case json.Number:
+		if i, err := vt.Int64(); err == nil {
+			return i, nil
+		}
+		return vt.Float64()
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(vt)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	default:
+		return nil, fmt.Errorf("%T type is not supported", val)
+	}
+}
+
+func gId(v interface{}) (gid uint64, err error) {
+	switch aval := v.(type) {
+	case json.Number:
+		return strconv.ParseUint(aval.String(), 10, 64)
+	case string:
+		return strconv.ParseUint(aval, 10, 64)
+	default:
+		return gid, fmt.Errorf("Unable to convert field into a uint64.")
+	}
+}
@@ -0,0 +1,108 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrInvalidBinaryHeader = errors.New("gpkg: invalid geometry binary header")
+)
+
+//	envelope sizes (in float64s) as defined by the envelope contents indicator code
+//	http://www.geopackage.org/spec/#gpb_format
+var envelopeSizes = [...]int{
+	0: 0, //	no envelope
+	1: 4, //	minx, maxx, miny, maxy
+	2: 6, //	minx, maxx, miny, maxy, minz, maxz
+	3: 6, //	minx, maxx, miny, maxy, minm, maxm
+	4: 8, //	minx, maxx, miny, maxy, minz, maxz, minm, maxm
+}
+
+//	BinaryHeader is the header that precedes the WKB of every GeoPackage geometry blob
+type BinaryHeader struct {
+	magic   [2]byte
+	version uint8
+	flags   uint8
+	srsid   int32
+	//	envelope values in the order minx, maxx, miny, maxy, [minz, maxz], [minm, maxm]
+	envelope []float64
+	//	size of the header in bytes. the WKB starts at this offset.
+	size int
+}
+
+//	NewBinaryHeader decodes the header of a GeoPackage geometry blob
+func NewBinaryHeader(data []byte) (*BinaryHeader, error) {
+	//	magic (2), version (1), flags (1), srs_id (4)
+	if len(data) < 8 {
+		return nil, ErrInvalidBinaryHeader
+	}
+
+	var bh BinaryHeader
+	copy(bh.magic[:], data[0:2])
+	if bh.magic != [2]byte{'G', 'P'} {
+		return nil, ErrInvalidBinaryHeader
+	}
+
+	bh.version = data[2]
+	bh.flags = data[3]
+
+	//	bit 0 of the flags denotes the byte order of the header values
+	var bom binary.ByteOrder = binary.BigEndian
+	if bh.flags&0x01 == 1 {
+		bom = binary.LittleEndian
+	}
+
+	bh.srsid = int32(bom.Uint32(data[4:8]))
+
+	//	bits 1-3 are the envelope contents indicator code
+	code := int((bh.flags >> 1) & 0x07)
+	if code >= len(envelopeSizes) {
+		return nil, fmt.Errorf("gpkg: invalid envelope contents indicator code (%v)", code)
+	}
+
+	bh.size = 8 + envelopeSizes[code]*8
+	if len(data) < bh.size {
+		return nil, ErrInvalidBinaryHeader
+	}
+
+	for i := 0; i < envelopeSizes[code]; i++ {
+		offset := 8 + i*8
+		bh.envelope = append(bh.envelope, math.Float64frombits(bom.Uint64(data[offset:offset+8])))
+	}
+
+	return &bh, nil
+}
+
+//	SRSID is the spatial reference system id of the geometry
+func (bh *BinaryHeader) SRSID() int32 {
+	return bh.srsid
+}
+
+//	Version of the GeoPackage binary format. 0 is version 1.
+func (bh *BinaryHeader) Version() uint8 {
+	return bh.version
+}
+
+//	IsEmpty reports if the empty geometry flag is set
+func (bh *BinaryHeader) IsEmpty() bool {
+	return bh.flags&0x10 != 0
+}
+
+//	IsStandard reports if the geometry is a standard GeoPackage geometry.
+//	extended geometries (user defined types) are not supported.
+func (bh *BinaryHeader) IsStandard() bool {
+	return bh.flags&0x20 == 0
+}
+
+//	Envelope returns the envelope stored in the header, if any
+func (bh *BinaryHeader) Envelope() []float64 {
+	return bh.envelope
+}
+
+//	Size is the length of the header in bytes
+func (bh *BinaryHeader) Size() int {
+	return bh.size
+}
@@ -0,0 +1,499 @@
+//	Package gpkg provides a data provider for reading feature tables
+//	from a local GeoPackage (http://www.geopackage.org/) file.
+package gpkg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
+	"github.com/airmap/tegola/wkb"
+)
+
+const Name = "gpkg"
+
+// Provider provides the GeoPackage data provider.
+type Provider struct {
+	// path to the GeoPackage file
+	filepath string
+	db       *sql.DB
+	// map of layer name and corrosponding sql
+	layers     map[string]Layer
+	firstlayer string
+}
+
+const (
+	// We quote the field and table names to prevent colliding with SQLite keywords.
+	// The rtree index is joined in so the tile bounding box can be checked against it.
+	stdSQL = `SELECT %[1]v FROM "%[2]v" l JOIN "rtree_%[2]v_%[3]v" si ON l."%[4]v" = si.id WHERE ` + bboxToken
+
+	// SQL to get the column names of a table.
+	fldsSQL = `SELECT * FROM "%[1]v" LIMIT 0;`
+
+	// SQL to look up a feature table in the GeoPackage catalog.
+	geomColumnsSQL = `SELECT column_name, geometry_type_name, srs_id FROM gpkg_geometry_columns WHERE table_name = ?;`
+
+	// SQL to check for the rtree index of a feature table.
+	rtreeSQL = `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`
+)
+
+const (
+	DefaultSRID        = tegola.WGS84
+	DefaultIDFieldname = "fid"
+	DefaultGeomField   = "geom"
+)
+
+const (
+	ConfigKeyFilePath    = "filepath"
+	ConfigKeyLayers      = "layers"
+	ConfigKeyLayerName   = "name"
+	ConfigKeyTablename   = "tablename"
+	ConfigKeySQL         = "sql"
+	ConfigKeyFields      = "fields"
+	ConfigKeyGeomField   = "geometry_fieldname"
+	ConfigKeyGeomIDField = "id_fieldname"
+	ConfigKeySRID        = "srid"
+)
+
+func init() {
+	provider.Register(Name, NewProvider)
+}
+
+//	NewProvider Setups and returns a new GeoPackage provider or an error; if something
+//	is wrong. The function will validate that the config object looks good before
+//	trying to open the file. This means that the Provider expects the following
+//	fields to exists in the provided map[string]interface{} map:
+//
+//		filepath (string) — the path to the .gpkg file.
+//		layers (map[string]struct{})  — This is map of layers keyed by the layer name.
+//     		tablename (string) — The feature table to use with the default query. Defaults to the layer name.
+//     		sql (string) — Custom sql to use instead of the tablename. Must contain the !BBOX! token and select the rtree minx, miny, maxx, maxy columns.
+//     		fields ([]string) — This is a list, if this is nil or empty we will get all fields.
+//     		geometry_fieldname (string) — This is the field name of the geometry. For tables it defaults to the gpkg_geometry_columns entry, for sql to 'geom'.
+//     		id_fieldname (string) — This is the field name for the id property, if it's an empty string or nil, it will defaults to 'fid'.
+//     		srid (int) — The srid of the sql layer geometries. Tables use the gpkg_geometry_columns entry.
+//
+func NewProvider(config map[string]interface{}) (prov mvt.Provider, err error) {
+	// Validate the config to make sure it has the values I care about and the types for those values.
+	c := dict.M(config)
+
+	fpath, err := c.String(ConfigKeyFilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if fpath == "" {
+		return nil, fmt.Errorf("%v value is required.", ConfigKeyFilePath)
+	}
+
+	//	sqlite will create a new database if the file does not exist. we don't want that.
+	if _, err := os.Stat(fpath); err != nil {
+		return nil, fmt.Errorf("unable to open GeoPackage (%v): %v", fpath, err)
+	}
+
+	p := Provider{
+		filepath: fpath,
+	}
+
+	if p.db, err = sql.Open("sqlite3", fmt.Sprintf("file:%v?mode=ro", fpath)); err != nil {
+		return nil, fmt.Errorf("Failed while opening GeoPackage (%v): %v", fpath, err)
+	}
+	//	don't leak the database if the layers can't be set up
+	defer func() {
+		if err != nil {
+			p.db.Close()
+		}
+	}()
+
+	layers, ok := c[ConfigKeyLayers].([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected %v to be a []map[string]interface{}", ConfigKeyLayers)
+	}
+
+	lyrs := make(map[string]Layer)
+	lyrsSeen := make(map[string]int)
+
+	for i, v := range layers {
+		vc := dict.M(v)
+
+		lname, err := vc.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if j, ok := lyrsSeen[lname]; ok {
+			return nil, fmt.Errorf("%v layer name is duplicated in both layer %v and layer %v", lname, i, j)
+		}
+		lyrsSeen[lname] = i
+		if i == 0 {
+			p.firstlayer = lname
+		}
+
+		fields, err := vc.StringSlice(ConfigKeyFields)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v %v field had the following error: %v", i, lname, ConfigKeyFields, err)
+		}
+
+		var geomfld string
+		geomfld, err = vc.String(ConfigKeyGeomField, &geomfld)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		idfld := DefaultIDFieldname
+		idfld, err = vc.String(ConfigKeyGeomIDField, &idfld)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		var tblName string
+		tblName, err = vc.String(ConfigKeyTablename, &lname)
+		if err != nil {
+			return nil, fmt.Errorf("for %v layer(%v) %v has an error: %v", i, lname, ConfigKeyTablename, err)
+		}
+
+		var sql string
+		sql, err = vc.String(ConfigKeySQL, &sql)
+		if err != nil {
+			return nil, fmt.Errorf("for %v layer(%v) %v has an error: %v", i, lname, ConfigKeySQL, err)
+		}
+
+		if tblName != lname && sql != "" {
+			log.Printf("Both %v and %v field are specified for layer(%v) %v, using only %[2]v field.", ConfigKeyTablename, ConfigKeySQL, i, lname)
+		}
+
+		l := Layer{
+			name:    lname,
+			idField: idfld,
+		}
+
+		if sql != "" {
+			if geomfld == "" {
+				geomfld = DefaultGeomField
+			}
+			if idfld == geomfld {
+				return nil, fmt.Errorf("For layer (%v) %v: %v (%v) and %v field (%v) is the same!", i, lname, ConfigKeyGeomField, geomfld, ConfigKeyGeomIDField, idfld)
+			}
+			l.geomField = geomfld
+
+			// make sure that the sql has a !BBOX! token
+			if !strings.Contains(sql, bboxToken) {
+				return nil, fmt.Errorf("SQL for layer (%v) %v is missing required token: %v", i, lname, bboxToken)
+			}
+			if !strings.Contains(sql, "*") {
+				if !strings.Contains(sql, geomfld) {
+					return nil, fmt.Errorf("SQL for layer (%v) %v does not contain the geometry field: %v", i, lname, geomfld)
+				}
+				if !strings.Contains(sql, idfld) {
+					return nil, fmt.Errorf("SQL for layer (%v) %v does not contain the id field for the geometry: %v", i, lname, idfld)
+				}
+			}
+			l.sql = sql
+
+			var lsrid int64
+			if lsrid, err = vc.Int64(ConfigKeySRID, &lsrid); err != nil {
+				return nil, err
+			}
+			l.srid = int(lsrid)
+
+			//	sniff the geometry type (and srid if not configured) from the data
+			if err = p.layerGeomType(&l); err != nil {
+				return nil, fmt.Errorf("error fetching geometry type for layer (%v): %v", l.name, err)
+			}
+		} else {
+			//	look up the feature table in the GeoPackage catalog
+			colName, geomTypeName, srid, err := p.geomColumn(tblName)
+			if err != nil {
+				return nil, fmt.Errorf("layer (%v): %v", lname, err)
+			}
+
+			if geomfld == "" {
+				geomfld = colName
+			}
+			if idfld == geomfld {
+				return nil, fmt.Errorf("For layer (%v) %v: %v (%v) and %v field (%v) is the same!", i, lname, ConfigKeyGeomField, geomfld, ConfigKeyGeomIDField, idfld)
+			}
+			l.geomField = geomfld
+			l.srid = int(srid)
+
+			if l.geomType, err = geomTypeFromName(geomTypeName); err != nil {
+				return nil, fmt.Errorf("layer (%v): %v", lname, err)
+			}
+
+			//	confirm the spatial index exists
+			var count int
+			rtree := fmt.Sprintf("rtree_%v_%v", tblName, geomfld)
+			if err = p.db.QueryRow(rtreeSQL, rtree).Scan(&count); err != nil {
+				return nil, fmt.Errorf("error checking for rtree index for layer (%v): %v", lname, err)
+			}
+			if count == 0 {
+				return nil, fmt.Errorf("layer (%v) table (%v) is missing the rtree spatial index (%v)", lname, tblName, rtree)
+			}
+
+			l.sql, err = genSQL(&l, p.db, tblName, fields)
+			if err != nil {
+				return nil, fmt.Errorf("Could not generate sql, for layer(%v): %v", lname, err)
+			}
+		}
+
+		if strings.Contains(os.Getenv("SQL_DEBUG"), "LAYER_SQL") {
+			log.Printf("SQL for Layer(%v):\n%v\n", lname, l.sql)
+		}
+
+		lyrs[lname] = l
+	}
+	p.layers = lyrs
+
+	return &p, nil
+}
+
+//	geomColumn reads the geometry column name, geometry type name and srid of a feature
+//	table from the gpkg_geometry_columns table
+func (p *Provider) geomColumn(tblName string) (colName, geomTypeName string, srid int64, err error) {
+	err = p.db.QueryRow(geomColumnsSQL, tblName).Scan(&colName, &geomTypeName, &srid)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", "", 0, fmt.Errorf("table (%v) is not registered in gpkg_geometry_columns", tblName)
+	case err != nil:
+		return "", "", 0, fmt.Errorf("error reading gpkg_geometry_columns for table (%v): %v", tblName, err)
+	}
+
+	return colName, geomTypeName, srid, nil
+}
+
+//	layerGeomType sets the geomType field on the layer by running the SQL and reading the geometry
+//	of the first row in the result set. If the layer does not have a srid configured, the srid of
+//	the geometry is used.
+func (p *Provider) layerGeomType(l *Layer) error {
+	sniffSRID := l.srid == 0
+
+	//	we don't know the srid of the data yet so the bounding box check is dropped
+	sql := strings.NewReplacer(
+		bboxToken, "1 = 1",
+		zoomToken, "0",
+	).Replace(l.sql)
+
+	//	we only need a single result set to sniff out the geometry type
+	sql = fmt.Sprintf("SELECT * FROM (%v) LIMIT 1", sql)
+
+	rows, err := p.db.Query(sql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		valPtrs := make([]interface{}, len(cols))
+		for i := range vals {
+			valPtrs[i] = &vals[i]
+		}
+
+		if err = rows.Scan(valPtrs...); err != nil {
+			return fmt.Errorf("error running SQL: %v ; %v", sql, err)
+		}
+
+		for i := range cols {
+			if cols[i] != l.geomField {
+				continue
+			}
+
+			geobytes, ok := vals[i].([]byte)
+			if !ok {
+				return fmt.Errorf("Unable to convert geometry field (%v) into bytes.", l.geomField)
+			}
+
+			h, geom, err := decodeGeometry(geobytes)
+			if err != nil {
+				return err
+			}
+			if sniffSRID && h.SRSID() > 0 {
+				l.srid = int(h.SRSID())
+			}
+			if geom == nil {
+				continue
+			}
+
+			if l.geomType, err = geomTypeFromWKB(geom); err != nil {
+				return fmt.Errorf("layer (%v) returned %v", l.name, err)
+			}
+		}
+	}
+
+	if l.srid == 0 {
+		l.srid = DefaultSRID
+	}
+
+	return rows.Err()
+}
+
+//	Close closes the GeoPackage
+func (p *Provider) Close() error {
+	return p.db.Close()
+}
+
+func (p *Provider) Layer(name string) (Layer, bool) {
+	if name == "" {
+		return p.layers[p.firstlayer], true
+	}
+	plyr, ok := p.layers[name]
+	return plyr, ok
+}
+
+func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
+	var ls []mvt.LayerInfo
+
+	for i := range p.layers {
+		ls = append(ls, p.layers[i])
+	}
+
+	return ls, nil
+}
+
+func (p *Provider) ForEachFeature(ctx context.Context, layerName string, tile tegola.Tile, fn func(layer Layer, gid uint64, geom wkb.Geometry, tags map[string]interface{}) error) error {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	sql, err := replaceTokens(&plyr, tile)
+	if err != nil {
+		return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+	}
+
+	if strings.Contains(os.Getenv("SQL_DEBUG"), "EXECUTE_SQL") {
+		log.Printf("SQL_DEBUG:EXECUTE_SQL for layer (%v): %v", layerName, sql)
+	}
+
+	// do a quick context check:
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rows, err := p.db.QueryContext(ctx, sql)
+	if err != nil {
+		return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+	}
+
+	vals := make([]interface{}, len(cols))
+	valPtrs := make([]interface{}, len(cols))
+	for i := range vals {
+		valPtrs[i] = &vals[i]
+	}
+
+	for rows.Next() {
+		// do a quick context check:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err = rows.Scan(valPtrs...); err != nil {
+			return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+		}
+
+		var gid uint64
+		var geom wkb.Geometry
+		tags := map[string]interface{}{}
+
+		for i, v := range vals {
+			// Skip nil values.
+			if v == nil {
+				continue
+			}
+
+			switch cols[i] {
+			case plyr.GeomFieldName():
+				geobytes, ok := v.([]byte)
+				if !ok {
+					return fmt.Errorf("Unable to convert geometry field (%v) into bytes.", plyr.GeomFieldName())
+				}
+				if _, geom, err = decodeGeometry(geobytes); err != nil {
+					return fmt.Errorf("unable to decode layer (%v) geometry field (%v): %v", layerName, plyr.GeomFieldName(), err)
+				}
+			case plyr.IDFieldName():
+				if gid, err = gId(v); err != nil {
+					return fmt.Errorf("For layer (%v) unable to convert id field (%v): %v", layerName, plyr.IDFieldName(), err)
+				}
+			default:
+				if rtreeFields[cols[i]] {
+					continue
+				}
+				value, err := transformVal(v)
+				if err != nil {
+					return fmt.Errorf("For layer (%v) unable to convert field (%v): %v", layerName, cols[i], err)
+				}
+				tags[cols[i]] = value
+			}
+		}
+
+		//	empty geometries have nothing to render
+		if geom == nil {
+			continue
+		}
+
+		if err = fn(plyr, gid, geom, tags); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (layer *mvt.Layer, err error) {
+
+	layer = &mvt.Layer{
+		Name: layerName,
+	}
+
+	err = p.ForEachFeature(ctx, layerName, tile,
+		func(lyr Layer, gid uint64, wgeom wkb.Geometry, ftags map[string]interface{}) error {
+			var geom tegola.Geometry = wgeom
+			if lyr.SRID() != tegola.WebMercator {
+				g, err := basic.ToWebMercator(lyr.SRID(), geom)
+				if err != nil {
+					return fmt.Errorf("Was unable to transform geometry to webmercator from SRID (%v) for layer (%v)", lyr.SRID(), layerName)
+				}
+				geom = g.Geometry
+			}
+			//	copy our default tags to a tags map
+			tags := map[string]interface{}{}
+			for k, v := range dtags {
+				tags[k] = v
+			}
+
+			//	add feature tags to our map
+			for k := range ftags {
+				tags[k] = ftags[k]
+			}
+
+			// Add features to Layer
+			layer.AddFeatures(mvt.Feature{
+				ID:       &gid,
+				Tags:     tags,
+				Geometry: geom,
+			})
+
+			return nil
+		})
+
+	return layer, err
+}
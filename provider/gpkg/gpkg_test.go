@@ -0,0 +1,189 @@
+package gpkg_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/provider/gpkg"
+)
+
+//	testGeoPackage has two feature tables in WGS84:
+//		roads (LINESTRING, with rtree index): two roads in Athens and one in La Serena, Chile
+//		pois (POINT, without rtree index)
+const testGeoPackage = "testfiles/test.gpkg"
+
+func TestNewProvider(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		expectErr bool
+		layers    map[string]tegola.Geometry
+	}{
+		{
+			config: map[string]interface{}{
+				gpkg.ConfigKeyFilePath: testGeoPackage,
+				gpkg.ConfigKeyLayers: []map[string]interface{}{
+					{
+						gpkg.ConfigKeyLayerName: "roads",
+					},
+					{
+						gpkg.ConfigKeyLayerName: "roads_sql",
+						gpkg.ConfigKeySQL:       "SELECT fid, geom, name FROM roads l JOIN rtree_roads_geom si ON l.fid = si.id WHERE !BBOX!",
+					},
+				},
+			},
+			layers: map[string]tegola.Geometry{
+				"roads":     basic.Line{},
+				"roads_sql": basic.Line{},
+			},
+		},
+		//	missing file
+		{
+			config: map[string]interface{}{
+				gpkg.ConfigKeyFilePath: "testfiles/missing.gpkg",
+				gpkg.ConfigKeyLayers:   []map[string]interface{}{},
+			},
+			expectErr: true,
+		},
+		//	table without an rtree index
+		{
+			config: map[string]interface{}{
+				gpkg.ConfigKeyFilePath: testGeoPackage,
+				gpkg.ConfigKeyLayers: []map[string]interface{}{
+					{
+						gpkg.ConfigKeyLayerName: "pois",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	sql missing the !BBOX! token
+		{
+			config: map[string]interface{}{
+				gpkg.ConfigKeyFilePath: testGeoPackage,
+				gpkg.ConfigKeyLayers: []map[string]interface{}{
+					{
+						gpkg.ConfigKeyLayerName: "roads",
+						gpkg.ConfigKeySQL:       "SELECT fid, geom FROM roads",
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		p, err := gpkg.NewProvider(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		layers, err := p.Layers()
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if len(layers) != len(tc.layers) {
+			t.Errorf("testcase (%v) failed. expected (%v) layers, got (%v)", i, len(tc.layers), len(layers))
+			continue
+		}
+
+		for _, l := range layers {
+			if reflect.TypeOf(l.GeomType()) != reflect.TypeOf(tc.layers[l.Name()]) {
+				t.Errorf("testcase (%v) failed. layer (%v) expected geom type (%T) got (%T)", i, l.Name(), tc.layers[l.Name()], l.GeomType())
+			}
+			if l.SRID() != tegola.WGS84 {
+				t.Errorf("testcase (%v) failed. layer (%v) expected srid (%v) got (%v)", i, l.Name(), tegola.WGS84, l.SRID())
+			}
+		}
+
+		if err = p.(mvt.Closer).Close(); err != nil {
+			t.Errorf("testcase (%v) failed. unable to close the provider. err: %v", i, err)
+		}
+	}
+}
+
+func TestMVTLayer(t *testing.T) {
+	p, err := gpkg.NewProvider(map[string]interface{}{
+		gpkg.ConfigKeyFilePath: testGeoPackage,
+		gpkg.ConfigKeyLayers: []map[string]interface{}{
+			{
+				gpkg.ConfigKeyLayerName: "roads",
+				gpkg.ConfigKeyFields:    []string{"name"},
+			},
+			{
+				gpkg.ConfigKeyLayerName: "wide_roads",
+				gpkg.ConfigKeySQL:       "SELECT fid, geom, lanes FROM roads l JOIN rtree_roads_geom si ON l.fid = si.id WHERE !BBOX! AND lanes > 2",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create provider: %v", err)
+	}
+	defer p.(mvt.Closer).Close()
+
+	testcases := []struct {
+		layerName    string
+		tile         tegola.Tile
+		expectedTags map[uint64]map[string]interface{}
+	}{
+		{
+			layerName: "roads",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				1: {"name": "Ermou", "class": "road"},
+				2: {"name": "Athinas", "class": "road"},
+			},
+		},
+		{
+			layerName: "wide_roads",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				2: {"lanes": int64(4), "class": "road"},
+			},
+		},
+		{
+			layerName: "roads",
+			tile:      tegola.Tile{Z: 10, X: 312, Y: 601},
+			expectedTags: map[uint64]map[string]interface{}{
+				3: {"name": "Avenida Brasil", "class": "road"},
+			},
+		},
+		{
+			layerName:    "roads",
+			tile:         tegola.Tile{Z: 10, X: 0, Y: 0},
+			expectedTags: map[uint64]map[string]interface{}{},
+		},
+	}
+
+	for i, tc := range testcases {
+		l, err := p.MVTLayer(context.Background(), tc.layerName, tc.tile, map[string]interface{}{"class": "road"})
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		features := l.Features()
+		if len(features) != len(tc.expectedTags) {
+			t.Errorf("testcase (%v) failed. expected (%v) features, got (%v)", i, len(tc.expectedTags), len(features))
+			continue
+		}
+
+		for _, f := range features {
+			if !reflect.DeepEqual(tc.expectedTags[*f.ID], f.Tags) {
+				t.Errorf("testcase (%v) failed. feature (%v) expected tags (%v) got (%v)", i, *f.ID, tc.expectedTags[*f.ID], f.Tags)
+			}
+		}
+	}
+}
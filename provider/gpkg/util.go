@@ -0,0 +1,201 @@
+package gpkg
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/wkb"
+)
+
+const (
+	bboxToken = "!BBOX!"
+	zoomToken = "!ZOOM!"
+)
+
+//	the columns of a GeoPackage rtree index. these are not encoded as tags.
+var rtreeFields = map[string]bool{
+	"minx": true,
+	"maxx": true,
+	"miny": true,
+	"maxy": true,
+}
+
+// genSQL will build the SQL for a layer given the feature table name and list of fields.
+func genSQL(l *Layer, db *sql.DB, tblname string, flds []string) (string, error) {
+	if len(flds) == 0 {
+		// We need to hit the database to see what the fields are.
+		rows, err := db.Query(fmt.Sprintf(fldsSQL, tblname))
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+
+		flds, err = rows.Columns()
+		if err != nil {
+			return "", err
+		}
+		if len(flds) == 0 {
+			return "", fmt.Errorf("No fields were returned for table %v", tblname)
+		}
+	}
+
+	var fgeom, fgid bool
+	var selectFlds []string
+	for _, f := range flds {
+		switch f {
+		case l.geomField:
+			fgeom = true
+		case l.idField:
+			fgid = true
+		}
+		//	to avoid field names possibly colliding with SQLite keywords,
+		//	we wrap the field names in quotes
+		selectFlds = append(selectFlds, fmt.Sprintf(`l."%v"`, f))
+	}
+	if !fgeom {
+		selectFlds = append(selectFlds, fmt.Sprintf(`l."%v"`, l.geomField))
+	}
+	if !fgid {
+		selectFlds = append(selectFlds, fmt.Sprintf(`l."%v"`, l.idField))
+	}
+
+	return fmt.Sprintf(stdSQL, strings.Join(selectFlds, ", "), tblname, l.geomField, l.idField), nil
+}
+
+//	replaceTokens replaces tokens in the provided SQL string
+//
+//	!BBOX! - the bounding box of the tile as a comparison against the rtree minx, miny, maxx, maxy columns
+//	!ZOOM! - the tile Z value
+func replaceTokens(plyr *Layer, tile tegola.Tile) (string, error) {
+	textent := tile.BoundingBox()
+
+	minGeo, err := basic.FromWebMercator(plyr.srid, basic.Point{textent.Minx, textent.Miny})
+	if err != nil {
+		return "", fmt.Errorf("Error trying to convert tile point: %v ", err)
+	}
+	maxGeo, err := basic.FromWebMercator(plyr.srid, basic.Point{textent.Maxx, textent.Maxy})
+	if err != nil {
+		return "", fmt.Errorf("Error trying to convert tile point: %v ", err)
+	}
+
+	minPt, maxPt := minGeo.AsPoint(), maxGeo.AsPoint()
+
+	//	the tile's y axis is flipped so we need to sort our values
+	minx, maxx := math.Min(minPt.X(), maxPt.X()), math.Max(minPt.X(), maxPt.X())
+	miny, maxy := math.Min(minPt.Y(), maxPt.Y()), math.Max(minPt.Y(), maxPt.Y())
+
+	bbox := fmt.Sprintf("minx <= %v AND maxx >= %v AND miny <= %v AND maxy >= %v",
+		strconv.FormatFloat(maxx, 'f', -1, 64),
+		strconv.FormatFloat(minx, 'f', -1, 64),
+		strconv.FormatFloat(maxy, 'f', -1, 64),
+		strconv.FormatFloat(miny, 'f', -1, 64),
+	)
+
+	//	replace query string tokens
+	tokenReplacer := strings.NewReplacer(
+		bboxToken, bbox,
+		zoomToken, strconv.Itoa(tile.Z),
+	)
+
+	return tokenReplacer.Replace(plyr.sql), nil
+}
+
+//	geomTypeFromName maps the geometry_type_name values of the gpkg_geometry_columns table
+//	to a tegola geometry. GEOMETRY (i.e. mixed geometries) returns nil.
+func geomTypeFromName(name string) (tegola.Geometry, error) {
+	switch strings.ToUpper(name) {
+	case "POINT":
+		return basic.Point{}, nil
+	case "LINESTRING":
+		return basic.Line{}, nil
+	case "POLYGON":
+		return basic.Polygon{}, nil
+	case "MULTIPOINT":
+		return basic.MultiPoint{}, nil
+	case "MULTILINESTRING":
+		return basic.MultiLine{}, nil
+	case "MULTIPOLYGON":
+		return basic.MultiPolygon{}, nil
+	case "GEOMETRYCOLLECTION":
+		return basic.Collection{}, nil
+	case "GEOMETRY":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type (%v)", name)
+	}
+}
+
+//	geomTypeFromWKB maps a wkb geometry to a tegola geometry type
+func geomTypeFromWKB(geom wkb.Geometry) (tegola.Geometry, error) {
+	switch geom.Type() {
+	case wkb.GeoPoint:
+		return basic.Point{}, nil
+	case wkb.GeoLineString:
+		return basic.Line{}, nil
+	case wkb.GeoPolygon:
+		return basic.Polygon{}, nil
+	case wkb.GeoMultiPoint:
+		return basic.MultiPoint{}, nil
+	case wkb.GeoMultiLineString:
+		return basic.MultiLine{}, nil
+	case wkb.GeoMultiPolygon:
+		return basic.MultiPolygon{}, nil
+	case wkb.GeoGeometryCollection:
+		return basic.Collection{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type (%v)", geom.Type())
+	}
+}
+
+//	decodeGeometry strips the GeoPackage binary header and decodes the WKB that follows
+func decodeGeometry(data []byte) (*BinaryHeader, wkb.Geometry, error) {
+	h, err := NewBinaryHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !h.IsStandard() {
+		return h, nil, fmt.Errorf("gpkg: extended geometry types are not supported")
+	}
+	if h.IsEmpty() {
+		return h, nil, nil
+	}
+
+	geom, err := wkb.DecodeBytes(data[h.Size():])
+	return h, geom, err
+}
+
+//	transformVal converts the values returned by the SQLite driver into values
+//	that can be encoded as tags
+func transformVal(val interface{}) (interface{}, error) {
+	switch vt := val.(type) {
+	case int64, float64, string, bool:
+		return vt, nil
+	case []byte:
+		return string(vt), nil
+	case time.Time:
+		return vt.Format(time.RFC3339), nil
+	default:
+		return nil, fmt.Errorf("%T type is not supported", val)
+	}
+}
+
+func gId(v interface{}) (gid uint64, err error) {
+	switch aval := v.(type) {
+	case int64:
+		return uint64(aval), nil
+	case float64:
+		return uint64(aval), nil
+	case string:
+		return strconv.ParseUint(aval, 10, 64)
+	case []byte:
+		return strconv.ParseUint(string(aval), 10, 64)
+	default:
+		return gid, fmt.Errorf("Unable to convert field into a uint64.")
+	}
+}
@@ -0,0 +1,53 @@
+package gpkg
+
+import (
+	"testing"
+
+	"github.com/airmap/tegola"
+)
+
+func TestReplaceTokens(t *testing.T) {
+	testcases := []struct {
+		layer    Layer
+		tile     tegola.Tile
+		expected string
+	}{
+		{
+			layer: Layer{
+				sql:  "SELECT * FROM foo WHERE !BBOX!",
+				srid: tegola.WebMercator,
+			},
+			tile: tegola.Tile{
+				Z: 2,
+				X: 1,
+				Y: 1,
+			},
+			expected: "SELECT * FROM foo WHERE minx <= 0 AND maxx >= -10018754.17 AND miny <= 10018754.17 AND maxy >= 0",
+		},
+		{
+			layer: Layer{
+				sql:  "SELECT id, scalerank=!ZOOM! FROM foo WHERE !BBOX!",
+				srid: tegola.WGS84,
+			},
+			tile: tegola.Tile{
+				Z: 2,
+				X: 1,
+				Y: 1,
+			},
+			expected: "SELECT id, scalerank=2 FROM foo WHERE minx <= 0 AND maxx >= -89.99999998747191 AND miny <= 66.51326043811893 AND maxy >= 0",
+		},
+	}
+
+	for i, tc := range testcases {
+		sql, err := replaceTokens(&tc.layer, tc.tile)
+		if err != nil {
+			t.Errorf("Failed test %v. err: %v", i, err)
+			return
+		}
+
+		if sql != tc.expected {
+			t.Errorf("Failed test %v. Expected (%v), got (%v)", i, tc.expected, sql)
+			return
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package mbtiles
+
+import "github.com/airmap/tegola"
+
+// Layer is a vector layer of the tileset.
+type Layer struct {
+	// The Name of the layer as it's stored in the tiles
+	name string
+}
+
+func (l Layer) Name() string {
+	return l.name
+}
+
+// GeomType is nil as the layers of a tileset can contain mixed geometries.
+func (l Layer) GeomType() tegola.Geometry {
+	return nil
+}
+
+// SRID is always webmercator. The geometries are converted from tile coordinates when decoded.
+func (l Layer) SRID() int {
+	return tegola.WebMercator
+}
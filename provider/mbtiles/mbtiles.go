@@ -0,0 +1,269 @@
+//	Package mbtiles provides a data provider for serving the layers of a pre-rendered
+//	vector tileset stored as MBTiles (https://github.com/mapbox/mbtiles-spec).
+//	The stored tiles are decoded so their layers can be merged with other providers.
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
+)
+
+const Name = "mbtiles"
+
+// Provider provides the MBTiles data provider.
+type Provider struct {
+	// path to the MBTiles file
+	filepath string
+	db       *sql.DB
+	// map of layer name and corrosponding layer
+	layers     map[string]Layer
+	firstlayer string
+	// the zoom range of the tileset
+	minZoom int
+	maxZoom int
+}
+
+const (
+	// SQL to fetch a tile. The tile_row is in the TMS scheme.
+	tileSQL = `SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?;`
+
+	// SQL to read the metadata of the tileset.
+	metadataSQL = `SELECT name, value FROM metadata;`
+)
+
+const (
+	//	the max zoom used when the tileset's metadata does not have a maxzoom
+	DefaultMaxZoom = 30
+)
+
+const (
+	ConfigKeyFilePath  = "filepath"
+	ConfigKeyLayers    = "layers"
+	ConfigKeyLayerName = "name"
+)
+
+func init() {
+	provider.Register(Name, NewProvider)
+}
+
+//	NewProvider Setups and returns a new MBTiles provider or an error; if something
+//	is wrong. The provider expects the following fields to exists in the provided
+//	map[string]interface{} map:
+//
+//		filepath (string) — the path to the .mbtiles file.
+//		layers (map[string]struct{})  — Optional. The layers of the tileset to serve. Defaults to the
+//			vector_layers listed in the tileset's json metadata.
+//     		name (string) — The name of the layer in the stored tiles.
+//
+func NewProvider(config map[string]interface{}) (prov mvt.Provider, err error) {
+	// Validate the config to make sure it has the values I care about and the types for those values.
+	c := dict.M(config)
+
+	fpath, err := c.String(ConfigKeyFilePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if fpath == "" {
+		return nil, fmt.Errorf("%v value is required.", ConfigKeyFilePath)
+	}
+
+	//	sqlite will create a new database if the file does not exist. we don't want that.
+	if _, err := os.Stat(fpath); err != nil {
+		return nil, fmt.Errorf("unable to open MBTiles (%v): %v", fpath, err)
+	}
+
+	p := Provider{
+		filepath: fpath,
+		layers:   make(map[string]Layer),
+		maxZoom:  DefaultMaxZoom,
+	}
+
+	if p.db, err = sql.Open("sqlite3", fmt.Sprintf("file:%v?mode=ro", fpath)); err != nil {
+		return nil, fmt.Errorf("Failed while opening MBTiles (%v): %v", fpath, err)
+	}
+	//	don't leak the database if the layers can't be set up
+	defer func() {
+		if err != nil {
+			p.db.Close()
+		}
+	}()
+
+	vectorLayers, err := p.readMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading MBTiles (%v) metadata: %v", fpath, err)
+	}
+
+	//	if the layers are not configured we serve all the layers of the tileset
+	var layers []map[string]interface{}
+	if _, ok := c[ConfigKeyLayers]; ok {
+		if layers, ok = c[ConfigKeyLayers].([]map[string]interface{}); !ok {
+			return nil, fmt.Errorf("Expected %v to be a []map[string]interface{}", ConfigKeyLayers)
+		}
+	} else {
+		if len(vectorLayers) == 0 {
+			return nil, fmt.Errorf("MBTiles (%v) metadata does not list the vector_layers. %v must be configured.", fpath, ConfigKeyLayers)
+		}
+		for _, name := range vectorLayers {
+			layers = append(layers, map[string]interface{}{ConfigKeyLayerName: name})
+		}
+	}
+
+	for i, v := range layers {
+		vc := dict.M(v)
+
+		lname, err := vc.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if _, ok := p.layers[lname]; ok {
+			return nil, fmt.Errorf("%v layer name is duplicated", lname)
+		}
+		if i == 0 {
+			p.firstlayer = lname
+		}
+
+		p.layers[lname] = Layer{
+			name: lname,
+		}
+	}
+
+	return &p, nil
+}
+
+//	readMetadata validates the format of the tileset, sets the zoom range and returns
+//	the names of the vector_layers listed in the json metadata, if any.
+func (p *Provider) readMetadata() (vectorLayers []string, err error) {
+	rows, err := p.db.Query(metadataSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "format":
+			if value != "pbf" {
+				return nil, fmt.Errorf("unsupported tile format (%v). only vector tiles (pbf) are supported", value)
+			}
+		case "minzoom":
+			if p.minZoom, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid minzoom (%v): %v", value, err)
+			}
+		case "maxzoom":
+			if p.maxZoom, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid maxzoom (%v): %v", value, err)
+			}
+		case "json":
+			var meta struct {
+				VectorLayers []struct {
+					ID string `json:"id"`
+				} `json:"vector_layers"`
+			}
+			if err = json.Unmarshal([]byte(value), &meta); err != nil {
+				return nil, fmt.Errorf("invalid json metadata: %v", err)
+			}
+			for _, vl := range meta.VectorLayers {
+				vectorLayers = append(vectorLayers, vl.ID)
+			}
+		}
+	}
+
+	return vectorLayers, rows.Err()
+}
+
+//	Close closes the MBTiles file
+func (p *Provider) Close() error {
+	return p.db.Close()
+}
+
+func (p *Provider) Layer(name string) (Layer, bool) {
+	if name == "" {
+		return p.layers[p.firstlayer], true
+	}
+	plyr, ok := p.layers[name]
+	return plyr, ok
+}
+
+func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
+	var ls []mvt.LayerInfo
+
+	for i := range p.layers {
+		ls = append(ls, p.layers[i])
+	}
+
+	return ls, nil
+}
+
+//	tile fetches and decodes a tile of the tileset. nil is returned if the tileset does not contain the tile.
+func (p *Provider) tile(ctx context.Context, tile tegola.Tile) (*mvt.Tile, error) {
+	if tile.Z < p.minZoom || tile.Z > p.maxZoom {
+		return nil, nil
+	}
+
+	//	MBTiles uses the TMS tiling scheme which has the y axis flipped
+	row := (1 << uint(tile.Z)) - 1 - tile.Y
+
+	var data []byte
+	err := p.db.QueryRowContext(ctx, tileSQL, tile.Z, tile.X, row).Scan(&data)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	//	tiles are usually gzip compressed, which is handled by the decoder
+	return mvt.Decode(data)
+}
+
+func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (layer *mvt.Layer, err error) {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return nil, fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	layer = &mvt.Layer{
+		Name: layerName,
+	}
+
+	t, err := p.tile(ctx, tile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tile (%v/%v/%v) for layer (%v): %v", tile.Z, tile.X, tile.Y, layerName, err)
+	}
+	if t == nil {
+		return layer, nil
+	}
+
+	for _, tl := range t.Layers() {
+		if tl.Name != plyr.Name() {
+			continue
+		}
+
+		layer, err = tl.WebMercatorLayer(ctx, layerName, tile.BoundingBox(), dtags)
+		if err != nil {
+			if err == ctx.Err() {
+				return nil, err
+			}
+			return nil, fmt.Errorf("unable to convert geometries for layer (%v): %v", layerName, err)
+		}
+		break
+	}
+
+	return layer, nil
+}
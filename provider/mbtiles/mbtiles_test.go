@@ -0,0 +1,235 @@
+package mbtiles_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/provider/mbtiles"
+)
+
+//	pt converts a lon/lat pair to a webmercator point
+func pt(t *testing.T, lon, lat float64) basic.Point {
+	g, err := basic.ToWebMercator(tegola.WGS84, basic.Point{lon, lat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.Geometry.(basic.Point)
+}
+
+var (
+	athens = tegola.Tile{Z: 10, X: 579, Y: 395}
+	chile  = tegola.Tile{Z: 10, X: 312, Y: 601}
+	tokyo  = tegola.Tile{Z: 10, X: 909, Y: 403}
+)
+
+//	testMBTiles is a tileset with the pois of the athens, chile and tokyo tiles, zooms 10 to 12.
+//	The metadata lists the pois and roads vector_layers. The Athens tile is gzip compressed.
+//	The pois of the Tokyo tile have the ids 0, 0, 7 and 7.
+const testMBTiles = "testfiles/test.mbtiles"
+
+func TestNewProvider(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		expectErr bool
+		layers    []string
+	}{
+		//	layers from the metadata
+		{
+			config: map[string]interface{}{
+				mbtiles.ConfigKeyFilePath: testMBTiles,
+			},
+			layers: []string{"pois", "roads"},
+		},
+		{
+			config: map[string]interface{}{
+				mbtiles.ConfigKeyFilePath: testMBTiles,
+				mbtiles.ConfigKeyLayers: []map[string]interface{}{
+					{mbtiles.ConfigKeyLayerName: "pois"},
+				},
+			},
+			layers: []string{"pois"},
+		},
+		//	missing file
+		{
+			config: map[string]interface{}{
+				mbtiles.ConfigKeyFilePath: "testfiles/missing.mbtiles",
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		p, err := mbtiles.NewProvider(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		layers, err := p.Layers()
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if len(layers) != len(tc.layers) {
+			t.Errorf("testcase (%v) failed. expected (%v) layers, got (%v)", i, len(tc.layers), len(layers))
+			continue
+		}
+
+		names := map[string]bool{}
+		for _, l := range layers {
+			names[l.Name()] = true
+			if l.SRID() != tegola.WebMercator {
+				t.Errorf("testcase (%v) failed. layer (%v) expected srid (%v) got (%v)", i, l.Name(), tegola.WebMercator, l.SRID())
+			}
+		}
+		for _, name := range tc.layers {
+			if !names[name] {
+				t.Errorf("testcase (%v) failed. missing layer (%v)", i, name)
+			}
+		}
+
+		if err = p.(mvt.Closer).Close(); err != nil {
+			t.Errorf("testcase (%v) failed. unable to close the provider. err: %v", i, err)
+		}
+	}
+}
+
+func TestMVTLayer(t *testing.T) {
+	p, err := mbtiles.NewProvider(map[string]interface{}{
+		mbtiles.ConfigKeyFilePath: testMBTiles,
+	})
+	if err != nil {
+		t.Fatalf("unable to create provider: %v", err)
+	}
+	defer p.(mvt.Closer).Close()
+
+	testcases := []struct {
+		layerName      string
+		tile           tegola.Tile
+		expectedTags   map[uint64]map[string]interface{}
+		expectedPoints map[uint64]basic.Point
+	}{
+		{
+			layerName: "pois",
+			tile:      athens,
+			expectedTags: map[uint64]map[string]interface{}{
+				1: {"name": "Acropolis", "height": int64(156), "class": "poi"},
+				2: {"name": "Lycabettus", "height": 277.5, "class": "poi"},
+			},
+			expectedPoints: map[uint64]basic.Point{
+				1: pt(t, 23.726, 37.971),
+				2: pt(t, 23.743, 37.982),
+			},
+		},
+		{
+			layerName: "pois",
+			tile:      chile,
+			expectedTags: map[uint64]map[string]interface{}{
+				1: {"name": "La Serena", "capital": true, "class": "poi"},
+			},
+			expectedPoints: map[uint64]basic.Point{
+				1: pt(t, -70.005, -30.005),
+			},
+		},
+		//	the layer is not in the tile
+		{
+			layerName:    "roads",
+			tile:         athens,
+			expectedTags: map[uint64]map[string]interface{}{},
+		},
+		//	the tile is not in the tileset
+		{
+			layerName:    "pois",
+			tile:         tegola.Tile{Z: 10, X: 0, Y: 0},
+			expectedTags: map[uint64]map[string]interface{}{},
+		},
+		//	outside of the zoom range
+		{
+			layerName:    "pois",
+			tile:         tegola.Tile{Z: 2, X: 0, Y: 0},
+			expectedTags: map[uint64]map[string]interface{}{},
+		},
+	}
+
+	for i, tc := range testcases {
+		l, err := p.MVTLayer(context.Background(), tc.layerName, tc.tile, map[string]interface{}{"class": "poi"})
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		features := l.Features()
+		if len(features) != len(tc.expectedTags) {
+			t.Errorf("testcase (%v) failed. expected (%v) features, got (%v)", i, len(tc.expectedTags), len(features))
+			continue
+		}
+
+		//	the geometries are snapped to the tile grid when encoded
+		textent := tc.tile.BoundingBox()
+		tolerance := (textent.Maxx - textent.Minx) / 4096
+
+		for _, f := range features {
+			if !reflect.DeepEqual(tc.expectedTags[*f.ID], f.Tags) {
+				t.Errorf("testcase (%v) failed. feature (%v) expected tags (%v) got (%v)", i, *f.ID, tc.expectedTags[*f.ID], f.Tags)
+			}
+
+			got, ok := f.Geometry.(basic.Point)
+			if !ok {
+				t.Errorf("testcase (%v) failed. feature (%v) expected a point got (%T)", i, *f.ID, f.Geometry)
+				continue
+			}
+			expected := tc.expectedPoints[*f.ID]
+			if math.Abs(got.X()-expected.X()) > tolerance || math.Abs(got.Y()-expected.Y()) > tolerance {
+				t.Errorf("testcase (%v) failed. feature (%v) expected point (%v) got (%v)", i, *f.ID, expected, got)
+			}
+		}
+	}
+}
+
+func TestMVTLayerRepeatedIDs(t *testing.T) {
+	p, err := mbtiles.NewProvider(map[string]interface{}{
+		mbtiles.ConfigKeyFilePath: testMBTiles,
+	})
+	if err != nil {
+		t.Fatalf("unable to create provider: %v", err)
+	}
+	defer p.(mvt.Closer).Close()
+
+	l, err := p.MVTLayer(context.Background(), "pois", tokyo, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	//	all the features are kept, in the order of the tile
+	expected := []struct {
+		id   uint64
+		name string
+	}{
+		{0, "Shibuya"},
+		{0, "Shinjuku"},
+		{7, "Tokyo Tower"},
+		{7, "Ueno"},
+	}
+
+	features := l.Features()
+	if len(features) != len(expected) {
+		t.Fatalf("expected (%v) features, got (%v)", len(expected), len(features))
+	}
+	for i, f := range features {
+		if f.ID == nil || *f.ID != expected[i].id || f.Tags["name"] != expected[i].name {
+			t.Errorf("feature (%v) expected id (%v) name (%v) got (%v) (%v)", i, expected[i].id, expected[i].name, f.ID, f.Tags["name"])
+		}
+	}
+}
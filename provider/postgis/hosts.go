@@ -0,0 +1,356 @@
+package postgis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+const (
+	//	hand out connections to the hosts in turn
+	BalanceRoundRobin = "round_robin"
+	//	hand out connections from the host with the fewest connections in use
+	BalanceLeastConnections = "least_connections"
+)
+
+var errHostPoolClosed = errors.New("the connection pool is closed")
+
+//	dbHost is a database server the provider reads from
+type dbHost struct {
+	config pgx.ConnPoolConfig
+
+	//	guards pool, healthy and closed
+	sync.RWMutex
+	//	nil until a connection to the host has been made
+	pool *pgx.ConnPool
+	//	false once the host has failed, until a health check succeeds
+	healthy bool
+	//	set when the host pool is closed. no new connections are made once it's set
+	closed bool
+
+	//	serializes connecting to the host so concurrent checks don't each create a pool.
+	//	it's separate from the RWMutex so the host can be read while connecting.
+	connectMu sync.Mutex
+}
+
+func (h *dbHost) String() string {
+	return net.JoinHostPort(h.config.Host, strconv.Itoa(int(h.config.Port)))
+}
+
+//	connPool returns the host's connection pool and health
+func (h *dbHost) connPool() (*pgx.ConnPool, bool) {
+	h.RLock()
+	defer h.RUnlock()
+
+	return h.pool, h.healthy
+}
+
+//	inUse returns the number of the host's connections that are acquired
+func (h *dbHost) inUse() int {
+	pool, _ := h.connPool()
+	if pool == nil {
+		return 0
+	}
+
+	stat := pool.Stat()
+	return stat.CurrentConnections - stat.AvailableConnections
+}
+
+//	setHealthy records the health of the host, logging when it changes
+func (h *dbHost) setHealthy(healthy bool, cause error) {
+	h.Lock()
+	//	a check finishing after the host pool is closed does not bring the host back
+	if h.closed {
+		h.Unlock()
+		return
+	}
+	changed := h.healthy != healthy
+	h.healthy = healthy
+	h.Unlock()
+
+	if !changed {
+		return
+	}
+	if healthy {
+		log.Printf("database host (%v) is back up", h)
+	} else {
+		log.Printf("database host (%v) is down, removing it until it passes a health check: %v", h, cause)
+	}
+}
+
+//	connect returns the host's connection pool, connecting to the host if it's not connected yet
+func (h *dbHost) connect() (*pgx.ConnPool, error) {
+	h.connectMu.Lock()
+	defer h.connectMu.Unlock()
+
+	h.RLock()
+	pool, closed := h.pool, h.closed
+	h.RUnlock()
+
+	switch {
+	case closed:
+		return nil, errHostPoolClosed
+	case pool != nil:
+		return pool, nil
+	}
+
+	pool, err := pgx.NewConnPool(h.config)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	//	the host pool was closed while connecting
+	if h.closed {
+		pool.Close()
+		return nil, errHostPoolClosed
+	}
+	h.pool = pool
+
+	return pool, nil
+}
+
+//	check connects to the host, if it's not connected yet, and runs a trivial statement on it
+func (h *dbHost) check() error {
+	pool, err := h.connect()
+	if err != nil {
+		return err
+	}
+
+	conn, err := pool.Acquire()
+	if err != nil {
+		return err
+	}
+	defer pool.Release(conn)
+
+	_, err = conn.Exec("SELECT 1")
+	return err
+}
+
+//	hostPool hands out connections to a set of database hosts (i.e. a primary and its read replicas)
+//	holding the same data. Hosts that fail are removed from the rotation and added back once they
+//	pass a health check.
+type hostPool struct {
+	hosts   []*dbHost
+	balance string
+	//	the round robin position
+	next uint32
+	//	closed when the pool is closed to stop the health checks
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//	newHostPool connects to the hosts. An error is returned if none of them can be connected to.
+//	Hosts that can't be connected to are retried by the health checks, which run at the interval
+//	when there is more than one host. A zero interval disables the health checks.
+func newHostPool(configs []pgx.ConnPoolConfig, balance string, healthCheckInterval time.Duration) (*hostPool, error) {
+	switch balance {
+	case BalanceRoundRobin, BalanceLeastConnections:
+	default:
+		return nil, fmt.Errorf("%v (%v) is invalid. expected %v or %v", ConfigKeyBalance, balance, BalanceRoundRobin, BalanceLeastConnections)
+	}
+
+	hp := hostPool{
+		balance: balance,
+		done:    make(chan struct{}),
+	}
+
+	var err error
+	var up int
+	for i := range configs {
+		h := &dbHost{config: configs[i]}
+		hp.hosts = append(hp.hosts, h)
+
+		var pool *pgx.ConnPool
+		if pool, err = pgx.NewConnPool(h.config); err != nil {
+			log.Printf("database host (%v) is down: %v", h, err)
+			continue
+		}
+		h.pool, h.healthy = pool, true
+		up++
+	}
+	if up == 0 {
+		return nil, fmt.Errorf("Failed while creating connection pool: %v", err)
+	}
+
+	if len(hp.hosts) > 1 && healthCheckInterval > 0 {
+		go hp.healthChecks(healthCheckInterval)
+	}
+
+	return &hp, nil
+}
+
+//	healthChecks checks every host at the interval
+func (hp *hostPool) healthChecks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hp.done:
+			return
+		case <-ticker.C:
+		}
+
+		var wg sync.WaitGroup
+		for _, h := range hp.hosts {
+			wg.Add(1)
+			go func(h *dbHost) {
+				defer wg.Done()
+
+				err := h.check()
+				h.setHealthy(err == nil, err)
+			}(h)
+		}
+		wg.Wait()
+	}
+}
+
+//	health checks every host, returning nil as soon as one of them passes. The hosts' health is
+//	not changed as a single failed check does not take a host out of the rotation.
+func (hp *hostPool) health(ctx context.Context) error {
+	select {
+	case <-hp.done:
+		return errHostPoolClosed
+	default:
+	}
+
+	errs := make(chan error, len(hp.hosts))
+	for _, h := range hp.hosts {
+		go func(h *dbHost) {
+			if err := h.check(); err != nil {
+				errs <- fmt.Errorf("database host (%v): %v", h, err)
+				return
+			}
+			errs <- nil
+		}(h)
+	}
+
+	var err error
+	for range hp.hosts {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err = <-errs:
+			if err == nil {
+				return nil
+			}
+		}
+	}
+
+	return err
+}
+
+//	close stops the health checks and closes the connections to all the hosts.
+//	It waits for the connections that are acquired to be released.
+func (hp *hostPool) close() {
+	hp.closeOnce.Do(func() {
+		close(hp.done)
+
+		for _, h := range hp.hosts {
+			h.Lock()
+			pool := h.pool
+			h.healthy = false
+			h.closed = true
+			h.Unlock()
+
+			//	the host is unlocked as releasing connections reads the pool
+			if pool != nil {
+				pool.Close()
+			}
+		}
+	})
+}
+
+//	candidates returns the hosts in the order connections should be acquired from them.
+//	The healthy hosts are ordered by the balance strategy and are followed by the failed
+//	hosts, which are only tried when none of the healthy hosts can be used.
+func (hp *hostPool) candidates() []*dbHost {
+	n := len(hp.hosts)
+	if n == 1 {
+		return hp.hosts
+	}
+
+	start := int(atomic.AddUint32(&hp.next, 1)-1) % n
+
+	var healthy, failed []*dbHost
+	for i := 0; i < n; i++ {
+		h := hp.hosts[(start+i)%n]
+		if pool, ok := h.connPool(); ok {
+			healthy = append(healthy, h)
+		} else if pool != nil {
+			failed = append(failed, h)
+		}
+	}
+
+	if hp.balance == BalanceLeastConnections {
+		//	the round robin order breaks ties
+		inUse := make(map[*dbHost]int, len(healthy))
+		for _, h := range healthy {
+			inUse[h] = h.inUse()
+		}
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return inUse[healthy[i]] < inUse[healthy[j]]
+		})
+	}
+
+	return append(healthy, failed...)
+}
+
+//	acquire returns a connection from one of the hosts along with the host it belongs to.
+//	A host that fails to hand out a connection is marked as failed and the next host is tried.
+func (hp *hostPool) acquire() (*dbHost, *pgx.Conn, error) {
+	var err error
+	for _, h := range hp.candidates() {
+		pool, _ := h.connPool()
+
+		var conn *pgx.Conn
+		if conn, err = pool.Acquire(); err != nil {
+			hp.failed(h, err)
+			continue
+		}
+
+		return h, conn, nil
+	}
+
+	if err == nil {
+		err = errors.New("no database hosts are available")
+	}
+	return nil, nil, err
+}
+
+//	failed marks the host as failed. A lone host is always used so it's never marked.
+func (hp *hostPool) failed(h *dbHost, cause error) {
+	if len(hp.hosts) > 1 {
+		h.setHealthy(false, cause)
+	}
+}
+
+//	release returns the connection to its host. If the connection was lost the host is marked as failed.
+func (hp *hostPool) release(h *dbHost, conn *pgx.Conn) {
+	if !conn.IsAlive() {
+		hp.failed(h, conn.CauseOfDeath())
+	}
+
+	pool, _ := h.connPool()
+	pool.Release(conn)
+}
+
+//	discard closes the connection and removes it from its host's pool without marking the host as failed
+func (hp *hostPool) discard(h *dbHost, conn *pgx.Conn) {
+	conn.Close()
+
+	pool, _ := h.connPool()
+	pool.Release(conn)
+}
@@ -0,0 +1,151 @@
+package postgis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+func TestHostPoolCandidates(t *testing.T) {
+	//	newHost returns a host. failed hosts have been connected to before
+	newHost := func(name string, healthy bool) *dbHost {
+		h := dbHost{
+			config:  pgx.ConnPoolConfig{ConnConfig: pgx.ConnConfig{Host: name, Port: DefaultPort}},
+			healthy: healthy,
+		}
+		if !healthy {
+			h.pool = new(pgx.ConnPool)
+		}
+		return &h
+	}
+
+	a, b, c := newHost("a", true), newHost("b", true), newHost("c", true)
+	down := newHost("down", false)
+	//	never connected to
+	unreachable := &dbHost{config: pgx.ConnPoolConfig{ConnConfig: pgx.ConnConfig{Host: "unreachable", Port: DefaultPort}}}
+
+	testcases := []struct {
+		hosts    []*dbHost
+		expected [][]*dbHost
+	}{
+		{
+			hosts: []*dbHost{a, b, c},
+			expected: [][]*dbHost{
+				{a, b, c},
+				{b, c, a},
+				{c, a, b},
+				{a, b, c},
+			},
+		},
+		//	failed hosts are tried last and hosts never connected to are not tried
+		{
+			hosts: []*dbHost{a, down, b, unreachable},
+			expected: [][]*dbHost{
+				{a, b, down},
+				{b, a, down},
+				{b, a, down},
+				{a, b, down},
+			},
+		},
+		//	a lone host is always used
+		{
+			hosts: []*dbHost{down},
+			expected: [][]*dbHost{
+				{down},
+				{down},
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		hp := hostPool{
+			hosts:   tc.hosts,
+			balance: BalanceRoundRobin,
+		}
+
+		for j, expected := range tc.expected {
+			output := hp.candidates()
+			if len(output) != len(expected) {
+				t.Errorf("testcase (%v) call (%v) failed. expected (%v) got (%v)", i, j, expected, output)
+				continue
+			}
+			for k := range expected {
+				if output[k] != expected[k] {
+					t.Errorf("testcase (%v) call (%v) failed. expected (%v) got (%v)", i, j, expected, output)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestHostPoolFailed(t *testing.T) {
+	a := &dbHost{healthy: true}
+	b := &dbHost{healthy: true}
+
+	//	a lone host is never marked as failed
+	hp := hostPool{hosts: []*dbHost{a}}
+	hp.failed(a, nil)
+	if !a.healthy {
+		t.Errorf("expected a lone host to stay healthy")
+	}
+
+	hp = hostPool{hosts: []*dbHost{a, b}}
+	hp.failed(a, nil)
+	if a.healthy {
+		t.Errorf("expected the host to be marked as failed")
+	}
+	if !b.healthy {
+		t.Errorf("expected the other host to stay healthy")
+	}
+}
+
+func TestNewHostPoolInvalidBalance(t *testing.T) {
+	if _, err := newHostPool(nil, "random", 0); err == nil {
+		t.Errorf("expected an error for an invalid %v", ConfigKeyBalance)
+	}
+}
+
+func TestHostPoolClose(t *testing.T) {
+	hp := hostPool{
+		hosts: []*dbHost{
+			{config: pgx.ConnPoolConfig{ConnConfig: pgx.ConnConfig{Host: "a", Port: DefaultPort}}},
+			{config: pgx.ConnPoolConfig{ConnConfig: pgx.ConnConfig{Host: "b", Port: DefaultPort}}},
+		},
+		balance: BalanceRoundRobin,
+		done:    make(chan struct{}),
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		hp.healthChecks(time.Hour)
+		close(stopped)
+	}()
+
+	hp.close()
+	//	closing again is a no-op
+	hp.close()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("expected the health checks to stop")
+	}
+
+	if err := hp.health(context.Background()); err == nil {
+		t.Errorf("expected a closed pool to be unhealthy")
+	}
+
+	//	a check racing with close does not connect to the host or bring it back
+	for _, h := range hp.hosts {
+		if err := h.check(); err != errHostPoolClosed {
+			t.Errorf("host (%v) expected err (%v) got (%v)", h, errHostPoolClosed, err)
+		}
+		h.setHealthy(true, nil)
+		if pool, healthy := h.connPool(); pool != nil || healthy {
+			t.Errorf("host (%v) expected no pool and unhealthy, got (%v, %v)", h, pool, healthy)
+		}
+	}
+}
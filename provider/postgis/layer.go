@@ -1,6 +1,11 @@
 package postgis
 
-import "github.com/airmap/tegola"
+import (
+	"fmt"
+	"time"
+
+	"github.com/airmap/tegola"
+)
 
 // layer holds information about a query.
 type Layer struct {
@@ -16,6 +21,35 @@ type Layer struct {
 	geomType tegola.Geometry
 	// The SRID that the data in the table is stored in. This will default to WebMercator
 	srid int
+	// The max duration the layer's SQL can run for. Zero means no limit.
+	queryTimeout time.Duration
+	// The request parameters the layer's SQL accepts keyed by name
+	params map[string]layerParam
+	// The types of the attributes returned by the layer's SQL keyed by field name
+	fields map[string]string
+	// How timestamp, array and JSON values are encoded into tags
+	formats valueFormats
+	// The SQL to use, instead of sql, for ranges of zooms. Ordered by zoom
+	variants []sqlVariant
+	// Set on the copy of a layer made for one of its variants
+	variant *sqlVariant
+	// Set if the layer is encoded by PostGIS, with ST_AsMVT, instead of tegola
+	nativeMVT bool
+}
+
+// sqlVariant is the SQL a layer uses for a range of zooms.
+type sqlVariant struct {
+	minZoom int
+	maxZoom int
+	sql     string
+}
+
+// layerParam is a request parameter declared by a layer.
+type layerParam struct {
+	// The type of the parameter. One of string, int, float or bool
+	typ string
+	// The value used when the request does not have the parameter. nil binds NULL
+	def interface{}
 }
 
 func (l Layer) Name() string {
@@ -30,6 +64,16 @@ func (l Layer) SRID() int {
 	return l.srid
 }
 
+//	Fields returns the layer's attribute names mapped to their type
+func (l Layer) Fields() map[string]string {
+	return l.fields
+}
+
+//	NativeMVT reports whether the layer is encoded by PostGIS
+func (l Layer) NativeMVT() bool {
+	return l.nativeMVT
+}
+
 func (l Layer) GeomFieldName() string {
 	return l.geomField
 }
@@ -37,3 +81,25 @@ func (l Layer) GeomFieldName() string {
 func (l Layer) IDFieldName() string {
 	return l.idField
 }
+
+//	statementName is the name of the layer's prepared statement
+func (l Layer) statementName() string {
+	if l.variant != nil {
+		return fmt.Sprintf("tegola_layer_%v_%v_%v", l.name, l.variant.minZoom, l.variant.maxZoom)
+	}
+	return "tegola_layer_" + l.name
+}
+
+//	forZoom returns the layer using the SQL for the zoom. false is returned if
+//	the layer has SQL variants and none of them are for the zoom.
+func (l Layer) forZoom(z int) (Layer, bool) {
+	for i := range l.variants {
+		if l.variants[i].minZoom <= z && z <= l.variants[i].maxZoom {
+			l.sql = l.variants[i].sql
+			l.variant = &l.variants[i]
+			return l, true
+		}
+	}
+
+	return l, len(l.variants) == 0
+}
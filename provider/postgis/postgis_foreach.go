@@ -7,7 +7,10 @@ import (
 	"os"
 	"strings"
 
+	"github.com/jackc/pgx"
+
 	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
 	"github.com/airmap/tegola/wkb"
 )
 
@@ -20,28 +23,50 @@ func (p *Provider) Layer(name string) (Layer, bool) {
 }
 
 func (p *Provider) ForEachFeature(ctx context.Context, layerName string, tile tegola.Tile, fn func(layer Layer, gid uint64, geom wkb.Geometry, tags map[string]interface{}) error) error {
+	return p.forEachFeature(ctx, nil, layerName, tile, fn)
+}
+
+//	forEachFeature runs the layer's SQL on the connection, or on a connection acquired from the pool if it's nil
+func (p *Provider) forEachFeature(ctx context.Context, conn *dbConn, layerName string, tile tegola.Tile, fn func(layer Layer, gid uint64, geom wkb.Geometry, tags map[string]interface{}) error) error {
 	plyr, ok := p.Layer(layerName)
 	if !ok {
 		return fmt.Errorf("layer (%v) not found ", layerName)
 	}
 
-	sql, err := replaceTokens(&plyr, tile)
+	//	pick the SQL for the tile's zoom
+	if plyr, ok = plyr.forZoom(tile.Z); !ok {
+		//	the layer has no features at this zoom
+		return nil
+	}
+
+	sql, args, err := replaceTokens(&plyr, tile, mvt.ParamsFromContext(ctx))
 	if err != nil {
 		return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
 	}
 
 	if strings.Contains(os.Getenv("SQL_DEBUG"), "EXECUTE_SQL") {
-		log.Printf("SQL_DEBUG:EXECUTE_SQL for layer (%v): %v", layerName, sql)
+		log.Printf("SQL_DEBUG:EXECUTE_SQL for layer (%v): %v args: %v", layerName, sql, args)
 	}
 
-	// do a quick context check:
-	if err := ctx.Err(); err != nil {
-		return err
+	if plyr.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, plyr.queryTimeout)
+		defer cancel()
 	}
 
-	rows, err := p.pool.Query(sql)
+	var rows *pgx.Rows
+	if conn != nil {
+		rows, err = conn.query(ctx, plyr.statementName(), sql, args...)
+	} else {
+		rows, err = p.query(ctx, plyr.statementName(), sql, args...)
+	}
 	if err != nil {
-		return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+		switch err {
+		case context.Canceled, context.DeadlineExceeded:
+			return err
+		default:
+			return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+		}
 	}
 	defer rows.Close()
 
@@ -60,7 +85,7 @@ func (p *Provider) ForEachFeature(ctx context.Context, layerName string, tile te
 			return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
 		}
 
-		gid, geobytes, tags, err := decipherFields(ctx, plyr.GeomFieldName(), plyr.IDFieldName(), fdescs, vals)
+		gid, geobytes, tags, err := decipherFields(ctx, plyr.GeomFieldName(), plyr.IDFieldName(), plyr.formats, fdescs, vals)
 		if err != nil {
 			switch err {
 			case context.Canceled:
@@ -79,5 +104,83 @@ func (p *Provider) ForEachFeature(ctx context.Context, layerName string, tile te
 			return err
 		}
 	}
+
+	if err := rows.Err(); err != nil {
+		//	a canceled statement errors on the server, report why it was canceled
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+	}
+
 	return nil
 }
+
+//	nativeLayer returns the layer encoded by PostGIS with ST_AsMVT, as a vector tile holding the layer. The
+//	layer's SQL is run on the connection, or on a connection acquired from the pool if it's nil.
+func (p *Provider) nativeLayer(ctx context.Context, conn *dbConn, layerName string, tile tegola.Tile) ([]byte, error) {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return nil, fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	//	pick the SQL for the tile's zoom
+	if plyr, ok = plyr.forZoom(tile.Z); !ok {
+		//	the layer has no features at this zoom
+		return []byte{}, nil
+	}
+
+	sql, args, err := replaceTokens(&plyr, tile, mvt.ParamsFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+	}
+	sql = asMVTSQL(&plyr, sql)
+
+	if strings.Contains(os.Getenv("SQL_DEBUG"), "EXECUTE_SQL") {
+		log.Printf("SQL_DEBUG:EXECUTE_SQL for layer (%v): %v args: %v", layerName, sql, args)
+	}
+
+	if plyr.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, plyr.queryTimeout)
+		defer cancel()
+	}
+
+	var rows *pgx.Rows
+	if conn != nil {
+		rows, err = conn.query(ctx, plyr.statementName(), sql, args...)
+	} else {
+		rows, err = p.query(ctx, plyr.statementName(), sql, args...)
+	}
+	if err != nil {
+		switch err {
+		case context.Canceled, context.DeadlineExceeded:
+			return nil, err
+		default:
+			return nil, fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+		}
+	}
+	defer rows.Close()
+
+	var data []byte
+	for rows.Next() {
+		if err = rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		//	a canceled statement errors on the server, report why it was canceled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error running layer (%v) SQL (%v): %v", layerName, sql, err)
+	}
+
+	//	ST_AsMVT returns NULL when there aren't any features
+	if data == nil {
+		data = []byte{}
+	}
+
+	return data, nil
+}
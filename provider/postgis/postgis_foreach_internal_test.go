@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/wkb"
@@ -78,3 +79,72 @@ func TestForEachFeature(t *testing.T) {
 		}
 	}
 }
+
+func TestForEachFeatureCanceled(t *testing.T) {
+	if os.Getenv("RUN_POSTGIS_TEST") != "yes" {
+		return
+	}
+
+	config := map[string]interface{}{
+		ConfigKeyHost:     "localhost",
+		ConfigKeyPort:     int64(5432),
+		ConfigKeyDB:       "tegola",
+		ConfigKeyUser:     "postgres",
+		ConfigKeyPassword: "",
+		ConfigKeyLayers: []map[string]interface{}{
+			{
+				ConfigKeyLayerName: "land",
+				ConfigKeySQL:       "SELECT gid, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank WHERE geom && !BBOX!",
+			},
+			{
+				ConfigKeyLayerName:    "slow_land",
+				ConfigKeyQueryTimeout: int64(1),
+				ConfigKeySQL:          "SELECT gid, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank, pg_sleep(30) WHERE geom && !BBOX!",
+			},
+		},
+	}
+
+	provider, err := NewProvider(config)
+	if err != nil {
+		t.Fatalf("unable to create a new provider. err: %v", err)
+	}
+	p := provider.(Provider)
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testcases := []struct {
+		ctx       context.Context
+		layerName string
+		expected  error
+	}{
+		//	the context is already canceled
+		{
+			ctx:       canceledCtx,
+			layerName: "land",
+			expected:  context.Canceled,
+		},
+		//	the layer's query_timeout cancels the statement
+		{
+			ctx:       context.Background(),
+			layerName: "slow_land",
+			expected:  context.DeadlineExceeded,
+		},
+	}
+
+	for i, tc := range testcases {
+		start := time.Now()
+
+		err := p.ForEachFeature(tc.ctx, tc.layerName, tegola.Tile{Z: 1, X: 1, Y: 1}, func(lyr Layer, gid uint64, wgeom wkb.Geometry, ftags map[string]interface{}) error {
+			return nil
+		})
+		if err != tc.expected {
+			t.Errorf("test (%v) failed. expected err (%v) got (%v)", i, tc.expected, err)
+		}
+
+		//	the statement should be canceled on the server rather than run to completion
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("test (%v) failed. expected the statement to be canceled, took (%v)", i, elapsed)
+		}
+	}
+}
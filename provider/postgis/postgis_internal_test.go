@@ -7,6 +7,7 @@ import (
 
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
 )
 
 func TestLayerGeomType(t *testing.T) {
@@ -45,18 +46,170 @@ func TestLayerGeomType(t *testing.T) {
 			continue
 		}
 
-		p := provider.(Provider)
-		layer := p.layers[tc.layerName]
-		if err := p.layerGeomType(&layer); err != nil {
-			t.Errorf("testcase (%v) failed on layerGeomType. err: %v", i, err)
+		layer := provider.(Provider).layers[tc.layerName]
+
+		expectedGeomType := reflect.TypeOf(tc.geom)
+		outputGeomType := reflect.TypeOf(layer.geomType)
+
+		if expectedGeomType != outputGeomType {
+			t.Errorf("testcase (%v) failed. output (%v) does not match expected (%v)", i, outputGeomType, expectedGeomType)
+		}
+	}
+}
+
+func TestLayerSchema(t *testing.T) {
+	if os.Getenv("RUN_POSTGIS_TEST") != "yes" {
+		return
+	}
+
+	testcases := []struct {
+		config    map[string]interface{}
+		layerName string
+		geom      tegola.Geometry
+		srid      int
+		fields    map[string]string
+	}{
+		{
+			config: map[string]interface{}{
+				ConfigKeyHost:     "localhost",
+				ConfigKeyPort:     int64(5432),
+				ConfigKeyDB:       "tegola",
+				ConfigKeyUser:     "postgres",
+				ConfigKeyPassword: "",
+				ConfigKeyLayers: []map[string]interface{}{
+					{
+						ConfigKeyLayerName: "land",
+						ConfigKeySQL:       "SELECT gid, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank WHERE geom && !BBOX!",
+					},
+				},
+			},
+			layerName: "land",
+			geom:      basic.MultiPolygon{},
+			srid:      tegola.WebMercator,
+			fields:    map[string]string{},
+		},
+		{
+			config: map[string]interface{}{
+				ConfigKeyHost:     "localhost",
+				ConfigKeyPort:     int64(5432),
+				ConfigKeyDB:       "tegola",
+				ConfigKeyUser:     "postgres",
+				ConfigKeyPassword: "",
+				ConfigKeyLayers: []map[string]interface{}{
+					{
+						ConfigKeyLayerName: "land",
+						ConfigKeyTablename: "ne_10m_land_scale_rank",
+						ConfigKeyFields:    []string{"gid", "scalerank", "featurecla"},
+					},
+				},
+			},
+			layerName: "land",
+			geom:      basic.MultiPolygon{},
+			srid:      tegola.WebMercator,
+			fields: map[string]string{
+				"scalerank":  mvt.FieldTypeNumber,
+				"featurecla": mvt.FieldTypeString,
+			},
+		},
+		//	the geometry is not a table column so the geometry type must be configured
+		{
+			config: map[string]interface{}{
+				ConfigKeyHost:     "localhost",
+				ConfigKeyPort:     int64(5432),
+				ConfigKeyDB:       "tegola",
+				ConfigKeyUser:     "postgres",
+				ConfigKeyPassword: "",
+				ConfigKeyLayers: []map[string]interface{}{
+					{
+						ConfigKeyLayerName: "land",
+						ConfigKeySQL:       "SELECT gid, ST_AsBinary(ST_Centroid(geom)) AS geom FROM ne_10m_land_scale_rank WHERE geom && !BBOX!",
+						ConfigKeyGeomType:  "point",
+					},
+				},
+			},
+			layerName: "land",
+			geom:      basic.Point{},
+			srid:      tegola.WebMercator,
+			fields:    map[string]string{},
+		},
+	}
+
+	for i, tc := range testcases {
+		provider, err := NewProvider(tc.config)
+		if err != nil {
+			t.Errorf("testcase (%v) failed on NewProvider. err: %v", i, err)
 			continue
 		}
 
+		layer := provider.(Provider).layers[tc.layerName]
+
 		expectedGeomType := reflect.TypeOf(tc.geom)
 		outputGeomType := reflect.TypeOf(layer.geomType)
 
 		if expectedGeomType != outputGeomType {
 			t.Errorf("testcase (%v) failed. output (%v) does not match expected (%v)", i, outputGeomType, expectedGeomType)
 		}
+
+		if layer.srid != tc.srid {
+			t.Errorf("testcase (%v) failed. expected srid (%v) got (%v)", i, tc.srid, layer.srid)
+		}
+
+		if !reflect.DeepEqual(layer.fields, tc.fields) {
+			t.Errorf("testcase (%v) failed. expected fields (%v) got (%v)", i, tc.fields, layer.fields)
+		}
+	}
+}
+
+func TestParseGeometryType(t *testing.T) {
+	testcases := []struct {
+		name      string
+		expected  tegola.Geometry
+		expectErr bool
+	}{
+		{name: "Point", expected: basic.Point{}},
+		{name: "linestring", expected: basic.Line{}},
+		{name: "MULTIPOLYGON", expected: basic.MultiPolygon{}},
+		{name: "GeometryCollection", expected: basic.Collection{}},
+		{name: "Geometry", expectErr: true},
+		{name: "circle", expectErr: true},
+	}
+
+	for i, tc := range testcases {
+		geom, err := parseGeometryType(tc.name)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if reflect.TypeOf(geom) != reflect.TypeOf(tc.expected) {
+			t.Errorf("testcase (%v) failed. expected (%T) got (%T)", i, tc.expected, geom)
+		}
+	}
+}
+
+func TestCatalogGeometryType(t *testing.T) {
+	testcases := []struct {
+		typ      string
+		expected tegola.Geometry
+	}{
+		{typ: "POINT", expected: basic.Point{}},
+		{typ: "POINTM", expected: basic.Point{}},
+		{typ: "MULTILINESTRINGZ", expected: basic.MultiLine{}},
+		{typ: "POLYGONZM", expected: basic.Polygon{}},
+		{typ: "GEOMETRY", expected: nil},
+		{typ: "GEOMETRYZ", expected: nil},
+	}
+
+	for i, tc := range testcases {
+		geom := catalogGeometryType(tc.typ)
+		if reflect.TypeOf(geom) != reflect.TypeOf(tc.expected) {
+			t.Errorf("testcase (%v) failed. expected (%T) got (%T)", i, tc.expected, geom)
+		}
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"context"
 
 	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
 	"github.com/airmap/tegola/provider/postgis"
 )
 
@@ -36,11 +37,21 @@ func TestNewProvider(t *testing.T) {
 	}
 
 	for i, tc := range testcases {
-		_, err := postgis.NewProvider(tc.config)
+		p, err := postgis.NewProvider(tc.config)
 		if err != nil {
 			t.Errorf("Failed test %v. Unable to create a new provider. err: %v", i, err)
 			return
 		}
+
+		if err = p.(mvt.HealthChecker).Health(context.Background()); err != nil {
+			t.Errorf("Failed test %v. Expected the provider to be healthy. err: %v", i, err)
+		}
+		if err = p.(mvt.Closer).Close(); err != nil {
+			t.Errorf("Failed test %v. Unable to close the provider. err: %v", i, err)
+		}
+		if err = p.(mvt.HealthChecker).Health(context.Background()); err == nil {
+			t.Errorf("Failed test %v. Expected a closed provider to be unhealthy", i)
+		}
 	}
 }
 
@@ -147,3 +158,106 @@ func TestMVTLayer(t *testing.T) {
 		}
 	}
 }
+
+func TestMVTLayers(t *testing.T) {
+	if os.Getenv("RUN_POSTGIS_TEST") != "yes" {
+		return
+	}
+
+	config := map[string]interface{}{
+		postgis.ConfigKeyHost:     "localhost",
+		postgis.ConfigKeyPort:     int64(5432),
+		postgis.ConfigKeyDB:       "tegola",
+		postgis.ConfigKeyUser:     "postgres",
+		postgis.ConfigKeyPassword: "",
+		postgis.ConfigKeyLayers: []map[string]interface{}{
+			{
+				postgis.ConfigKeyLayerName: "land",
+				postgis.ConfigKeyTablename: "ne_10m_land_scale_rank",
+			},
+			{
+				postgis.ConfigKeyLayerName: "scalerank",
+				postgis.ConfigKeySQL:       "SELECT gid, ST_AsBinary(geom) AS geom FROM ne_10m_land_scale_rank WHERE scalerank=!ZOOM! AND geom && !BBOX!",
+			},
+		},
+	}
+
+	p, err := postgis.NewProvider(config)
+	if err != nil {
+		t.Fatalf("Unable to create a new provider. err: %v", err)
+	}
+	defer p.(mvt.Closer).Close()
+
+	tile := tegola.Tile{Z: 1, X: 1, Y: 1}
+	layers, err := p.(mvt.BatchProvider).MVTLayers(context.Background(), []string{"scalerank", "land"}, tile, []map[string]interface{}{nil, {"class": "land"}})
+	if err != nil {
+		t.Fatalf("failed to create mvt layers err: %v", err)
+	}
+
+	//	the layers are returned in the requested order
+	expected := []struct {
+		name         string
+		featureCount int
+	}{
+		{"scalerank", 23},
+		{"land", 614},
+	}
+	if len(layers) != len(expected) {
+		t.Fatalf("expected %v layers got %v", len(expected), len(layers))
+	}
+	for i, e := range expected {
+		if layers[i].Name != e.name {
+			t.Errorf("layer (%v) name, expected %v got %v", i, e.name, layers[i].Name)
+		}
+		if len(layers[i].Features()) != e.featureCount {
+			t.Errorf("layer (%v) expected feature count (%v), got (%v)", i, e.featureCount, len(layers[i].Features()))
+		}
+	}
+	if f := layers[1].Features(); len(f) > 0 && f[0].Tags["class"] != "land" {
+		t.Errorf("expected the default tags to be added, got %v", f[0].Tags)
+	}
+}
+
+func TestNativeMVTLayer(t *testing.T) {
+	if os.Getenv("RUN_POSTGIS_TEST") != "yes" {
+		return
+	}
+
+	config := map[string]interface{}{
+		postgis.ConfigKeyHost:     "localhost",
+		postgis.ConfigKeyPort:     int64(5432),
+		postgis.ConfigKeyDB:       "tegola",
+		postgis.ConfigKeyUser:     "postgres",
+		postgis.ConfigKeyPassword: "",
+		postgis.ConfigKeyLayers: []map[string]interface{}{
+			{
+				postgis.ConfigKeyLayerName: "buildings",
+				postgis.ConfigKeyGeomField: "geometry",
+				postgis.ConfigKeyNativeMVT: true,
+				postgis.ConfigKeySQL:       "SELECT osm_id, name, ST_AsMVTGeom(geometry, !BBOX!, 4096, 16, true) AS geometry FROM osm_buildings_test WHERE geometry && !BBOX!",
+			},
+		},
+	}
+
+	p, err := postgis.NewProvider(config)
+	if err != nil {
+		t.Fatalf("Unable to create a new provider. err: %v", err)
+	}
+	defer p.(mvt.Closer).Close()
+
+	l, err := p.MVTLayer(context.Background(), "buildings", tegola.Tile{Z: 16, X: 11241, Y: 26168}, nil)
+	if err != nil {
+		t.Fatalf("failed to create mvt layer err: %v", err)
+	}
+	if len(l.Raw) == 0 {
+		t.Fatal("expected the layer to be encoded by PostGIS")
+	}
+
+	tile, err := mvt.Decode(l.Raw)
+	if err != nil {
+		t.Fatalf("failed to decode the layer err: %v", err)
+	}
+	if layers := tile.Layers(); len(layers) != 1 || len(layers[0].Features()) != 101 {
+		t.Errorf("expected 1 layer with 101 features, got %v", layers)
+	}
+}
@@ -0,0 +1,198 @@
+package postgis
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+const (
+	//	the code identifying a CancelRequest message. See https://www.postgresql.org/docs/current/static/protocol-flow.html#AEN112861
+	cancelRequestCode = 80877102
+	//	how long we wait on the server while sending a cancel request
+	cancelRequestTimeout = 10 * time.Second
+)
+
+//	query acquires a connection from one of the hosts and runs the sql on it (see dbConn.query).
+//	The connection is released back to the pool when the returned rows are closed.
+func (p Provider) query(ctx context.Context, name, sql string, args ...interface{}) (*pgx.Rows, error) {
+	conn, err := p.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.query(ctx, name, sql, args...)
+	if err != nil {
+		conn.release()
+		return nil, err
+	}
+	rows.AfterClose(func(*pgx.Rows) {
+		conn.release()
+	})
+
+	return rows, nil
+}
+
+//	dbConn is a connection acquired from one of the hosts for running one or more queries
+type dbConn struct {
+	pool *hostPool
+	host *dbHost
+	conn *pgx.Conn
+	//	set once a cancel request has been sent for a query on the connection
+	canceled bool
+	//	stops watching the context of the running query
+	stop func()
+}
+
+//	conn acquires a connection from one of the hosts. It must be released once its queries are done.
+func (p Provider) conn(ctx context.Context) (*dbConn, error) {
+	// do a quick context check:
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	host, conn, err := p.pool.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbConn{
+		pool: p.pool,
+		host: host,
+		conn: conn,
+	}, nil
+}
+
+//	query runs the sql on the connection. If a name is provided the sql is prepared on the connection,
+//	the first time the connection runs it, as a statement with that name so it's only parsed and
+//	planned once per connection. If the context is canceled, or its deadline passes, before the
+//	returned rows are closed a cancel request is sent to the server so the statement stops running
+//	instead of finishing for a client that has gone away. The rows must be closed before the
+//	connection runs another query.
+func (c *dbConn) query(ctx context.Context, name, sql string, args ...interface{}) (*pgx.Rows, error) {
+	// do a quick context check:
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	//	watch the context while the query is running
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			c.canceled = true
+			if err := cancelRequest(c.host.config.ConnConfig, c.conn.Pid, c.conn.SecretKey); err != nil {
+				log.Printf("error canceling SQL (%v): %v", sql, err)
+			}
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+	c.stop = stop
+
+	var err error
+	if name != "" {
+		//	prepare is a no-op if the connection already has the statement
+		if _, err = c.conn.Prepare(name, sql); err != nil {
+			stop()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		//	the connection runs the prepared statement when queried by its name
+		sql = name
+	}
+
+	rows, err := c.conn.Query(sql, args...)
+	if err != nil {
+		stop()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	rows.AfterClose(func(*pgx.Rows) {
+		stop()
+	})
+
+	return rows, nil
+}
+
+//	release puts the connection back in the pool
+func (c *dbConn) release() {
+	//	the running query's context must not be watched once the connection is back in the pool
+	if c.stop != nil {
+		c.stop()
+	}
+
+	//	the server processes cancel requests asynchronously so a canceled connection could
+	//	have the next query run on it canceled. don't put it back in the pool.
+	if c.canceled {
+		c.pool.discard(c.host, c.conn)
+		return
+	}
+	c.pool.release(c.host, c.conn)
+}
+
+//	cancelRequest asks the server to cancel the statement running on the backend with the provided
+//	pid. The request is sent on a new connection as described by the PostgreSQL protocol.
+func cancelRequest(cfg pgx.ConnConfig, pid, secretKey int32) error {
+	//	use the same network and address pgx uses to connect
+	network, address := "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port)))
+	if _, err := os.Stat(cfg.Host); err == nil {
+		network, address = "unix", cfg.Host
+		if !strings.Contains(address, "/.s.PGSQL.") {
+			address = filepath.Join(address, ".s.PGSQL.") + strconv.Itoa(int(cfg.Port))
+		}
+	}
+
+	dial := cfg.Dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: cancelRequestTimeout}).Dial
+	}
+
+	conn, err := dial(network, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(cancelRequestTimeout)); err != nil {
+		return err
+	}
+
+	msg := make([]byte, 16)
+	binary.BigEndian.PutUint32(msg[0:4], 16)
+	binary.BigEndian.PutUint32(msg[4:8], cancelRequestCode)
+	binary.BigEndian.PutUint32(msg[8:12], uint32(pid))
+	binary.BigEndian.PutUint32(msg[12:16], uint32(secretKey))
+
+	if _, err = conn.Write(msg); err != nil {
+		return fmt.Errorf("error sending cancel request: %v", err)
+	}
+
+	//	the server does not reply, it closes the connection once it has processed the request
+	conn.Read(msg)
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package postgis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx"
+)
+
+func TestCancelRequest(t *testing.T) {
+	//	stand in for the server, recording the cancel request message
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		msg, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- msg
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	cfg := pgx.ConnConfig{
+		Host: "127.0.0.1",
+		Port: uint16(addr.Port),
+	}
+
+	//	close our side once the message is written so the stand in server's read completes
+	cfg.Dial = func(network, address string) (net.Conn, error) {
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return closeWriteConn{conn.(*net.TCPConn)}, nil
+	}
+
+	if err := cancelRequest(cfg, 1234, -5678); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := make([]byte, 16)
+	binary.BigEndian.PutUint32(expected[0:4], 16)
+	binary.BigEndian.PutUint32(expected[4:8], cancelRequestCode)
+	binary.BigEndian.PutUint32(expected[8:12], 1234)
+	binary.BigEndian.PutUint32(expected[12:16], uint32(0xffffe9d2))
+
+	if msg := <-received; !bytes.Equal(msg, expected) {
+		t.Errorf("expected cancel request (%v) got (%v)", expected, msg)
+	}
+}
+
+//	closeWriteConn closes the write side of the connection after every write
+type closeWriteConn struct {
+	*net.TCPConn
+}
+
+func (c closeWriteConn) Write(b []byte) (int, error) {
+	n, err := c.TCPConn.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, c.TCPConn.CloseWrite()
+}
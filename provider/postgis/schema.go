@@ -0,0 +1,211 @@
+package postgis
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
+)
+
+//	SQL to look up the geometry type and SRID of a table column in the geometry_columns view.
+//	The column is identified by its table OID and attribute number as reported in a statement description.
+const geomColumnSQL = `SELECT gc.type, gc.srid
+FROM geometry_columns gc
+JOIN pg_namespace n ON n.nspname = gc.f_table_schema
+JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = gc.f_table_name
+JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = gc.f_geometry_column
+WHERE c.oid = $1::oid AND a.attnum = $2::int2`
+
+//	geometryTypes maps the geometry type names used by geometry_columns and
+//	the geometry_type config key to their geometries
+var geometryTypes = map[string]tegola.Geometry{
+	"POINT":              basic.Point{},
+	"LINESTRING":         basic.Line{},
+	"POLYGON":            basic.Polygon{},
+	"MULTIPOINT":         basic.MultiPoint{},
+	"MULTILINESTRING":    basic.MultiLine{},
+	"MULTIPOLYGON":       basic.MultiPolygon{},
+	"GEOMETRYCOLLECTION": basic.Collection{},
+}
+
+//	fieldTypes maps the PostgreSQL data type names to the type of the tag they are decoded into.
+//	Types not in the map are decoded as strings.
+var fieldTypes = map[string]string{
+	"bool":    mvt.FieldTypeBoolean,
+	"int2":    mvt.FieldTypeNumber,
+	"int4":    mvt.FieldTypeNumber,
+	"int8":    mvt.FieldTypeNumber,
+	"float4":  mvt.FieldTypeNumber,
+	"float8":  mvt.FieldTypeNumber,
+	"numeric": mvt.FieldTypeNumber,
+	"oid":     mvt.FieldTypeNumber,
+}
+
+//	asBinaryRegexp matches the call wrapping the geometry column in the layer SQL
+var asBinaryRegexp = regexp.MustCompile(`(?i)st_asbinary\s*\(`)
+
+//	parseGeometryType returns the geometry for a geometry_type config value (i.e. MultiPolygon)
+func parseGeometryType(name string) (tegola.Geometry, error) {
+	geom, ok := geometryTypes[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("%v (%v) is not supported. expected one of Point, LineString, Polygon, MultiPoint, MultiLineString, MultiPolygon or GeometryCollection", ConfigKeyGeomType, name)
+	}
+
+	return geom, nil
+}
+
+//	layerSchema sets the geometry type, SRID and fields of the layer from the database catalog without running
+//	the layer's SQL. The SQL is prepared, as an unnamed statement, with the ST_AsBinary call around the geometry
+//	column removed so the statement description reports the table column the geometry comes from. The column is
+//	then looked up in geometry_columns. If the geometry is not a plain table column (i.e. it's the result of
+//	ST_Transform) or the column is untyped, the geometry type is left unknown unless geometryType is set.
+//	The SRID is only read from the catalog when sridSet is false.
+func (p Provider) layerSchema(l *Layer, geomType tegola.Geometry, sridSet bool) error {
+	//	we need a tile to run our sql through the replacer
+	sql, _, err := replaceTokens(l, tegola.Tile{Z: 0, X: 0, Y: 0}, nil)
+	if err != nil {
+		return err
+	}
+
+	host, conn, err := p.pool.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.pool.release(host, conn)
+
+	//	describe the layer SQL to read the fields
+	ps, err := conn.Prepare("", sql)
+	if err != nil {
+		return fmt.Errorf("error preparing SQL: %v ; %v", sql, err)
+	}
+	l.fields = layerFields(l, ps.FieldDescriptions)
+
+	l.geomType = geomType
+	if geomType != nil && sridSet {
+		return nil
+	}
+
+	//	describe the SQL again with the raw geometry column to find where it comes from
+	loc := asBinaryRegexp.FindStringIndex(sql)
+	if loc == nil {
+		return nil
+	}
+	if ps, err = conn.Prepare("", sql[:loc[0]]+"("+sql[loc[1]:]); err != nil {
+		//	the SQL does not work without ST_AsBinary (i.e. ST_AsBinary was called with more than one argument)
+		log.Printf("unable to resolve the geometry column of layer (%v) from its SQL: %v", l.name, err)
+		return nil
+	}
+
+	var fdesc *pgx.FieldDescription
+	for i := range ps.FieldDescriptions {
+		if ps.FieldDescriptions[i].Name == l.geomField {
+			fdesc = &ps.FieldDescriptions[i]
+			break
+		}
+	}
+	if fdesc == nil || fdesc.Table == 0 {
+		log.Printf("the geometry of layer (%v) is not a table column. set %v to publish its geometry type", l.name, ConfigKeyGeomType)
+		return nil
+	}
+
+	var typ string
+	var srid int32
+	if err = conn.QueryRow(geomColumnSQL, fdesc.Table, fdesc.AttributeNumber).Scan(&typ, &srid); err != nil {
+		if err == pgx.ErrNoRows {
+			log.Printf("the geometry column of layer (%v) is not in geometry_columns. set %v to publish its geometry type", l.name, ConfigKeyGeomType)
+			return nil
+		}
+		return fmt.Errorf("error reading geometry_columns: %v", err)
+	}
+
+	if geomType == nil {
+		l.geomType = catalogGeometryType(typ)
+	}
+	if !sridSet && srid > 0 {
+		l.srid = int(srid)
+	}
+
+	return nil
+}
+
+//	catalogGeometryType returns the geometry for a geometry_columns type. nil is returned for
+//	columns that can hold any type of geometry (i.e. GEOMETRY).
+func catalogGeometryType(typ string) tegola.Geometry {
+	//	measured and 3D types are suffixed with M, Z or ZM (i.e. POINTZM)
+	typ = strings.ToUpper(typ)
+	for _, suffix := range []string{"ZM", "Z", "M"} {
+		if geom, ok := geometryTypes[strings.TrimSuffix(typ, suffix)]; ok {
+			return geom
+		}
+	}
+
+	return nil
+}
+
+//	layerFields returns the attribute schema of the layer from the fields its SQL returns. The geometry
+//	and id fields are not attributes and fields encoded with dynamic keys (i.e. hstore) are skipped.
+func layerFields(l *Layer, fdescs []pgx.FieldDescription) map[string]string {
+	fields := map[string]string{}
+
+	for _, fdesc := range fdescs {
+		switch {
+		case fdesc.Name == l.geomField, fdesc.Name == l.idField, l.formats.dynamicKeys(fdesc):
+			continue
+		}
+
+		typ, ok := fieldTypes[fdesc.DataTypeName]
+		switch {
+		case fdesc.DataType == pgx.DateOid, fdesc.DataType == pgx.TimestampOid, fdesc.DataType == pgx.TimestampTzOid:
+			if l.formats.timestamp == TimestampFormatEpoch {
+				typ = mvt.FieldTypeNumber
+			} else {
+				typ = mvt.FieldTypeString
+			}
+		case !ok:
+			typ = mvt.FieldTypeString
+		}
+		fields[fdesc.Name] = typ
+	}
+
+	return fields
+}
+
+//	variantsSchema sets the geometry type, SRID and fields of a layer with SQL variants. The fields of all the
+//	variants are published and the geometry type is only known when all the variants agree on it.
+func (p Provider) variantsSchema(l *Layer, geomType tegola.Geometry, sridSet bool) error {
+	fields := map[string]string{}
+
+	for i := range l.variants {
+		vl, _ := l.forZoom(l.variants[i].minZoom)
+		if err := p.layerSchema(&vl, geomType, sridSet); err != nil {
+			return fmt.Errorf("zooms %v-%v: %v", l.variants[i].minZoom, l.variants[i].maxZoom, err)
+		}
+
+		switch {
+		case i == 0:
+			l.geomType, l.srid = vl.geomType, vl.srid
+		case vl.srid != l.srid:
+			//	the bounding box tokens are in the layer's srid
+			return fmt.Errorf("zooms %v-%v have SRID (%v) while zooms %v-%v have SRID (%v). set %v", l.variants[i].minZoom, l.variants[i].maxZoom, vl.srid,
+				l.variants[0].minZoom, l.variants[0].maxZoom, l.srid, ConfigKeySRID)
+		case reflect.TypeOf(vl.geomType) != reflect.TypeOf(l.geomType):
+			l.geomType = nil
+		}
+
+		for name, typ := range vl.fields {
+			if _, ok := fields[name]; !ok {
+				fields[name] = typ
+			}
+		}
+	}
+	l.fields = fields
+
+	return nil
+}
@@ -4,20 +4,32 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/maths/makevalid"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/util/dict"
 )
 
 // genSQL will fill in the SQL field of a layer given a pool, and list of fields.
-func genSQL(l *Layer, pool *pgx.ConnPool, tblname string, flds []string) (sql string, err error) {
+func genSQL(l *Layer, pool *hostPool, tblname string, flds []string) (sql string, err error) {
 
 	if len(flds) == 0 {
 		// We need to hit the database to see what the fields are.
-		rows, err := pool.Query(fmt.Sprintf(fldsSQL, tblname))
+		host, conn, err := pool.acquire()
+		if err != nil {
+			return "", err
+		}
+		defer pool.release(host, conn)
+
+		rows, err := conn.Query(fmt.Sprintf(fldsSQL, tblname))
 		if err != nil {
 			return "", err
 		}
@@ -66,41 +78,319 @@ func genSQL(l *Layer, pool *pgx.ConnPool, tblname string, flds []string) (sql st
 }
 
 const (
-	bboxToken = "!BBOX!"
-	zoomToken = "!ZOOM!"
+	bboxToken             = "!BBOX!"
+	bboxBufferedToken     = "!BBOX_BUFFERED!"
+	zoomToken             = "!ZOOM!"
+	xToken                = "!X!"
+	yToken                = "!Y!"
+	pixelWidthToken       = "!PIXEL_WIDTH!"
+	scaleDenominatorToken = "!SCALE_DENOMINATOR!"
+	//	request parameter tokens are in the format !PARAM:name!
+	paramTokenPrefix = "PARAM:"
+)
+
+const (
+	//	the size, in pixels, of the tiles the pixel width and scale denominator are calculated for
+	tilePixels = 256
+	//	the size of a pixel in meters as defined by the OGC standardized rendering pixel size
+	pixelSize = 0.00028
+)
+
+var (
+	//	tokenRegexp matches the tokens supported in a layer's SQL
+	tokenRegexp = regexp.MustCompile(strings.Join([]string{
+		bboxToken,
+		bboxBufferedToken,
+		zoomToken,
+		xToken,
+		yToken,
+		pixelWidthToken,
+		scaleDenominatorToken,
+	}, "|"))
+
+	//	paramTokenRegexp matches the request parameter tokens, !PARAM:name!, capturing the parameter name
+	paramTokenRegexp = regexp.MustCompile(`!` + paramTokenPrefix + `([A-Za-z0-9_]+)!`)
+
+	//	allTokensRegexp matches the supported tokens and the request parameter tokens
+	allTokensRegexp = regexp.MustCompile(tokenRegexp.String() + "|" + paramTokenRegexp.String())
+
+	//	anyTokenRegexp matches anything that looks like a token
+	anyTokenRegexp = regexp.MustCompile(`![A-Z_]+!`)
+
+	//	paramNameRegexp matches valid request parameter names
+	paramNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+	//	paramTypes maps the supported request parameter types to the PostgreSQL type they are bound as
+	paramTypes = map[string]string{
+		"string": "text",
+		"int":    "bigint",
+		"float":  "float8",
+		"bool":   "boolean",
+	}
 )
 
-//	replaceTokens replaces tokens in the provided SQL string
+//	parseParam converts the value of a request parameter to the parameter's type
+func parseParam(typ, val string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return val, nil
+	case "int":
+		return strconv.ParseInt(val, 10, 64)
+	case "float":
+		return strconv.ParseFloat(val, 64)
+	case "bool":
+		return strconv.ParseBool(val)
+	default:
+		return nil, fmt.Errorf("unsupported param type (%v)", typ)
+	}
+}
+
+//	parseLayerParams reads the request parameters declared in a layer's config
+func parseLayerParams(config []map[string]interface{}) (map[string]layerParam, error) {
+	params := make(map[string]layerParam, len(config))
+
+	for i, v := range config {
+		vc := dict.M(v)
+
+		name, err := vc.String(ConfigKeyParamName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("param (%v) %v", i, err)
+		}
+		if !paramNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("param (%v) name (%v) can only contain letters, digits and underscores", i, name)
+		}
+		if _, ok := params[name]; ok {
+			return nil, fmt.Errorf("param (%v) name (%v) is duplicated", i, name)
+		}
+
+		typ := "string"
+		if typ, err = vc.String(ConfigKeyParamType, &typ); err != nil {
+			return nil, fmt.Errorf("param (%v) %v", name, err)
+		}
+		if _, ok := paramTypes[typ]; !ok {
+			return nil, fmt.Errorf("param (%v) has unsupported type (%v)", name, typ)
+		}
+
+		lp := layerParam{typ: typ}
+		if def, ok := v[ConfigKeyParamDefault]; ok && def != nil {
+			if lp.def, err = parseParam(typ, fmt.Sprint(def)); err != nil {
+				return nil, fmt.Errorf("param (%v) %v (%v) is not a valid %v: %v", name, ConfigKeyParamDefault, def, typ, err)
+			}
+		}
+
+		params[name] = lp
+	}
+
+	return params, nil
+}
+
+//	parseSQLVariants reads the zoom ranges and their SQL from a layer's zoom_sql config.
+//	The variants are returned ordered by zoom and can not overlap.
+func parseSQLVariants(config []map[string]interface{}) ([]sqlVariant, error) {
+	if len(config) == 0 {
+		return nil, fmt.Errorf("%v can not be empty", ConfigKeyZoomSQL)
+	}
+
+	variants := make([]sqlVariant, 0, len(config))
+	for i, v := range config {
+		vc := dict.M(v)
+
+		minZoom, err := vc.Int64(ConfigKeyMinZoom, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%v (%v) %v", ConfigKeyZoomSQL, i, err)
+		}
+		maxZoom, err := vc.Int64(ConfigKeyMaxZoom, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%v (%v) %v", ConfigKeyZoomSQL, i, err)
+		}
+		if minZoom < 0 || maxZoom < minZoom {
+			return nil, fmt.Errorf("%v (%v) has an invalid zoom range (%v-%v)", ConfigKeyZoomSQL, i, minZoom, maxZoom)
+		}
+
+		sql, err := vc.String(ConfigKeySQL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%v (%v) %v", ConfigKeyZoomSQL, i, err)
+		}
+
+		variants = append(variants, sqlVariant{
+			minZoom: int(minZoom),
+			maxZoom: int(maxZoom),
+			sql:     sql,
+		})
+	}
+
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].minZoom < variants[j].minZoom
+	})
+	for i := 1; i < len(variants); i++ {
+		if variants[i].minZoom <= variants[i-1].maxZoom {
+			return nil, fmt.Errorf("%v zoom ranges (%v-%v) and (%v-%v) overlap", ConfigKeyZoomSQL,
+				variants[i-1].minZoom, variants[i-1].maxZoom, variants[i].minZoom, variants[i].maxZoom)
+		}
+	}
+
+	return variants, nil
+}
+
+//	validateSQL checks the tokens of a layer's SQL and, unless it selects *, that it selects the geometry and id fields
+func validateSQL(sql, geomField, idField string, params map[string]layerParam) error {
+	// make sure that the sql has a !BBOX! token and only supported tokens
+	if err := validateTokens(sql, params); err != nil {
+		return err
+	}
+	if !strings.Contains(sql, "*") {
+		if !strings.Contains(sql, geomField) {
+			return fmt.Errorf("does not contain the geometry field: %v", geomField)
+		}
+		if !strings.Contains(sql, idField) {
+			return fmt.Errorf("does not contain the id field for the geometry: %v", idField)
+		}
+	}
+
+	return nil
+}
+
+//	validateTokens checks that the SQL contains one of the bounding box tokens, no unsupported tokens
+//	and that the request parameter tokens reference the declared params
+func validateTokens(sql string, params map[string]layerParam) error {
+	if !strings.Contains(sql, bboxToken) && !strings.Contains(sql, bboxBufferedToken) {
+		return fmt.Errorf("missing required token: %v or %v", bboxToken, bboxBufferedToken)
+	}
+
+	for _, token := range anyTokenRegexp.FindAllString(sql, -1) {
+		if !tokenRegexp.MatchString(token) {
+			return fmt.Errorf("unsupported token: %v", token)
+		}
+	}
+
+	for _, match := range paramTokenRegexp.FindAllStringSubmatch(sql, -1) {
+		if _, ok := params[match[1]]; !ok {
+			return fmt.Errorf("token %v references a param that is not declared", match[0])
+		}
+	}
+
+	return nil
+}
+
+//	asMVTSQL wraps the layer's SQL, with its tokens replaced, so PostGIS encodes the selected
+//	features into a vector tile layer named after the layer
+func asMVTSQL(l *Layer, sql string) string {
+	return fmt.Sprintf(`SELECT ST_AsMVT(q, %v, %v, %v) FROM (%v) AS q`, quoteLiteral(l.name), tegola.DefaultExtent, quoteLiteral(l.geomField), sql)
+}
+
+//	quoteLiteral quotes the string as a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+//	envelope returns the bounding box in the provided srid
+func envelope(srid int, minx, miny, maxx, maxy float64) (minPt, maxPt basic.Point, err error) {
+	minGeo, err := basic.FromWebMercator(srid, basic.Point{minx, miny})
+	if err != nil {
+		return minPt, maxPt, fmt.Errorf("Error trying to convert tile point: %v ", err)
+	}
+	maxGeo, err := basic.FromWebMercator(srid, basic.Point{maxx, maxy})
+	if err != nil {
+		return minPt, maxPt, fmt.Errorf("Error trying to convert tile point: %v ", err)
+	}
+
+	return minGeo.AsPoint(), maxGeo.AsPoint(), nil
+}
+
+//	replaceTokens rewrites the tokens in the layer's SQL into positional parameters ($1, $2, ...)
+//	and returns the SQL along with the parameter values for the tile. The SQL is the same for
+//	every tile so it can be prepared once and the statement reused.
 //
 //	!BBOX! - the bounding box of the tile
+//	!BBOX_BUFFERED! - the bounding box of the tile expanded by the tile buffer used when clipping geometries
 //	!ZOOM! - the tile Z value
-func replaceTokens(plyr *Layer, tile tegola.Tile) (string, error) {
+//	!X! - the tile X value
+//	!Y! - the tile Y value
+//	!PIXEL_WIDTH! - the width of a pixel, for 256 pixel tiles, in the units of the layer's SRID
+//	!SCALE_DENOMINATOR! - the scale denominator of the zoom, for 256 pixel tiles
+//	!PARAM:name! - the value of the request parameter, or the parameter's default when the request does not have it
+func replaceTokens(plyr *Layer, tile tegola.Tile, params mvt.Params) (string, []interface{}, error) {
 
 	textent := tile.BoundingBox()
 
-	minGeo, err := basic.FromWebMercator(plyr.srid, basic.Point{textent.Minx, textent.Miny})
+	minPt, maxPt, err := envelope(plyr.srid, textent.Minx, textent.Miny, textent.Maxx, textent.Maxy)
 	if err != nil {
-		return "", fmt.Errorf("Error trying to convert tile point: %v ", err)
-	}
-	maxGeo, err := basic.FromWebMercator(plyr.srid, basic.Point{textent.Maxx, textent.Maxy})
-	if err != nil {
-		return "", fmt.Errorf("Error trying to convert tile point: %v ", err)
+		return "", nil, err
 	}
 
-	minPt, maxPt := minGeo.AsPoint(), maxGeo.AsPoint()
+	var args []interface{}
+	//	tokens can be used more than once. each token is bound once and its parameters reused.
+	bound := map[string]string{}
 
-	bbox := fmt.Sprintf("ST_MakeEnvelope(%v,%v,%v,%v,%v)", minPt.X(), minPt.Y(), maxPt.X(), maxPt.Y(), plyr.srid)
+	sql := allTokensRegexp.ReplaceAllStringFunc(plyr.sql, func(token string) string {
+		if param, ok := bound[token]; ok {
+			return param
+		}
 
-	//	replace query string tokens
-	tokenReplacer := strings.NewReplacer(
-		bboxToken, bbox,
-		zoomToken, strconv.Itoa(tile.Z),
-	)
+		switch token {
+		case bboxToken:
+			args = append(args, minPt.X(), minPt.Y(), maxPt.X(), maxPt.Y())
+			n := len(args)
+			bound[token] = fmt.Sprintf("ST_MakeEnvelope($%v,$%v,$%v,$%v,%v)", n-3, n-2, n-1, n, plyr.srid)
+		case bboxBufferedToken:
+			buffer := (textent.Maxx - textent.Minx) * makevalid.TileBuffer / tegola.DefaultExtent
+			//	the tile's y axis is flipped, Miny is the top of the tile
+			bminPt, bmaxPt, berr := envelope(plyr.srid, textent.Minx-buffer, textent.Miny+buffer, textent.Maxx+buffer, textent.Maxy-buffer)
+			if berr != nil {
+				err = berr
+				return token
+			}
+			args = append(args, bminPt.X(), bminPt.Y(), bmaxPt.X(), bmaxPt.Y())
+			n := len(args)
+			bound[token] = fmt.Sprintf("ST_MakeEnvelope($%v,$%v,$%v,$%v,%v)", n-3, n-2, n-1, n, plyr.srid)
+		case zoomToken:
+			args = append(args, tile.Z)
+			bound[token] = fmt.Sprintf("$%v::integer", len(args))
+		case xToken:
+			args = append(args, tile.X)
+			bound[token] = fmt.Sprintf("$%v::integer", len(args))
+		case yToken:
+			args = append(args, tile.Y)
+			bound[token] = fmt.Sprintf("$%v::integer", len(args))
+		case pixelWidthToken:
+			args = append(args, (maxPt.X()-minPt.X())/tilePixels)
+			bound[token] = fmt.Sprintf("$%v::float8", len(args))
+		case scaleDenominatorToken:
+			args = append(args, tile.ZRes()/pixelSize)
+			bound[token] = fmt.Sprintf("$%v::float8", len(args))
+		default:
+			//	request parameter
+			name := paramTokenRegexp.FindStringSubmatch(token)[1]
+			lp, ok := plyr.params[name]
+			if !ok {
+				err = fmt.Errorf("token %v references a param that is not declared", token)
+				return token
+			}
 
-	return tokenReplacer.Replace(plyr.sql), nil
+			val := lp.def
+			if v, ok := params[name]; ok {
+				var perr error
+				if val, perr = parseParam(lp.typ, v); perr != nil {
+					err = fmt.Errorf("param (%v) value (%v) is not a valid %v: %v", name, v, lp.typ, perr)
+					return token
+				}
+			}
+
+			args = append(args, val)
+			bound[token] = fmt.Sprintf("$%v::%v", len(args), paramTypes[lp.typ])
+		}
+
+		return bound[token]
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sql, args, nil
 }
 
-func transformVal(valType pgx.Oid, val interface{}) (interface{}, error) {
+//	transformVal converts the value of a scalar field into a tag value
+func (f valueFormats) transformVal(valType pgx.Oid, val interface{}) (interface{}, error) {
 	switch valType {
 	default:
 		switch vt := val.(type) {
@@ -112,8 +402,10 @@ func transformVal(valType pgx.Oid, val interface{}) (interface{}, error) {
 		case string:
 			return vt, nil
 		}
-	case pgx.BoolOid, pgx.ByteaOid, pgx.TextOid, pgx.OidOid, pgx.VarcharOid, pgx.JsonbOid:
+	case pgx.BoolOid, pgx.ByteaOid, pgx.TextOid, pgx.OidOid, pgx.VarcharOid:
 		return val, nil
+	case pgx.UuidOid:
+		return fmt.Sprintf("%v", val), nil
 	case pgx.Int8Oid, pgx.Int2Oid, pgx.Int4Oid, pgx.Float4Oid, pgx.Float8Oid:
 		switch vt := val.(type) {
 		case int8:
@@ -138,11 +430,16 @@ func transformVal(valType pgx.Oid, val interface{}) (interface{}, error) {
 			return nil, fmt.Errorf("%v type is not supported. (should never happen)", valType)
 		}
 	case pgx.DateOid, pgx.TimestampOid, pgx.TimestampTzOid:
-		return fmt.Sprintf("%v", val), nil
+		t, ok := val.(time.Time)
+		if !ok {
+			//	i.e. infinity
+			return fmt.Sprintf("%v", val), nil
+		}
+		return f.encodeTime(t, valType == pgx.DateOid), nil
 	}
 }
 
-func decipherFields(ctx context.Context, geoFieldname, idFieldname string, descriptions []pgx.FieldDescription, values []interface{}) (gid uint64, geom []byte, tags map[string]interface{}, err error) {
+func decipherFields(ctx context.Context, geoFieldname, idFieldname string, formats valueFormats, descriptions []pgx.FieldDescription, values []interface{}) (gid uint64, geom []byte, tags map[string]interface{}, err error) {
 	tags = make(map[string]interface{})
 	var desc pgx.FieldDescription
 	var ok bool
@@ -191,11 +488,9 @@ func decipherFields(ctx context.Context, geoFieldname, idFieldname string, descr
 				tags[desc.Name] = num
 				continue
 			default:
-				value, err := transformVal(desc.DataType, v)
-				if err != nil {
-					return gid, geom, tags, fmt.Errorf("Unable to convert field[%v] (%v) of type (%v - %v) to a suitable value.: [[ %T  :: %[5]t ]]", i, desc.Name, desc.DataType, desc.DataTypeName, v)
+				if err := formats.setTags(tags, desc, v); err != nil {
+					return gid, geom, tags, fmt.Errorf("Unable to convert field[%v] (%v) of type (%v - %v) to a suitable value.: [[ %T  :: %[5]t ]] %v", i, desc.Name, desc.DataType, desc.DataTypeName, v, err)
 				}
-				tags[desc.Name] = value
 			}
 		}
 	}
@@ -1,16 +1,20 @@
 package postgis
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/mvt"
 )
 
 func TestReplaceTokens(t *testing.T) {
 	testcases := []struct {
 		layer    Layer
 		tile     tegola.Tile
+		params   mvt.Params
 		expected string
+		args     []interface{}
 	}{
 		{
 			layer: Layer{
@@ -22,7 +26,8 @@ func TestReplaceTokens(t *testing.T) {
 				X: 1,
 				Y: 1,
 			},
-			expected: "SELECT * FROM foo WHERE geom && ST_MakeEnvelope(-1.001875417e+07,1.001875417e+07,0,0,3857)",
+			expected: "SELECT * FROM foo WHERE geom && ST_MakeEnvelope($1,$2,$3,$4,3857)",
+			args:     []interface{}{-1.001875417e+07, 1.001875417e+07, 0.0, 0.0},
 		},
 		{
 			layer: Layer{
@@ -34,12 +39,60 @@ func TestReplaceTokens(t *testing.T) {
 				X: 1,
 				Y: 1,
 			},
-			expected: "SELECT id, scalerank=2 FROM foo WHERE geom && ST_MakeEnvelope(-1.001875417e+07,1.001875417e+07,0,0,3857)",
+			expected: "SELECT id, scalerank=$1::integer FROM foo WHERE geom && ST_MakeEnvelope($2,$3,$4,$5,3857)",
+			args:     []interface{}{2, -1.001875417e+07, 1.001875417e+07, 0.0, 0.0},
+		},
+		//	tokens used more than once are bound once
+		{
+			layer: Layer{
+				sql:  "SELECT id FROM foo WHERE geom && !BBOX! AND ST_Intersects(geom, !BBOX!) AND min_zoom <= !ZOOM! AND max_zoom >= !ZOOM!",
+				srid: tegola.WebMercator,
+			},
+			tile: tegola.Tile{
+				Z: 2,
+				X: 1,
+				Y: 1,
+			},
+			expected: "SELECT id FROM foo WHERE geom && ST_MakeEnvelope($1,$2,$3,$4,3857) AND ST_Intersects(geom, ST_MakeEnvelope($1,$2,$3,$4,3857)) AND min_zoom <= $5::integer AND max_zoom >= $5::integer",
+			args:     []interface{}{-1.001875417e+07, 1.001875417e+07, 0.0, 0.0, 2},
+		},
+		{
+			layer: Layer{
+				sql:  "SELECT id FROM foo WHERE geom && !BBOX_BUFFERED! AND x = !X! AND y = !Y! AND ST_Length(geom) > !PIXEL_WIDTH! AND !SCALE_DENOMINATOR! < 1000000",
+				srid: tegola.WebMercator,
+			},
+			tile: tegola.Tile{
+				Z: 2,
+				X: 1,
+				Y: 1,
+			},
+			expected: "SELECT id FROM foo WHERE geom && ST_MakeEnvelope($1,$2,$3,$4,3857) AND x = $5::integer AND y = $6::integer AND ST_Length(geom) > $7::float8 AND $8::float8 < 1000000",
+			args:     []interface{}{-1.0057889928476563e+07, 1.0057889928476563e+07, 39135.7584765625, -39135.7584765625, 1, 1, 39135.7584765625, 1.397705660071795e+08},
+		},
+		//	request params, using the defaults for the ones the request does not have
+		{
+			layer: Layer{
+				sql:  "SELECT id FROM foo WHERE geom && !BBOX! AND class = !PARAM:class! AND (!PARAM:after! IS NULL OR opened > !PARAM:after!) AND height > !PARAM:height!",
+				srid: tegola.WebMercator,
+				params: map[string]layerParam{
+					"class":  {typ: "string"},
+					"after":  {typ: "string"},
+					"height": {typ: "float", def: 10.0},
+				},
+			},
+			tile: tegola.Tile{
+				Z: 2,
+				X: 1,
+				Y: 1,
+			},
+			params:   mvt.Params{"class": "B", "ignored": "true"},
+			expected: "SELECT id FROM foo WHERE geom && ST_MakeEnvelope($1,$2,$3,$4,3857) AND class = $5::text AND ($6::text IS NULL OR opened > $6::text) AND height > $7::float8",
+			args:     []interface{}{-1.001875417e+07, 1.001875417e+07, 0.0, 0.0, "B", nil, 10.0},
 		},
 	}
 
 	for i, tc := range testcases {
-		sql, err := replaceTokens(&tc.layer, tc.tile)
+		sql, args, err := replaceTokens(&tc.layer, tc.tile, tc.params)
 		if err != nil {
 			t.Errorf("Failed test %v. err: %v", i, err)
 			return
@@ -49,5 +102,293 @@ func TestReplaceTokens(t *testing.T) {
 			t.Errorf("Failed test %v. Expected (%v), got (%v)", i, tc.expected, sql)
 			return
 		}
+
+		if !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("Failed test %v. Expected args (%v), got (%v)", i, tc.args, args)
+			return
+		}
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	testcases := []struct {
+		sql       string
+		params    map[string]layerParam
+		expectErr bool
+	}{
+		{
+			sql: "SELECT * FROM foo WHERE geom && !BBOX! AND scalerank = !ZOOM!",
+		},
+		{
+			sql: "SELECT * FROM foo WHERE geom && !BBOX_BUFFERED! AND !SCALE_DENOMINATOR! < 50000 AND x != !X!",
+		},
+		//	missing bbox token
+		{
+			sql:       "SELECT * FROM foo WHERE scalerank = !ZOOM!",
+			expectErr: true,
+		},
+		//	unsupported token
+		{
+			sql:       "SELECT * FROM foo WHERE geom && !BBOX! AND !TILE_SIZE! > 0",
+			expectErr: true,
+		},
+		{
+			sql:    "SELECT * FROM foo WHERE geom && !BBOX! AND class = !PARAM:class!",
+			params: map[string]layerParam{"class": {typ: "string"}},
+		},
+		//	param is not declared
+		{
+			sql:       "SELECT * FROM foo WHERE geom && !BBOX! AND class = !PARAM:klass!",
+			params:    map[string]layerParam{"class": {typ: "string"}},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		err := validateTokens(tc.sql, tc.params)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("Failed test %v. Expected error (%v), got (%v)", i, tc.expectErr, err)
+		}
+	}
+}
+
+func TestReplaceTokensInvalidParam(t *testing.T) {
+	layer := Layer{
+		sql:    "SELECT id FROM foo WHERE geom && !BBOX! AND lanes = !PARAM:lanes!",
+		srid:   tegola.WebMercator,
+		params: map[string]layerParam{"lanes": {typ: "int"}},
+	}
+
+	if _, _, err := replaceTokens(&layer, tegola.Tile{Z: 2, X: 1, Y: 1}, mvt.Params{"lanes": "two"}); err == nil {
+		t.Errorf("Expected an error for an invalid int param, got none")
+	}
+}
+
+func TestParseLayerParams(t *testing.T) {
+	testcases := []struct {
+		config    []map[string]interface{}
+		expected  map[string]layerParam
+		expectErr bool
+	}{
+		{
+			config: []map[string]interface{}{
+				{ConfigKeyParamName: "class"},
+				{ConfigKeyParamName: "lanes", ConfigKeyParamType: "int", ConfigKeyParamDefault: int64(2)},
+				{ConfigKeyParamName: "oneway", ConfigKeyParamType: "bool", ConfigKeyParamDefault: "true"},
+			},
+			expected: map[string]layerParam{
+				"class":  {typ: "string"},
+				"lanes":  {typ: "int", def: int64(2)},
+				"oneway": {typ: "bool", def: true},
+			},
+		},
+		//	unsupported type
+		{
+			config:    []map[string]interface{}{{ConfigKeyParamName: "after", ConfigKeyParamType: "date"}},
+			expectErr: true,
+		},
+		//	invalid default
+		{
+			config:    []map[string]interface{}{{ConfigKeyParamName: "lanes", ConfigKeyParamType: "int", ConfigKeyParamDefault: "two"}},
+			expectErr: true,
+		},
+		//	invalid name
+		{
+			config:    []map[string]interface{}{{ConfigKeyParamName: "class!"}},
+			expectErr: true,
+		},
+		//	duplicate name
+		{
+			config:    []map[string]interface{}{{ConfigKeyParamName: "class"}, {ConfigKeyParamName: "class"}},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		params, err := parseLayerParams(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Failed test %v. Expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed test %v. err: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(params, tc.expected) {
+			t.Errorf("Failed test %v. Expected (%+v), got (%+v)", i, tc.expected, params)
+		}
+	}
+}
+
+func TestParseSQLVariants(t *testing.T) {
+	testcases := []struct {
+		config    []map[string]interface{}
+		expected  []sqlVariant
+		expectErr bool
+	}{
+		{
+			config: []map[string]interface{}{
+				{"min_zoom": int64(10), "max_zoom": int64(20), "sql": "detailed"},
+				{"min_zoom": int64(0), "max_zoom": int64(9), "sql": "simplified"},
+			},
+			expected: []sqlVariant{
+				{minZoom: 0, maxZoom: 9, sql: "simplified"},
+				{minZoom: 10, maxZoom: 20, sql: "detailed"},
+			},
+		},
+		{
+			//	gaps between the ranges are allowed
+			config: []map[string]interface{}{
+				{"min_zoom": int64(3), "max_zoom": int64(3), "sql": "three"},
+				{"min_zoom": int64(5), "max_zoom": int64(8), "sql": "five"},
+			},
+			expected: []sqlVariant{
+				{minZoom: 3, maxZoom: 3, sql: "three"},
+				{minZoom: 5, maxZoom: 8, sql: "five"},
+			},
+		},
+		{
+			//	overlapping ranges
+			config: []map[string]interface{}{
+				{"min_zoom": int64(0), "max_zoom": int64(10), "sql": "simplified"},
+				{"min_zoom": int64(10), "max_zoom": int64(20), "sql": "detailed"},
+			},
+			expectErr: true,
+		},
+		{
+			//	max_zoom before min_zoom
+			config: []map[string]interface{}{
+				{"min_zoom": int64(10), "max_zoom": int64(5), "sql": "detailed"},
+			},
+			expectErr: true,
+		},
+		{
+			//	missing max_zoom
+			config: []map[string]interface{}{
+				{"min_zoom": int64(0), "sql": "simplified"},
+			},
+			expectErr: true,
+		},
+		{
+			//	missing sql
+			config: []map[string]interface{}{
+				{"min_zoom": int64(0), "max_zoom": int64(10)},
+			},
+			expectErr: true,
+		},
+		{
+			config:    []map[string]interface{}{},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := parseSQLVariants(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("[%v] expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(output, tc.expected) {
+			t.Errorf("[%v] expected %+v got %+v", i, tc.expected, output)
+		}
+	}
+}
+
+func TestLayerForZoom(t *testing.T) {
+	variants := Layer{
+		name: "roads",
+		variants: []sqlVariant{
+			{minZoom: 0, maxZoom: 9, sql: "simplified"},
+			{minZoom: 12, maxZoom: 20, sql: "detailed"},
+		},
+	}
+
+	testcases := []struct {
+		layer     Layer
+		z         int
+		ok        bool
+		sql       string
+		statement string
+	}{
+		{
+			layer:     variants,
+			z:         0,
+			ok:        true,
+			sql:       "simplified",
+			statement: "tegola_layer_roads_0_9",
+		},
+		{
+			layer:     variants,
+			z:         12,
+			ok:        true,
+			sql:       "detailed",
+			statement: "tegola_layer_roads_12_20",
+		},
+		{
+			//	between the variants
+			layer: variants,
+			z:     10,
+			ok:    false,
+		},
+		{
+			layer:     Layer{name: "roads", sql: "all"},
+			z:         10,
+			ok:        true,
+			sql:       "all",
+			statement: "tegola_layer_roads",
+		},
+	}
+
+	for i, tc := range testcases {
+		l, ok := tc.layer.forZoom(tc.z)
+		if ok != tc.ok {
+			t.Errorf("[%v] expected ok %v got %v", i, tc.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if l.sql != tc.sql {
+			t.Errorf("[%v] expected sql %v got %v", i, tc.sql, l.sql)
+		}
+		if l.statementName() != tc.statement {
+			t.Errorf("[%v] expected statement name %v got %v", i, tc.statement, l.statementName())
+		}
+	}
+}
+
+func TestAsMVTSQL(t *testing.T) {
+	testcases := []struct {
+		layer    Layer
+		sql      string
+		expected string
+	}{
+		{
+			layer:    Layer{name: "buildings", geomField: "geom"},
+			sql:      "SELECT gid, ST_AsMVTGeom(geom, ST_MakeEnvelope($1,$2,$3,$4,3857), 4096, 16, true) AS geom FROM buildings",
+			expected: "SELECT ST_AsMVT(q, 'buildings', 4096, 'geom') FROM (SELECT gid, ST_AsMVTGeom(geom, ST_MakeEnvelope($1,$2,$3,$4,3857), 4096, 16, true) AS geom FROM buildings) AS q",
+		},
+		{
+			layer:    Layer{name: "o'neil", geomField: "the_geom"},
+			sql:      "SELECT 1",
+			expected: "SELECT ST_AsMVT(q, 'o''neil', 4096, 'the_geom') FROM (SELECT 1) AS q",
+		},
+	}
+
+	for i, tc := range testcases {
+		if sql := asMVTSQL(&tc.layer, tc.sql); sql != tc.expected {
+			t.Errorf("[%v] expected %v got %v", i, tc.expected, sql)
+		}
 	}
 }
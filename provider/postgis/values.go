@@ -0,0 +1,353 @@
+package postgis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+
+	"github.com/airmap/tegola/util/dict"
+)
+
+const (
+	//	timestamps and dates are encoded as ISO 8601 strings (i.e. 2017-12-01T10:30:00Z)
+	TimestampFormatISO = "iso"
+	//	timestamps and dates are encoded as the number of seconds since the unix epoch
+	TimestampFormatEpoch = "epoch"
+	//	arrays are encoded as a JSON string (i.e. ["a","b"])
+	ArrayFormatJSON = "json"
+	//	arrays are encoded as a tag per element keyed by the field name and the element's index (i.e. name.0)
+	ArrayFormatIndexed = "indexed"
+	//	JSON and JSONB values are encoded as a JSON string
+	JSONFormatString = "string"
+	//	JSON and JSONB objects are encoded as a tag per value keyed by the field name and the value's path (i.e. name.key)
+	JSONFormatFlatten = "flatten"
+)
+
+//	the separator between the field name and the keys or indexes of flattened values
+const flattenSeparator = "."
+
+//	valueFormats sets how values of types without a tag equivalent are encoded into tags
+type valueFormats struct {
+	timestamp string
+	array     string
+	json      string
+}
+
+//	parseValueFormats reads the timestamp_format, array_format and json_format config keys,
+//	falling back to the provided formats for the keys that are not set
+func parseValueFormats(c dict.M, def valueFormats) (f valueFormats, err error) {
+	if f.timestamp, err = c.String(ConfigKeyTimestampFormat, &def.timestamp); err != nil {
+		return f, err
+	}
+	switch f.timestamp {
+	case TimestampFormatISO, TimestampFormatEpoch:
+	default:
+		return f, fmt.Errorf("%v (%v) is invalid. expected %v or %v", ConfigKeyTimestampFormat, f.timestamp, TimestampFormatISO, TimestampFormatEpoch)
+	}
+
+	if f.array, err = c.String(ConfigKeyArrayFormat, &def.array); err != nil {
+		return f, err
+	}
+	switch f.array {
+	case ArrayFormatJSON, ArrayFormatIndexed:
+	default:
+		return f, fmt.Errorf("%v (%v) is invalid. expected %v or %v", ConfigKeyArrayFormat, f.array, ArrayFormatJSON, ArrayFormatIndexed)
+	}
+
+	if f.json, err = c.String(ConfigKeyJSONFormat, &def.json); err != nil {
+		return f, err
+	}
+	switch f.json {
+	case JSONFormatString, JSONFormatFlatten:
+	default:
+		return f, fmt.Errorf("%v (%v) is invalid. expected %v or %v", ConfigKeyJSONFormat, f.json, JSONFormatString, JSONFormatFlatten)
+	}
+
+	return f, nil
+}
+
+//	isArray reports if the field is an array. PostgreSQL names array types after their element type prefixed with _
+func isArray(desc pgx.FieldDescription) bool {
+	return strings.HasPrefix(desc.DataTypeName, "_")
+}
+
+//	dynamicKeys reports if the field is encoded as tags whose keys depend on the value
+func (f valueFormats) dynamicKeys(desc pgx.FieldDescription) bool {
+	switch {
+	case desc.DataTypeName == "hstore":
+		return true
+	case desc.DataType == pgx.JsonOid, desc.DataType == pgx.JsonbOid:
+		return f.json == JSONFormatFlatten
+	case isArray(desc):
+		return f.array == ArrayFormatIndexed
+	}
+
+	return false
+}
+
+//	encodeTime encodes a timestamp, or a date, in the configured format
+func (f valueFormats) encodeTime(t time.Time, date bool) interface{} {
+	switch {
+	case f.timestamp == TimestampFormatEpoch:
+		return t.Unix()
+	case date:
+		return t.Format("2006-01-02")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
+
+//	setTags adds the value of a field to the tags. Most fields are a single tag named after the field
+//	but, depending on the formats, arrays and JSON values can be spread over several tags.
+func (f valueFormats) setTags(tags map[string]interface{}, desc pgx.FieldDescription, val interface{}) error {
+	switch {
+	case desc.DataType == pgx.JsonOid, desc.DataType == pgx.JsonbOid:
+		raw, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("expected the JSON value to be a string, got %T", val)
+		}
+		if f.json == JSONFormatString {
+			tags[desc.Name] = raw
+			return nil
+		}
+
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return fmt.Errorf("unable to parse JSON: %v", err)
+		}
+		return f.flatten(tags, desc.Name, v)
+
+	case isArray(desc):
+		elems, err := f.arrayElems(desc, val)
+		if err != nil {
+			return err
+		}
+		return f.setArray(tags, desc.Name, elems)
+
+	default:
+		value, err := f.transformVal(desc.DataType, val)
+		if err != nil {
+			return err
+		}
+		tags[desc.Name] = value
+		return nil
+	}
+}
+
+//	flatten adds a decoded JSON value to the tags. Objects are spread over a tag per value
+//	keyed by their path from the field and arrays are encoded in the array format.
+func (f valueFormats) flatten(tags map[string]interface{}, key string, v interface{}) error {
+	switch vt := v.(type) {
+	case nil:
+		//	null values are skipped like null fields
+	case map[string]interface{}:
+		for k := range vt {
+			if err := f.flatten(tags, key+flattenSeparator+k, vt[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		return f.setArray(tags, key, vt)
+	default:
+		//	string, float64 or bool
+		tags[key] = vt
+	}
+
+	return nil
+}
+
+//	setArray adds the elements of an array to the tags in the array format
+func (f valueFormats) setArray(tags map[string]interface{}, key string, elems []interface{}) error {
+	if f.array == ArrayFormatJSON {
+		b, err := json.Marshal(elems)
+		if err != nil {
+			return fmt.Errorf("unable to encode array as JSON: %v", err)
+		}
+		tags[key] = string(b)
+		return nil
+	}
+
+	for i := range elems {
+		if err := f.flatten(tags, key+flattenSeparator+strconv.Itoa(i), elems[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//	arrayElems returns the elements of an array field converted into tag values
+func (f valueFormats) arrayElems(desc pgx.FieldDescription, val interface{}) ([]interface{}, error) {
+	var elems []interface{}
+
+	switch vt := val.(type) {
+	case []bool:
+		for i := range vt {
+			elems = append(elems, vt[i])
+		}
+	case []int16:
+		for i := range vt {
+			elems = append(elems, int64(vt[i]))
+		}
+	case []int32:
+		for i := range vt {
+			elems = append(elems, int64(vt[i]))
+		}
+	case []int64:
+		for i := range vt {
+			elems = append(elems, vt[i])
+		}
+	case []float32:
+		for i := range vt {
+			elems = append(elems, float64(vt[i]))
+		}
+	case []float64:
+		for i := range vt {
+			elems = append(elems, vt[i])
+		}
+	case []string:
+		for i := range vt {
+			elems = append(elems, vt[i])
+		}
+	case []time.Time:
+		for i := range vt {
+			elems = append(elems, f.encodeTime(vt[i], false))
+		}
+	case string:
+		//	arrays of the types pgx does not decode are in the text format (i.e. {a,"b c",NULL})
+		parsed, err := parseArray(vt)
+		if err != nil {
+			return nil, err
+		}
+		return f.textArrayElems(strings.TrimPrefix(desc.DataTypeName, "_"), parsed)
+	default:
+		return nil, fmt.Errorf("%v array is not supported", desc.DataTypeName)
+	}
+
+	return elems, nil
+}
+
+//	textArrayElems converts the elements of an array in the text format from their text representation
+func (f valueFormats) textArrayElems(elemType string, elems []interface{}) ([]interface{}, error) {
+	for i := range elems {
+		switch et := elems[i].(type) {
+		case []interface{}:
+			//	multidimensional array
+			sub, err := f.textArrayElems(elemType, et)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = sub
+		case string:
+			switch elemType {
+			case "numeric":
+				num, err := strconv.ParseFloat(et, 64)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse numeric (%v) to float64 err: %v", et, err)
+				}
+				elems[i] = num
+			case "date":
+				t, err := time.Parse("2006-01-02", et)
+				if err != nil {
+					//	i.e. infinity
+					continue
+				}
+				elems[i] = f.encodeTime(t, true)
+			}
+		}
+	}
+
+	return elems, nil
+}
+
+//	parseArray parses an array in the PostgreSQL text format. Elements are returned as strings,
+//	nil for NULL, or as []interface{} for the sub arrays of a multidimensional array.
+//	See https://www.postgresql.org/docs/current/static/arrays.html#ARRAYS-IO
+func parseArray(s string) ([]interface{}, error) {
+	//	arrays with non default bounds are prefixed with their dimensions (i.e. [0:1]={a,b})
+	if strings.HasPrefix(s, "[") {
+		i := strings.Index(s, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("invalid array (%v)", s)
+		}
+		s = s[i+1:]
+	}
+
+	elems, rest, err := parseArrayElems(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid array (%v): %v", s, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid array (%v): unexpected (%v) after the array", s, rest)
+	}
+
+	return elems, nil
+}
+
+//	parseArrayElems parses the array at the start of s, returning the rest of s
+func parseArrayElems(s string) (elems []interface{}, rest string, err error) {
+	if !strings.HasPrefix(s, "{") {
+		return nil, "", fmt.Errorf("expected {")
+	}
+	s = s[1:]
+
+	elems = []interface{}{}
+	if strings.HasPrefix(s, "}") {
+		return elems, s[1:], nil
+	}
+
+	for {
+		switch {
+		case strings.HasPrefix(s, "{"):
+			var sub []interface{}
+			if sub, s, err = parseArrayElems(s); err != nil {
+				return nil, "", err
+			}
+			elems = append(elems, sub)
+
+		case strings.HasPrefix(s, `"`):
+			var b bytes.Buffer
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+					if i == len(s) {
+						break
+					}
+				}
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, "", fmt.Errorf("unterminated quoted element")
+			}
+			elems = append(elems, b.String())
+			s = s[i+1:]
+
+		default:
+			i := strings.IndexAny(s, ",}")
+			if i == -1 {
+				return nil, "", fmt.Errorf("expected }")
+			}
+			if v := strings.TrimSpace(s[:i]); v == "NULL" {
+				elems = append(elems, nil)
+			} else {
+				elems = append(elems, v)
+			}
+			s = s[i:]
+		}
+
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, "}"):
+			return elems, s[1:], nil
+		default:
+			return nil, "", fmt.Errorf("expected , or }")
+		}
+	}
+}
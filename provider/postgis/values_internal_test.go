@@ -0,0 +1,220 @@
+package postgis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+func TestParseArray(t *testing.T) {
+	testcases := []struct {
+		array     string
+		expected  []interface{}
+		expectErr bool
+	}{
+		{
+			array:    "{}",
+			expected: []interface{}{},
+		},
+		{
+			array:    `{a,"b c",NULL,"NULL","with \"quotes\" and \\ backslash"}`,
+			expected: []interface{}{"a", "b c", nil, "NULL", `with "quotes" and \ backslash`},
+		},
+		{
+			array:    "{{1,2},{3,4}}",
+			expected: []interface{}{[]interface{}{"1", "2"}, []interface{}{"3", "4"}},
+		},
+		{
+			array:    "[0:1]={a,b}",
+			expected: []interface{}{"a", "b"},
+		},
+		{
+			array:     "{a,b",
+			expectErr: true,
+		},
+		{
+			array:     `{"a}`,
+			expectErr: true,
+		},
+		{
+			array:     "{a}b",
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := parseArray(tc.array)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(output, tc.expected) {
+			t.Errorf("testcase (%v) failed. expected (%#v) got (%#v)", i, tc.expected, output)
+		}
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	defaults := valueFormats{
+		timestamp: TimestampFormatISO,
+		array:     ArrayFormatJSON,
+		json:      JSONFormatString,
+	}
+	ts := time.Date(2017, 12, 1, 10, 30, 0, 0, time.UTC)
+
+	testcases := []struct {
+		formats  valueFormats
+		desc     pgx.FieldDescription
+		val      interface{}
+		expected map[string]interface{}
+	}{
+		{
+			formats:  defaults,
+			desc:     pgx.FieldDescription{Name: "opened", DataType: pgx.TimestampTzOid, DataTypeName: "timestamptz"},
+			val:      ts,
+			expected: map[string]interface{}{"opened": "2017-12-01T10:30:00Z"},
+		},
+		{
+			formats:  defaults,
+			desc:     pgx.FieldDescription{Name: "opened", DataType: pgx.DateOid, DataTypeName: "date"},
+			val:      ts,
+			expected: map[string]interface{}{"opened": "2017-12-01"},
+		},
+		{
+			formats:  valueFormats{timestamp: TimestampFormatEpoch, array: ArrayFormatJSON, json: JSONFormatString},
+			desc:     pgx.FieldDescription{Name: "opened", DataType: pgx.TimestampOid, DataTypeName: "timestamp"},
+			val:      ts,
+			expected: map[string]interface{}{"opened": int64(1512124200)},
+		},
+		{
+			formats:  defaults,
+			desc:     pgx.FieldDescription{Name: "id", DataType: pgx.UuidOid, DataTypeName: "uuid"},
+			val:      "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11",
+			expected: map[string]interface{}{"id": "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		},
+		{
+			formats:  defaults,
+			desc:     pgx.FieldDescription{Name: "lanes", DataType: pgx.Int4ArrayOid, DataTypeName: "_int4"},
+			val:      []int32{1, 2},
+			expected: map[string]interface{}{"lanes": "[1,2]"},
+		},
+		{
+			formats: valueFormats{timestamp: TimestampFormatISO, array: ArrayFormatIndexed, json: JSONFormatString},
+			desc:    pgx.FieldDescription{Name: "names", DataType: pgx.TextArrayOid, DataTypeName: "_text"},
+			val:     []string{"main", "high"},
+			expected: map[string]interface{}{
+				"names.0": "main",
+				"names.1": "high",
+			},
+		},
+		//	arrays pgx does not decode are in the text format
+		{
+			formats:  defaults,
+			desc:     pgx.FieldDescription{Name: "heights", DataType: 1231, DataTypeName: "_numeric"},
+			val:      "{1.5,NULL,3}",
+			expected: map[string]interface{}{"heights": "[1.5,null,3]"},
+		},
+		{
+			formats:  defaults,
+			desc:     pgx.FieldDescription{Name: "props", DataType: pgx.JsonbOid, DataTypeName: "jsonb"},
+			val:      `{"a": 1, "b": {"c": "d"}}`,
+			expected: map[string]interface{}{"props": `{"a": 1, "b": {"c": "d"}}`},
+		},
+		{
+			formats: valueFormats{timestamp: TimestampFormatISO, array: ArrayFormatJSON, json: JSONFormatFlatten},
+			desc:    pgx.FieldDescription{Name: "props", DataType: pgx.JsonOid, DataTypeName: "json"},
+			val:     `{"a": 1, "b": {"c": "d", "e": null}, "f": [true, false]}`,
+			expected: map[string]interface{}{
+				"props.a":   float64(1),
+				"props.b.c": "d",
+				"props.f":   "[true,false]",
+			},
+		},
+		{
+			formats: valueFormats{timestamp: TimestampFormatISO, array: ArrayFormatIndexed, json: JSONFormatFlatten},
+			desc:    pgx.FieldDescription{Name: "props", DataType: pgx.JsonbOid, DataTypeName: "jsonb"},
+			val:     `{"f": [true, {"g": "h"}]}`,
+			expected: map[string]interface{}{
+				"props.f.0":   true,
+				"props.f.1.g": "h",
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		tags := map[string]interface{}{}
+		if err := tc.formats.setTags(tags, tc.desc, tc.val); err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(tags, tc.expected) {
+			t.Errorf("testcase (%v) failed. expected (%v) got (%v)", i, tc.expected, tags)
+		}
+	}
+}
+
+func TestParseValueFormats(t *testing.T) {
+	def := valueFormats{
+		timestamp: TimestampFormatISO,
+		array:     ArrayFormatJSON,
+		json:      JSONFormatString,
+	}
+
+	testcases := []struct {
+		config    map[string]interface{}
+		expected  valueFormats
+		expectErr bool
+	}{
+		{
+			config:   map[string]interface{}{},
+			expected: def,
+		},
+		{
+			config: map[string]interface{}{
+				ConfigKeyTimestampFormat: TimestampFormatEpoch,
+				ConfigKeyJSONFormat:      JSONFormatFlatten,
+			},
+			expected: valueFormats{timestamp: TimestampFormatEpoch, array: ArrayFormatJSON, json: JSONFormatFlatten},
+		},
+		{
+			config:    map[string]interface{}{ConfigKeyTimestampFormat: "rfc822"},
+			expectErr: true,
+		},
+		{
+			config:    map[string]interface{}{ConfigKeyArrayFormat: "csv"},
+			expectErr: true,
+		},
+		{
+			config:    map[string]interface{}{ConfigKeyJSONFormat: "hstore"},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		output, err := parseValueFormats(tc.config, def)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if output != tc.expected {
+			t.Errorf("testcase (%v) failed. expected (%+v) got (%+v)", i, tc.expected, output)
+		}
+	}
+}
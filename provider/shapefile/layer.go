@@ -0,0 +1,47 @@
+package shapefile
+
+import (
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/container/rtree"
+)
+
+//	feature is a shapefile record which has been transformed into webmercator
+type feature struct {
+	id       uint64
+	geometry tegola.Geometry
+	tags     map[string]interface{}
+}
+
+// Layer holds the records of a shapefile along with their spatial index.
+type Layer struct {
+	// The Name of the layer
+	name string
+	// The path to the .shp file
+	filepath string
+	// The ID field name. When empty the record number is used.
+	idField string
+	// GeomType is the the type of geometry in the shapefile
+	geomType tegola.Geometry
+	// The SRID that the data in the shapefile is stored in.
+	srid int
+	// The features of the shapefile in webmercator
+	features []feature
+	// The spatial index of the features. The items are indexes into the features slice.
+	index *rtree.Tree
+}
+
+func (l Layer) Name() string {
+	return l.name
+}
+
+func (l Layer) GeomType() tegola.Geometry {
+	return l.geomType
+}
+
+func (l Layer) SRID() int {
+	return l.srid
+}
+
+func (l Layer) IDFieldName() string {
+	return l.idField
+}
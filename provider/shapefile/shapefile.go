@@ -0,0 +1,319 @@
+//	Package shapefile provides a data provider which loads ESRI Shapefiles
+//	(.shp, .dbf) from disk into memory. The features of each layer are
+//	indexed with an R-tree for tile queries.
+package shapefile
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jonas-p/go-shp"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/container/rtree"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
+)
+
+const Name = "shapefile"
+
+// Provider provides the ESRI Shapefile data provider.
+type Provider struct {
+	// map of layer name and corrosponding features
+	layers     map[string]Layer
+	firstlayer string
+}
+
+const (
+	DefaultSRID = tegola.WGS84
+)
+
+const (
+	ConfigKeyLayers      = "layers"
+	ConfigKeyLayerName   = "name"
+	ConfigKeyFilePath    = "filepath"
+	ConfigKeyFields      = "fields"
+	ConfigKeyGeomIDField = "id_fieldname"
+	ConfigKeySRID        = "srid"
+)
+
+func init() {
+	provider.Register(Name, NewProvider)
+}
+
+//	NewProvider Setups and returns a new Shapefile provider or an error; if something
+//	is wrong. The function will validate the config and load the records of every
+//	layer into memory. This means that the Provider expects the following fields to
+//	exists in the provided map[string]interface{} map:
+//
+//		layers (map[string]struct{})  — This is map of layers keyed by the layer name.
+//     		filepath (string) — the path to the .shp file. The .dbf file is expected next to it with the same name.
+//     		fields ([]string) — This is a list of DBF fields to encode as tags, if this is nil or empty we will use all fields.
+//     		id_fieldname (string) — The numeric DBF field to use as the feature id. Defaults to the record number.
+//     		srid (int) — The srid of the shapefile geometries. Defaults to 4326. Only 4326 and 3857 are supported.
+//
+func NewProvider(config map[string]interface{}) (mvt.Provider, error) {
+	// Validate the config to make sure it has the values I care about and the types for those values.
+	c := dict.M(config)
+
+	layers, ok := c[ConfigKeyLayers].([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected %v to be a []map[string]interface{}", ConfigKeyLayers)
+	}
+
+	p := Provider{
+		layers: make(map[string]Layer),
+	}
+	lyrsSeen := make(map[string]int)
+
+	for i, v := range layers {
+		vc := dict.M(v)
+
+		lname, err := vc.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if j, ok := lyrsSeen[lname]; ok {
+			return nil, fmt.Errorf("%v layer name is duplicated in both layer %v and layer %v", lname, i, j)
+		}
+		lyrsSeen[lname] = i
+		if i == 0 {
+			p.firstlayer = lname
+		}
+
+		fpath, err := vc.String(ConfigKeyFilePath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+		if strings.ToLower(filepath.Ext(fpath)) != ".shp" {
+			return nil, fmt.Errorf("For layer (%v) %v : %v (%v) must be a .shp file.", i, lname, ConfigKeyFilePath, fpath)
+		}
+
+		fields, err := vc.StringSlice(ConfigKeyFields)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v %v field had the following error: %v", i, lname, ConfigKeyFields, err)
+		}
+
+		var idfld string
+		idfld, err = vc.String(ConfigKeyGeomIDField, &idfld)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		srid := int64(DefaultSRID)
+		if srid, err = vc.Int64(ConfigKeySRID, &srid); err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+		if srid != tegola.WGS84 && srid != tegola.WebMercator {
+			return nil, fmt.Errorf("For layer (%v) %v : unsupported %v (%v). Only %v and %v are supported.", i, lname, ConfigKeySRID, srid, tegola.WGS84, tegola.WebMercator)
+		}
+
+		l := Layer{
+			name:     lname,
+			filepath: fpath,
+			idField:  idfld,
+			srid:     int(srid),
+		}
+
+		if err = l.load(fields); err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		p.layers[lname] = l
+	}
+
+	return &p, nil
+}
+
+//	load reads the layer's shapefile, transforms the records into webmercator and builds
+//	the spatial index. If fields is not empty only those DBF fields are kept as tags.
+func (l *Layer) load(fields []string) error {
+	//	the shapefile reader silently ignores a missing .dbf file
+	dbfPath := l.filepath[:len(l.filepath)-3] + "dbf"
+	if _, err := os.Stat(dbfPath); err != nil {
+		return fmt.Errorf("unable to open shapefile attributes (%v): %v", dbfPath, err)
+	}
+
+	r, err := shp.Open(l.filepath)
+	if err != nil {
+		return fmt.Errorf("unable to open shapefile (%v): %v", l.filepath, err)
+	}
+	defer r.Close()
+
+	if l.geomType, err = geomTypeFromShapeType(r.GeometryType); err != nil {
+		return fmt.Errorf("shapefile (%v): %v", l.filepath, err)
+	}
+
+	//	the index of the DBF fields to encode as tags
+	keep := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		keep[f] = true
+	}
+
+	idIdx := -1
+	var tagIdxs []int
+	dbfFields := r.Fields()
+	for i, f := range dbfFields {
+		name := f.String()
+		if name == l.idField {
+			idIdx = i
+			continue
+		}
+		if len(keep) > 0 && !keep[name] {
+			continue
+		}
+		if !supportedFieldTypes[f.Fieldtype] {
+			log.Printf("shapefile (%v) field (%v) has an unsupported type (%c). skipping.", l.filepath, name, f.Fieldtype)
+			continue
+		}
+		tagIdxs = append(tagIdxs, i)
+	}
+	if l.idField != "" && idIdx == -1 {
+		return fmt.Errorf("shapefile (%v) is missing the %v (%v)", l.filepath, ConfigKeyGeomIDField, l.idField)
+	}
+
+	numRecords := r.AttributeCount()
+
+	var bboxes []rtree.BBox
+	for r.Next() {
+		n, shape := r.Shape()
+
+		geom, err := convertShape(shape)
+		if err != nil {
+			return fmt.Errorf("shapefile (%v) record (%v): %v", l.filepath, n, err)
+		}
+		//	null shapes have nothing to render
+		if geom == nil {
+			continue
+		}
+
+		f := feature{
+			id:   uint64(n + 1),
+			tags: map[string]interface{}{},
+		}
+
+		if n < numRecords {
+			if idIdx != -1 {
+				v, err := transformVal(dbfFields[idIdx], r.ReadAttribute(n, idIdx))
+				if err != nil {
+					return fmt.Errorf("shapefile (%v) record (%v) unable to convert id field (%v): %v", l.filepath, n, l.idField, err)
+				}
+				if f.id, err = gId(v); err != nil {
+					return fmt.Errorf("shapefile (%v) record (%v) unable to convert id field (%v): %v", l.filepath, n, l.idField, err)
+				}
+			}
+
+			for _, i := range tagIdxs {
+				v, err := transformVal(dbfFields[i], r.ReadAttribute(n, i))
+				if err != nil {
+					return fmt.Errorf("shapefile (%v) record (%v) unable to convert field (%v): %v", l.filepath, n, dbfFields[i], err)
+				}
+				if v == nil {
+					continue
+				}
+				f.tags[dbfFields[i].String()] = v
+			}
+		}
+
+		g, err := basic.ToWebMercator(l.srid, geom)
+		if err != nil {
+			return fmt.Errorf("shapefile (%v) record (%v) unable to transform geometry to webmercator from SRID (%v): %v", l.filepath, n, l.srid, err)
+		}
+		f.geometry = g.Geometry
+
+		//	webmercator is monotonic along each axis so transforming the corners of the
+		//	shape's bounding box gives us the bounding box in webmercator
+		box := shape.BBox()
+		min, err := basic.ToWebMercator(l.srid, basic.Point{box.MinX, box.MinY})
+		if err != nil {
+			return fmt.Errorf("shapefile (%v) record (%v) unable to transform bounding box: %v", l.filepath, n, err)
+		}
+		max, err := basic.ToWebMercator(l.srid, basic.Point{box.MaxX, box.MaxY})
+		if err != nil {
+			return fmt.Errorf("shapefile (%v) record (%v) unable to transform bounding box: %v", l.filepath, n, err)
+		}
+		minPt, maxPt := min.AsPoint(), max.AsPoint()
+
+		l.features = append(l.features, f)
+		bboxes = append(bboxes, rtree.BBox{minPt.X(), minPt.Y(), maxPt.X(), maxPt.Y()})
+	}
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("error reading shapefile (%v): %v", l.filepath, err)
+	}
+
+	l.index = rtree.New(bboxes)
+
+	return nil
+}
+
+func (p *Provider) Layer(name string) (Layer, bool) {
+	if name == "" {
+		return p.layers[p.firstlayer], true
+	}
+	plyr, ok := p.layers[name]
+	return plyr, ok
+}
+
+func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
+	var ls []mvt.LayerInfo
+
+	for i := range p.layers {
+		ls = append(ls, p.layers[i])
+	}
+
+	return ls, nil
+}
+
+func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (layer *mvt.Layer, err error) {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return nil, fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	layer = &mvt.Layer{
+		Name: layerName,
+	}
+
+	//	the tile's y axis is flipped so the min and max values are swapped
+	textent := tile.BoundingBox()
+	bbox := rtree.BBox{textent.Minx, textent.Maxy, textent.Maxx, textent.Miny}
+
+	plyr.index.Search(bbox, func(item int) bool {
+		// do a quick context check:
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+
+		f := plyr.features[item]
+
+		//	copy our default tags to a tags map
+		tags := map[string]interface{}{}
+		for k, v := range dtags {
+			tags[k] = v
+		}
+
+		//	add feature tags to our map
+		for k := range f.tags {
+			tags[k] = f.tags[k]
+		}
+
+		gid := f.id
+
+		// Add features to Layer
+		layer.AddFeatures(mvt.Feature{
+			ID:       &gid,
+			Tags:     tags,
+			Geometry: f.geometry,
+		})
+
+		return true
+	})
+
+	return layer, err
+}
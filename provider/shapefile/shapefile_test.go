@@ -0,0 +1,197 @@
+package shapefile_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/provider/shapefile"
+)
+
+//	testShapefile is a roads shapefile (with .shx and .dbf) of two roads in Athens and one in La Serena, Chile.
+//	The dbf has number, string, float, logical and date fields.
+const testShapefile = "testfiles/roads.shp"
+
+func TestNewProvider(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		expectErr bool
+		layers    map[string]tegola.Geometry
+	}{
+		{
+			config: map[string]interface{}{
+				shapefile.ConfigKeyLayers: []map[string]interface{}{
+					{
+						shapefile.ConfigKeyLayerName: "roads",
+						shapefile.ConfigKeyFilePath:  testShapefile,
+					},
+				},
+			},
+			layers: map[string]tegola.Geometry{
+				"roads": basic.MultiLine{},
+			},
+		},
+		//	missing file
+		{
+			config: map[string]interface{}{
+				shapefile.ConfigKeyLayers: []map[string]interface{}{
+					{
+						shapefile.ConfigKeyLayerName: "roads",
+						shapefile.ConfigKeyFilePath:  "testfiles/missing.shp",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	not a .shp file
+		{
+			config: map[string]interface{}{
+				shapefile.ConfigKeyLayers: []map[string]interface{}{
+					{
+						shapefile.ConfigKeyLayerName: "roads",
+						shapefile.ConfigKeyFilePath:  "testfiles/roads.dbf",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	missing id field
+		{
+			config: map[string]interface{}{
+				shapefile.ConfigKeyLayers: []map[string]interface{}{
+					{
+						shapefile.ConfigKeyLayerName:   "roads",
+						shapefile.ConfigKeyFilePath:    testShapefile,
+						shapefile.ConfigKeyGeomIDField: "gid",
+					},
+				},
+			},
+			expectErr: true,
+		},
+		//	unsupported srid
+		{
+			config: map[string]interface{}{
+				shapefile.ConfigKeyLayers: []map[string]interface{}{
+					{
+						shapefile.ConfigKeyLayerName: "roads",
+						shapefile.ConfigKeyFilePath:  testShapefile,
+						shapefile.ConfigKeySRID:      2100,
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		p, err := shapefile.NewProvider(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("testcase (%v) failed. expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		layers, err := p.Layers()
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		if len(layers) != len(tc.layers) {
+			t.Errorf("testcase (%v) failed. expected (%v) layers, got (%v)", i, len(tc.layers), len(layers))
+			continue
+		}
+
+		for _, l := range layers {
+			if reflect.TypeOf(l.GeomType()) != reflect.TypeOf(tc.layers[l.Name()]) {
+				t.Errorf("testcase (%v) failed. layer (%v) expected geom type (%T) got (%T)", i, l.Name(), tc.layers[l.Name()], l.GeomType())
+			}
+			if l.SRID() != tegola.WGS84 {
+				t.Errorf("testcase (%v) failed. layer (%v) expected srid (%v) got (%v)", i, l.Name(), tegola.WGS84, l.SRID())
+			}
+		}
+	}
+}
+
+func TestMVTLayer(t *testing.T) {
+	p, err := shapefile.NewProvider(map[string]interface{}{
+		shapefile.ConfigKeyLayers: []map[string]interface{}{
+			{
+				shapefile.ConfigKeyLayerName:   "roads",
+				shapefile.ConfigKeyFilePath:    testShapefile,
+				shapefile.ConfigKeyGeomIDField: "osm_id",
+			},
+			{
+				shapefile.ConfigKeyLayerName: "road_names",
+				shapefile.ConfigKeyFilePath:  testShapefile,
+				shapefile.ConfigKeyFields:    []string{"name"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create provider: %v", err)
+	}
+
+	testcases := []struct {
+		layerName    string
+		tile         tegola.Tile
+		expectedTags map[uint64]map[string]interface{}
+	}{
+		{
+			layerName: "roads",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				101: {"name": "Ermou", "lanes": int64(2), "width": 7.5, "oneway": false, "opened": "1835-01-01", "class": "road"},
+				102: {"name": "Athinas", "lanes": int64(4), "width": 12.25, "oneway": true, "class": "road"},
+			},
+		},
+		{
+			layerName: "roads",
+			tile:      tegola.Tile{Z: 10, X: 312, Y: 601},
+			expectedTags: map[uint64]map[string]interface{}{
+				103: {"name": "Avenida Brasil", "lanes": int64(6), "width": 20.0, "opened": "1960-12-31", "class": "road"},
+			},
+		},
+		{
+			layerName:    "roads",
+			tile:         tegola.Tile{Z: 10, X: 0, Y: 0},
+			expectedTags: map[uint64]map[string]interface{}{},
+		},
+		//	record numbers are used as ids when no id field is configured
+		{
+			layerName: "road_names",
+			tile:      tegola.Tile{Z: 10, X: 579, Y: 395},
+			expectedTags: map[uint64]map[string]interface{}{
+				1: {"name": "Ermou", "class": "road"},
+				2: {"name": "Athinas", "class": "road"},
+			},
+		},
+	}
+
+	for i, tc := range testcases {
+		l, err := p.MVTLayer(context.Background(), tc.layerName, tc.tile, map[string]interface{}{"class": "road"})
+		if err != nil {
+			t.Errorf("testcase (%v) failed. err: %v", i, err)
+			continue
+		}
+
+		features := l.Features()
+		if len(features) != len(tc.expectedTags) {
+			t.Errorf("testcase (%v) failed. expected (%v) features, got (%v)", i, len(tc.expectedTags), len(features))
+			continue
+		}
+
+		for _, f := range features {
+			if !reflect.DeepEqual(tc.expectedTags[*f.ID], f.Tags) {
+				t.Errorf("testcase (%v) failed. feature (%v) expected tags (%v) got (%v)", i, *f.ID, tc.expectedTags[*f.ID], f.Tags)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package shapefile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonas-p/go-shp"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+)
+
+//	geomTypeFromShapeType maps a shapefile shape type to a tegola geometry type.
+//	Lines and polygons are always decoded as multi geometries as a record can have several parts.
+func geomTypeFromShapeType(st shp.ShapeType) (tegola.Geometry, error) {
+	switch st {
+	case shp.POINT, shp.POINTZ, shp.POINTM:
+		return basic.Point{}, nil
+	case shp.MULTIPOINT, shp.MULTIPOINTZ, shp.MULTIPOINTM:
+		return basic.MultiPoint{}, nil
+	case shp.POLYLINE, shp.POLYLINEZ, shp.POLYLINEM:
+		return basic.MultiLine{}, nil
+	case shp.POLYGON, shp.POLYGONZ, shp.POLYGONM:
+		return basic.MultiPolygon{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported shape type (%v)", st)
+	}
+}
+
+//	convertShape converts a shape into a tegola geometry. nil is returned for null shapes.
+func convertShape(shape shp.Shape) (tegola.Geometry, error) {
+	switch s := shape.(type) {
+	case *shp.Null:
+		return nil, nil
+	case *shp.Point:
+		return basic.Point{s.X, s.Y}, nil
+	case *shp.PointZ:
+		return basic.Point{s.X, s.Y}, nil
+	case *shp.PointM:
+		return basic.Point{s.X, s.Y}, nil
+	case *shp.MultiPoint:
+		return multiPoint(s.Points), nil
+	case *shp.MultiPointZ:
+		return multiPoint(s.Points), nil
+	case *shp.MultiPointM:
+		return multiPoint(s.Points), nil
+	case *shp.PolyLine:
+		return basic.MultiLine(parts(s.Parts, s.Points)), nil
+	case *shp.PolyLineZ:
+		return basic.MultiLine(parts(s.Parts, s.Points)), nil
+	case *shp.PolyLineM:
+		return basic.MultiLine(parts(s.Parts, s.Points)), nil
+	case *shp.Polygon:
+		return multiPolygon(parts(s.Parts, s.Points)), nil
+	case *shp.PolygonZ:
+		return multiPolygon(parts(s.Parts, s.Points)), nil
+	case *shp.PolygonM:
+		return multiPolygon(parts(s.Parts, s.Points)), nil
+	default:
+		return nil, fmt.Errorf("unsupported shape (%T)", shape)
+	}
+}
+
+//	parts splits the points of a shape into lines using the part start indexes
+func parts(starts []int32, pts []shp.Point) (lines []basic.Line) {
+	for i := range starts {
+		end := len(pts)
+		if i+1 < len(starts) {
+			end = int(starts[i+1])
+		}
+		if int(starts[i]) > end || end > len(pts) {
+			break
+		}
+
+		var l basic.Line
+		for _, pt := range pts[starts[i]:end] {
+			l = append(l, basic.Point{pt.X, pt.Y})
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func multiPoint(pts []shp.Point) (mp basic.MultiPoint) {
+	for _, pt := range pts {
+		mp = append(mp, basic.Point{pt.X, pt.Y})
+	}
+	return mp
+}
+
+//	multiPolygon groups the rings of a shapefile polygon into polygons. Outer rings are
+//	clockwise and holes are counter clockwise. A hole is added to the first outer ring
+//	that contains it, or the last outer ring if none do.
+func multiPolygon(rings []basic.Line) (mp basic.MultiPolygon) {
+	var holes []basic.Line
+	for _, r := range rings {
+		if len(r) < 3 {
+			continue
+		}
+		if signedArea(r) <= 0 {
+			mp = append(mp, basic.Polygon{r})
+			continue
+		}
+		holes = append(holes, r)
+	}
+
+	//	a polygon of only counter clockwise rings is not valid but we treat them as outer rings
+	if len(mp) == 0 {
+		for _, h := range holes {
+			mp = append(mp, basic.Polygon{h})
+		}
+		return mp
+	}
+
+	for _, h := range holes {
+		idx := len(mp) - 1
+		for i := range mp {
+			if containsPoint(mp[i][0], h[0]) {
+				idx = i
+				break
+			}
+		}
+		mp[idx] = append(mp[idx], h)
+	}
+
+	return mp
+}
+
+//	signedArea returns twice the signed area of the ring. Clockwise rings are negative.
+func signedArea(ring basic.Line) (area float64) {
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return area
+}
+
+//	containsPoint is a ray casting point in polygon test
+func containsPoint(ring basic.Line, pt basic.Point) (in bool) {
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if (ring[i][1] > pt[1]) != (ring[j][1] > pt[1]) &&
+			pt[0] < (ring[j][0]-ring[i][0])*(pt[1]-ring[i][1])/(ring[j][1]-ring[i][1])+ring[i][0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+//	supportedFieldTypes are the DBF field types that can be converted by transformVal
+var supportedFieldTypes = map[byte]bool{
+	'C': true,
+	'N': true,
+	'F': true,
+	'O': true,
+	'L': true,
+	'D': true,
+}
+
+//	transformVal converts a DBF attribute into a typed value that can be encoded as a tag.
+//	nil is returned for empty values.
+func transformVal(fld shp.Field, val string) (interface{}, error) {
+	val = strings.TrimSpace(strings.TrimRight(val, "\x00"))
+	if val == "" {
+		return nil, nil
+	}
+
+	switch fld.Fieldtype {
+	case 'C':
+		return val, nil
+	case 'N':
+		if fld.Precision == 0 {
+			if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+		return strconv.ParseFloat(val, 64)
+	case 'F', 'O':
+		return strconv.ParseFloat(val, 64)
+	case 'L':
+		switch val {
+		case "T", "t", "Y", "y":
+			return true, nil
+		case "F", "f", "N", "n":
+			return false, nil
+		default:
+			//	'?' is an uninitialized logical
+			return nil, nil
+		}
+	case 'D':
+		d, err := time.Parse("20060102", val)
+		if err != nil {
+			return nil, err
+		}
+		return d.Format("2006-01-02"), nil
+	default:
+		return nil, fmt.Errorf("unsupported DBF field type (%c)", fld.Fieldtype)
+	}
+}
+
+func gId(v interface{}) (gid uint64, err error) {
+	switch aval := v.(type) {
+	case int64:
+		return uint64(aval), nil
+	case float64:
+		return uint64(aval), nil
+	case string:
+		return strconv.ParseUint(aval, 10, 64)
+	default:
+		return gid, fmt.Errorf("Unable to convert field into a uint64.")
+	}
+}
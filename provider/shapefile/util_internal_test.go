@@ -0,0 +1,48 @@
+package shapefile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola/basic"
+)
+
+func TestMultiPolygon(t *testing.T) {
+	//	clockwise outer rings
+	outer1 := basic.Line{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}
+	outer2 := basic.Line{{20, 0}, {20, 10}, {30, 10}, {30, 0}, {20, 0}}
+	//	counter clockwise holes
+	hole1 := basic.Line{{2, 2}, {4, 2}, {4, 4}, {2, 4}, {2, 2}}
+	hole2 := basic.Line{{22, 2}, {24, 2}, {24, 4}, {22, 4}, {22, 2}}
+
+	testcases := []struct {
+		rings    []basic.Line
+		expected basic.MultiPolygon
+	}{
+		{
+			rings:    []basic.Line{outer1},
+			expected: basic.MultiPolygon{{outer1}},
+		},
+		{
+			rings:    []basic.Line{outer1, hole1},
+			expected: basic.MultiPolygon{{outer1, hole1}},
+		},
+		//	holes are assigned to the outer ring that contains them
+		{
+			rings:    []basic.Line{outer1, outer2, hole2, hole1},
+			expected: basic.MultiPolygon{{outer1, hole1}, {outer2, hole2}},
+		},
+		//	only counter clockwise rings
+		{
+			rings:    []basic.Line{hole1},
+			expected: basic.MultiPolygon{{hole1}},
+		},
+	}
+
+	for i, tc := range testcases {
+		output := multiPolygon(tc.rings)
+		if !reflect.DeepEqual(tc.expected, output) {
+			t.Errorf("testcase (%v) failed. expected (%v) got (%v)", i, tc.expected, output)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package upstream
+
+import "github.com/airmap/tegola"
+
+// Layer is a layer of the upstream tiles.
+type Layer struct {
+	// The Name of the layer
+	name string
+	// The name of the layer in the upstream tiles
+	upstreamLayer string
+}
+
+func (l Layer) Name() string {
+	return l.name
+}
+
+// GeomType is nil as the layers of the upstream tiles can contain mixed geometries.
+func (l Layer) GeomType() tegola.Geometry {
+	return nil
+}
+
+// SRID is always webmercator. The geometries are converted from tile coordinates when decoded.
+func (l Layer) SRID() int {
+	return tegola.WebMercator
+}
@@ -0,0 +1,342 @@
+//	Package upstream provides a data provider which proxies the layers of vector tiles fetched
+//	from an upstream tile server (i.e. a third party basemap). The upstream tiles are decoded
+//	so their layers can be merged with the layers of other providers.
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/cache"
+	"github.com/airmap/tegola/cache/filecache"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/mvt/provider"
+	"github.com/airmap/tegola/util/dict"
+)
+
+const Name = "upstream"
+
+//	the tokens of the url template
+const (
+	zToken = "{z}"
+	xToken = "{x}"
+	yToken = "{y}"
+	//	the row in the TMS tiling scheme which has the y axis flipped
+	tmsYToken = "{-y}"
+)
+
+const (
+	//	seconds to wait for the upstream server
+	DefaultTimeout = 10
+	//	the maximum size in bytes of an upstream tile, larger responses are rejected
+	MaxTileSize = 10 << 20
+)
+
+const (
+	ConfigKeyURL           = "url"
+	ConfigKeyHeaders       = "headers"
+	ConfigKeyTimeout       = "timeout"
+	ConfigKeyCacheDir      = "cache_dir"
+	ConfigKeyLayers        = "layers"
+	ConfigKeyLayerName     = "name"
+	ConfigKeyUpstreamLayer = "upstream_layer"
+)
+
+func init() {
+	provider.Register(Name, NewProvider)
+}
+
+// Provider provides the upstream data provider.
+type Provider struct {
+	// the url template of the upstream tiles
+	url     string
+	headers map[string]string
+	client  *http.Client
+	// the raw upstream tiles are stored on disk when set
+	cache cache.Interface
+	// map of layer name and corrosponding layer
+	layers     map[string]Layer
+	firstlayer string
+
+	// the fetches in progress keyed by tile so the layers of a tile share a request
+	fetchesMu sync.Mutex
+	fetches   map[tegola.Tile]*fetch
+}
+
+//	fetch is a request for an upstream tile shared by the callers asking for the tile while it's in progress
+type fetch struct {
+	done chan struct{}
+	tile *mvt.Tile
+	err  error
+}
+
+//	NewProvider Setups and returns a new upstream provider or an error; if something
+//	is wrong. The provider expects the following fields to exists in the provided
+//	map[string]interface{} map:
+//
+//		url (string) — the url template of the upstream tiles. {z}, {x} and {y} are replaced with the
+//			tile's zoom, column and row. {-y} can be used instead of {y} for servers using the TMS scheme.
+//		headers (map[string]string) — Optional. Headers sent with the upstream requests (i.e. Authorization).
+//		timeout (int) — Optional. Seconds to wait for an upstream tile. Defaults to 10.
+//		cache_dir (string) — Optional. A directory the upstream tiles are stored in and read from before
+//			they are requested again.
+//		layers (map[string]struct{})  — The layers of the upstream tiles to serve.
+//     		name (string) — The name of the layer.
+//     		upstream_layer (string) — Optional. The name of the layer in the upstream tiles. Defaults to name.
+//
+func NewProvider(config map[string]interface{}) (mvt.Provider, error) {
+	// Validate the config to make sure it has the values I care about and the types for those values.
+	c := dict.M(config)
+
+	url, err := c.String(ConfigKeyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range []string{zToken, xToken} {
+		if !strings.Contains(url, token) {
+			return nil, fmt.Errorf("%v (%v) is missing the %v token", ConfigKeyURL, url, token)
+		}
+	}
+	if !strings.Contains(url, yToken) && !strings.Contains(url, tmsYToken) {
+		return nil, fmt.Errorf("%v (%v) is missing the %v or %v token", ConfigKeyURL, url, yToken, tmsYToken)
+	}
+
+	timeout := int64(DefaultTimeout)
+	if timeout, err = c.Int64(ConfigKeyTimeout, &timeout); err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("%v (%v) must be greater than 0", ConfigKeyTimeout, timeout)
+	}
+
+	p := Provider{
+		url: url,
+		client: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+		layers:  make(map[string]Layer),
+		fetches: make(map[tegola.Tile]*fetch),
+	}
+
+	if val, ok := c[ConfigKeyHeaders]; ok {
+		headers, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v value needs to be a table. Value is of type %T", ConfigKeyHeaders, val)
+		}
+
+		p.headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			if p.headers[k], ok = v.(string); !ok {
+				return nil, fmt.Errorf("%v (%v) value needs to be of type string. Value is of type %T", ConfigKeyHeaders, k, v)
+			}
+		}
+	}
+
+	var cacheDir string
+	if cacheDir, err = c.String(ConfigKeyCacheDir, &cacheDir); err != nil {
+		return nil, err
+	}
+	if cacheDir != "" {
+		if p.cache, err = filecache.New(map[string]interface{}{filecache.ConfigKeyBasepath: cacheDir}); err != nil {
+			return nil, fmt.Errorf("unable to set up %v (%v): %v", ConfigKeyCacheDir, cacheDir, err)
+		}
+	}
+
+	layers, ok := c[ConfigKeyLayers].([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected %v to be a []map[string]interface{}", ConfigKeyLayers)
+	}
+
+	for i, v := range layers {
+		vc := dict.M(v)
+
+		lname, err := vc.String(ConfigKeyLayerName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("For layer (%v) we got the following error trying to get the layer's name field: %v", i, err)
+		}
+		if _, ok := p.layers[lname]; ok {
+			return nil, fmt.Errorf("%v layer name is duplicated", lname)
+		}
+		if i == 0 {
+			p.firstlayer = lname
+		}
+
+		upstreamLayer := lname
+		if upstreamLayer, err = vc.String(ConfigKeyUpstreamLayer, &upstreamLayer); err != nil {
+			return nil, fmt.Errorf("For layer (%v) %v : %v", i, lname, err)
+		}
+
+		p.layers[lname] = Layer{
+			name:          lname,
+			upstreamLayer: upstreamLayer,
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *Provider) Layer(name string) (Layer, bool) {
+	if name == "" {
+		return p.layers[p.firstlayer], true
+	}
+	plyr, ok := p.layers[name]
+	return plyr, ok
+}
+
+func (p *Provider) Layers() ([]mvt.LayerInfo, error) {
+	var ls []mvt.LayerInfo
+
+	for i := range p.layers {
+		ls = append(ls, p.layers[i])
+	}
+
+	return ls, nil
+}
+
+//	tileURL returns the url of the upstream tile
+func (p *Provider) tileURL(tile tegola.Tile) string {
+	return strings.NewReplacer(
+		zToken, strconv.Itoa(tile.Z),
+		xToken, strconv.Itoa(tile.X),
+		yToken, strconv.Itoa(tile.Y),
+		tmsYToken, strconv.Itoa((1<<uint(tile.Z))-1-tile.Y),
+	).Replace(p.url)
+}
+
+//	tile returns the decoded upstream tile. nil is returned if the upstream server does not have the tile.
+//	Concurrent calls for the same tile (i.e. for the tile's layers) share a single upstream request. The shared
+//	request does not depend on the context of any one caller, it's bounded by the client's timeout. Each caller
+//	stops waiting for it when its own context is done.
+func (p *Provider) tile(ctx context.Context, tile tegola.Tile) (*mvt.Tile, error) {
+	p.fetchesMu.Lock()
+	f, ok := p.fetches[tile]
+	if !ok {
+		f = &fetch{done: make(chan struct{})}
+		p.fetches[tile] = f
+
+		go func() {
+			f.tile, f.err = p.fetchTile(context.Background(), tile)
+
+			p.fetchesMu.Lock()
+			delete(p.fetches, tile)
+			p.fetchesMu.Unlock()
+			close(f.done)
+		}()
+	}
+	p.fetchesMu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.tile, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//	fetchTile reads the tile from the disk cache, or requests it from the upstream server
+func (p *Provider) fetchTile(ctx context.Context, tile tegola.Tile) (*mvt.Tile, error) {
+	key := cache.Key{Z: tile.Z, X: tile.X, Y: tile.Y}
+
+	if p.cache != nil {
+		data, hit, err := p.cache.Get(&key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cached tile: %v", err)
+		}
+		if hit {
+			return mvt.Decode(data)
+		}
+	}
+
+	url := p.tileURL(tile)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusNotFound:
+		//	the upstream server does not have the tile (i.e. it's beyond the server's max zoom). misses are
+		//	not cached so a server failing with 404s is not remembered once it recovers
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("upstream tile (%v) request failed with status %v", url, resp.StatusCode)
+	}
+
+	//	read one byte past the limit to tell a tile of the max size from a larger one
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxTileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading upstream tile (%v): %v", url, err)
+	}
+	if len(data) > MaxTileSize {
+		return nil, fmt.Errorf("upstream tile (%v) is larger than %v bytes", url, MaxTileSize)
+	}
+
+	//	tiles are usually gzip compressed, which is handled by the decoder
+	t, err := mvt.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding upstream tile (%v): %v", url, err)
+	}
+
+	//	only tiles which decode are cached
+	if p.cache != nil {
+		if err = p.cache.Set(&key, data); err != nil {
+			return nil, fmt.Errorf("error caching tile: %v", err)
+		}
+	}
+
+	return t, nil
+}
+
+func (p *Provider) MVTLayer(ctx context.Context, layerName string, tile tegola.Tile, dtags map[string]interface{}) (layer *mvt.Layer, err error) {
+	plyr, ok := p.Layer(layerName)
+	if !ok {
+		return nil, fmt.Errorf("layer (%v) not found ", layerName)
+	}
+
+	layer = &mvt.Layer{
+		Name: layerName,
+	}
+
+	t, err := p.tile(ctx, tile)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tile (%v/%v/%v) for layer (%v): %v", tile.Z, tile.X, tile.Y, layerName, err)
+	}
+	if t == nil {
+		return layer, nil
+	}
+
+	for _, tl := range t.Layers() {
+		if tl.Name != plyr.upstreamLayer {
+			continue
+		}
+
+		layer, err = tl.WebMercatorLayer(ctx, layerName, tile.BoundingBox(), dtags)
+		if err != nil {
+			if err == ctx.Err() {
+				return nil, err
+			}
+			return nil, fmt.Errorf("unable to convert geometries for layer (%v): %v", layerName, err)
+		}
+		break
+	}
+
+	return layer, nil
+}
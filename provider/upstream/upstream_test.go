@@ -0,0 +1,383 @@
+package upstream_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/airmap/tegola"
+	"github.com/airmap/tegola/basic"
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/provider/upstream"
+)
+
+var athens = tegola.Tile{Z: 10, X: 579, Y: 395}
+
+//	pt converts a lon/lat pair to a webmercator point
+func pt(t *testing.T, lon, lat float64) basic.Point {
+	g, err := basic.ToWebMercator(tegola.WGS84, basic.Point{lon, lat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.Geometry.(basic.Point)
+}
+
+//	encodeTile encodes the layers as a vector tile
+func encodeTile(t *testing.T, tile tegola.Tile, layers ...*mvt.Layer) []byte {
+	var mvtTile mvt.Tile
+	if err := mvtTile.AddLayers(layers...); err != nil {
+		t.Fatal(err)
+	}
+
+	vtile, err := mvtTile.VTile(context.Background(), tile.BoundingBox())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := proto.Marshal(vtile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+//	testServer serves a tile for Athens with a places and a roads layer. Requests without
+//	the api key header are rejected. The number of tile requests is counted.
+func testServer(t *testing.T, requests *int32) *httptest.Server {
+	id := uint64(1)
+	places := mvt.Layer{Name: "places"}
+	places.AddFeatures(mvt.Feature{
+		ID:       &id,
+		Tags:     map[string]interface{}{"name": "Acropolis"},
+		Geometry: pt(t, 23.726, 37.971),
+	})
+	roads := mvt.Layer{Name: "roads"}
+	roads.AddFeatures(mvt.Feature{
+		Tags:     map[string]interface{}{"name": "Ermou"},
+		Geometry: basic.Line{pt(t, 23.72, 37.97), pt(t, 23.73, 37.975)},
+	})
+	data := encodeTile(t, athens, &places, &roads)
+
+	athensPath := fmt.Sprintf("/tiles/%v/%v/%v.pbf", athens.Z, athens.X, athens.Y)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		switch {
+		case r.Header.Get("X-Api-Key") != "secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == athensPath:
+			w.Write(data)
+		case r.URL.Path == "/tiles/0/0/0.pbf":
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.URL.Path == "/tiles/1/0/0.pbf":
+			//	not a vector tile
+			w.Write([]byte("not a tile"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestNewProvider(t *testing.T) {
+	testcases := []struct {
+		config    map[string]interface{}
+		expectErr bool
+	}{
+		{
+			config: map[string]interface{}{
+				upstream.ConfigKeyURL: "http://localhost/{z}/{x}/{y}.pbf",
+				upstream.ConfigKeyLayers: []map[string]interface{}{
+					{upstream.ConfigKeyLayerName: "places"},
+				},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				upstream.ConfigKeyURL: "http://localhost/{z}/{x}/{-y}.pbf",
+				upstream.ConfigKeyLayers: []map[string]interface{}{
+					{upstream.ConfigKeyLayerName: "places"},
+				},
+			},
+		},
+		//	missing y token
+		{
+			config: map[string]interface{}{
+				upstream.ConfigKeyURL: "http://localhost/{z}/{x}.pbf",
+				upstream.ConfigKeyLayers: []map[string]interface{}{
+					{upstream.ConfigKeyLayerName: "places"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				upstream.ConfigKeyURL:     "http://localhost/{z}/{x}/{y}.pbf",
+				upstream.ConfigKeyHeaders: map[string]interface{}{"X-Api-Key": int64(1)},
+				upstream.ConfigKeyLayers: []map[string]interface{}{
+					{upstream.ConfigKeyLayerName: "places"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				upstream.ConfigKeyURL:     "http://localhost/{z}/{x}/{y}.pbf",
+				upstream.ConfigKeyTimeout: int64(0),
+				upstream.ConfigKeyLayers: []map[string]interface{}{
+					{upstream.ConfigKeyLayerName: "places"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			config: map[string]interface{}{
+				upstream.ConfigKeyURL: "http://localhost/{z}/{x}/{y}.pbf",
+			},
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		_, err := upstream.NewProvider(tc.config)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("[%v] expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestMVTLayer(t *testing.T) {
+	var requests int32
+	srv := testServer(t, &requests)
+	defer srv.Close()
+
+	p, err := upstream.NewProvider(map[string]interface{}{
+		upstream.ConfigKeyURL:     srv.URL + "/tiles/{z}/{x}/{y}.pbf",
+		upstream.ConfigKeyHeaders: map[string]interface{}{"X-Api-Key": "secret"},
+		upstream.ConfigKeyLayers: []map[string]interface{}{
+			{upstream.ConfigKeyLayerName: "pois", upstream.ConfigKeyUpstreamLayer: "places"},
+			{upstream.ConfigKeyLayerName: "roads"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		layerName string
+		tile      tegola.Tile
+		dtags     map[string]interface{}
+		expected  []map[string]interface{}
+		expectErr bool
+	}{
+		{
+			layerName: "pois",
+			tile:      athens,
+			dtags:     map[string]interface{}{"source": "upstream"},
+			expected: []map[string]interface{}{
+				{"name": "Acropolis", "source": "upstream"},
+			},
+		},
+		{
+			layerName: "roads",
+			tile:      athens,
+			expected: []map[string]interface{}{
+				{"name": "Ermou"},
+			},
+		},
+		//	the upstream server does not have the tile
+		{
+			layerName: "pois",
+			tile:      tegola.Tile{Z: 10, X: 1, Y: 1},
+		},
+		{
+			layerName: "pois",
+			tile:      tegola.Tile{Z: 0, X: 0, Y: 0},
+			expectErr: true,
+		},
+		{
+			layerName: "water",
+			tile:      athens,
+			expectErr: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		layer, err := p.MVTLayer(context.Background(), tc.layerName, tc.tile, tc.dtags)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("[%v] expected an error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", i, err)
+			continue
+		}
+
+		if layer.Name != tc.layerName {
+			t.Errorf("[%v] layer name, expected %v got %v", i, tc.layerName, layer.Name)
+		}
+
+		var tags []map[string]interface{}
+		for _, f := range layer.Features() {
+			tags = append(tags, f.Tags)
+		}
+		if !reflect.DeepEqual(tags, tc.expected) {
+			t.Errorf("[%v] tags, expected %v got %v", i, tc.expected, tags)
+		}
+	}
+
+	//	the geometries are converted back to webmercator within the precision of the tile
+	layer, err := p.MVTLayer(context.Background(), "pois", athens, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := pt(t, 23.726, 37.971)
+	got, ok := layer.Features()[0].Geometry.(basic.Point)
+	if !ok {
+		t.Fatalf("expected a basic.Point got %T", layer.Features()[0].Geometry)
+	}
+	//	a tile coordinate at z10 with a 4096 extent is ~10m
+	if math.Abs(got.X()-expected.X()) > 10 || math.Abs(got.Y()-expected.Y()) > 10 {
+		t.Errorf("geometry, expected %v got %v", expected, got)
+	}
+}
+
+//	a caller giving up on a shared upstream request does not fail the other callers waiting for it
+func TestCanceledCaller(t *testing.T) {
+	var requests int32
+	upstreamSrv := testServer(t, &requests)
+	defer upstreamSrv.Close()
+
+	//	hold the upstream request until the first caller has given up
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		http.Redirect(w, r, upstreamSrv.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	p, err := upstream.NewProvider(map[string]interface{}{
+		upstream.ConfigKeyURL:     srv.URL + "/tiles/{z}/{x}/{y}.pbf",
+		upstream.ConfigKeyHeaders: map[string]interface{}{"X-Api-Key": "secret"},
+		upstream.ConfigKeyLayers: []map[string]interface{}{
+			{upstream.ConfigKeyLayerName: "places"},
+			{upstream.ConfigKeyLayerName: "roads"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := p.MVTLayer(ctx, "places", athens, nil)
+		canceled <- err
+	}()
+	<-started
+
+	type result struct {
+		layer *mvt.Layer
+		err   error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		layer, err := p.MVTLayer(context.Background(), "roads", athens, nil)
+		waiter <- result{layer, err}
+	}()
+
+	cancel()
+	if err := <-canceled; err == nil {
+		t.Error("expected the canceled caller to fail, got nil")
+	}
+	close(release)
+
+	res := <-waiter
+	if res.err != nil {
+		t.Fatalf("unexpected error: %v", res.err)
+	}
+	if len(res.layer.Features()) != 1 {
+		t.Errorf("expected 1 feature got %v", len(res.layer.Features()))
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tegola-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var requests int32
+	srv := testServer(t, &requests)
+	defer srv.Close()
+
+	config := map[string]interface{}{
+		upstream.ConfigKeyURL:      srv.URL + "/tiles/{z}/{x}/{y}.pbf",
+		upstream.ConfigKeyHeaders:  map[string]interface{}{"X-Api-Key": "secret"},
+		upstream.ConfigKeyCacheDir: dir,
+		upstream.ConfigKeyLayers: []map[string]interface{}{
+			{upstream.ConfigKeyLayerName: "places"},
+		},
+	}
+
+	p, err := upstream.NewProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := tegola.Tile{Z: 10, X: 1, Y: 1}
+	for i := 0; i < 2; i++ {
+		for _, tile := range []tegola.Tile{athens, missing} {
+			if _, err := p.MVTLayer(context.Background(), "places", tile, nil); err != nil {
+				t.Fatalf("[%v] unexpected error: %v", i, err)
+			}
+		}
+	}
+
+	//	undecodable tiles are not cached
+	invalid := tegola.Tile{Z: 1, X: 0, Y: 0}
+	for i := 0; i < 2; i++ {
+		if _, err := p.MVTLayer(context.Background(), "places", invalid, nil); err == nil {
+			t.Errorf("[%v] expected an error for an invalid tile", i)
+		}
+	}
+
+	//	the tile is only requested once. the missing and invalid tiles are not cached and are requested again
+	if requests != 5 {
+		t.Errorf("expected 5 upstream requests got %v", requests)
+	}
+
+	//	the cache is read by new providers
+	srv.Close()
+	if p, err = upstream.NewProvider(config); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := p.MVTLayer(context.Background(), "places", athens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(layer.Features()) != 1 {
+		t.Errorf("expected 1 feature got %v", len(layer.Features()))
+	}
+}
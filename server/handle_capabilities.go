@@ -28,6 +28,8 @@ type CapabilitiesLayer struct {
 	Tiles   []string `json:"tiles"`
 	MinZoom int      `json:"minzoom"`
 	MaxZoom int      `json:"maxzoom"`
+	//	the layer's attribute names mapped to their type, if known
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 type HandleCapabilities struct{}
@@ -78,43 +80,17 @@ func (req HandleCapabilities) ServeHTTP(w http.ResponseWriter, r *http.Request)
 				Capabilities: fmt.Sprintf("%v://%v/capabilities/%v.json%v", scheme(r), hostName(r), m.Name, debugQuery),
 			}
 
-			for i := range m.Layers {
-				//	skip disabled layers
-				if m.Layers[i].Disabled {
-					continue
-				}
-
-				//	check if the layer already exists in our slice. this can happen if the config
-				//	is using the "name" param for a layer to override the providerLayerName
-				var skip bool
-				for j := range cMap.Layers {
-					if cMap.Layers[j].Name == m.Layers[i].MVTName() {
-						//	we need to use the min and max of all layers with this name
-						if cMap.Layers[j].MinZoom > m.Layers[i].MinZoom {
-							cMap.Layers[j].MinZoom = m.Layers[i].MinZoom
-						}
-
-						if cMap.Layers[j].MaxZoom < m.Layers[i].MaxZoom {
-							cMap.Layers[j].MaxZoom = m.Layers[i].MaxZoom
-						}
-
-						skip = true
-						break
-					}
-				}
-				//	entry for layer already exists. move on
-				if skip {
-					continue
-				}
-
+			//	the layers are listed as they are in the map's tileJSON
+			for _, l := range m.TileJSON().VectorLayers {
 				//	build the layer details
 				cLayer := CapabilitiesLayer{
-					Name: m.Layers[i].MVTName(),
+					Name: l.Name,
 					Tiles: []string{
-						fmt.Sprintf("%v://%v/maps/%v/%v/{z}/{x}/{y}.pbf%v", scheme(r), hostName(r), m.Name, m.Layers[i].MVTName(), debugQuery),
+						fmt.Sprintf("%v://%v/maps/%v/%v/{z}/{x}/{y}.pbf%v", scheme(r), hostName(r), m.Name, l.Name, debugQuery),
 					},
-					MinZoom: m.Layers[i].MinZoom,
-					MaxZoom: m.Layers[i].MaxZoom,
+					MinZoom: l.MinZoom,
+					MaxZoom: l.MaxZoom,
+					Fields:  l.Fields,
 				}
 
 				//	add the layer to the map
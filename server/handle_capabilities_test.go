@@ -53,6 +53,7 @@ func TestHandleCapabilities(t *testing.T) {
 								},
 								MinZoom: testLayer1.MinZoom,
 								MaxZoom: testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+								Fields:  testLayer1And3Fields,
 							},
 							{
 								Name: testLayer2.MVTName(),
@@ -105,6 +106,30 @@ func TestHandleCapabilities(t *testing.T) {
 								MinZoom: 0,
 								MaxZoom: atlas.MaxZoom,
 							},
+							{
+								Name: "debug-tile-buffer",
+								Tiles: []string{
+									"http://cdn.tegola.io/maps/test-map/debug-tile-buffer/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
+							{
+								Name: "debug-tile-grid",
+								Tiles: []string{
+									"http://cdn.tegola.io/maps/test-map/debug-tile-grid/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
+							{
+								Name: "debug-tile-stats",
+								Tiles: []string{
+									"http://cdn.tegola.io/maps/test-map/debug-tile-stats/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
 							{
 								Name: testLayer1.MVTName(),
 								Tiles: []string{
@@ -112,6 +137,7 @@ func TestHandleCapabilities(t *testing.T) {
 								},
 								MinZoom: testLayer1.MinZoom,
 								MaxZoom: testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+								Fields:  testLayer1And3Fields,
 							},
 							{
 								Name: "test-layer-2-name",
@@ -151,6 +177,7 @@ func TestHandleCapabilities(t *testing.T) {
 								},
 								MinZoom: testLayer1.MinZoom,
 								MaxZoom: testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+								Fields:  testLayer1And3Fields,
 							},
 							{
 								Name: testLayer2.MVTName(),
@@ -203,6 +230,30 @@ func TestHandleCapabilities(t *testing.T) {
 								MinZoom: 0,
 								MaxZoom: atlas.MaxZoom,
 							},
+							{
+								Name: "debug-tile-buffer",
+								Tiles: []string{
+									"http://cdn.tegola.io/maps/test-map/debug-tile-buffer/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
+							{
+								Name: "debug-tile-grid",
+								Tiles: []string{
+									"http://cdn.tegola.io/maps/test-map/debug-tile-grid/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
+							{
+								Name: "debug-tile-stats",
+								Tiles: []string{
+									"http://cdn.tegola.io/maps/test-map/debug-tile-stats/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
 							{
 								Name: testLayer1.MVTName(),
 								Tiles: []string{
@@ -210,6 +261,7 @@ func TestHandleCapabilities(t *testing.T) {
 								},
 								MinZoom: testLayer1.MinZoom,
 								MaxZoom: testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+								Fields:  testLayer1And3Fields,
 							},
 							{
 								Name: "test-layer-2-name",
@@ -261,6 +313,30 @@ func TestHandleCapabilities(t *testing.T) {
 								MinZoom: 0,
 								MaxZoom: atlas.MaxZoom,
 							},
+							{
+								Name: "debug-tile-buffer",
+								Tiles: []string{
+									"http://cdn.tegola.io:8080/maps/test-map/debug-tile-buffer/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
+							{
+								Name: "debug-tile-grid",
+								Tiles: []string{
+									"http://cdn.tegola.io:8080/maps/test-map/debug-tile-grid/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
+							{
+								Name: "debug-tile-stats",
+								Tiles: []string{
+									"http://cdn.tegola.io:8080/maps/test-map/debug-tile-stats/{z}/{x}/{y}.pbf?debug=true",
+								},
+								MinZoom: 0,
+								MaxZoom: atlas.MaxZoom,
+							},
 							{
 								Name: testLayer1.MVTName(),
 								Tiles: []string{
@@ -268,6 +344,7 @@ func TestHandleCapabilities(t *testing.T) {
 								},
 								MinZoom: testLayer1.MinZoom,
 								MaxZoom: testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+								Fields:  testLayer1And3Fields,
 							},
 							{
 								Name: "test-layer-2-name",
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/airmap/tegola/mvt"
+)
+
+//	HealthCheckTimeout is how long the providers have to report their health
+var HealthCheckTimeout = 5 * time.Second
+
+//	the status of a healthy server or provider
+const healthOK = "ok"
+
+type Health struct {
+	//	ok, or error if any of the providers are unhealthy
+	Status string `json:"status"`
+	//	the status of each provider that reports its health. ok or the reason it's unhealthy
+	Providers map[string]string `json:"providers"`
+}
+
+//	HandleHealth reports if the providers are able to serve tiles. Providers that don't implement
+//	mvt.HealthChecker are assumed to be healthy and are not listed.
+//
+//	URI scheme: /health
+//	Responds with a 503 status if any of the providers are unhealthy
+type HandleHealth struct{}
+
+func (req HandleHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), HealthCheckTimeout)
+	defer cancel()
+
+	health := Health{
+		Status:    healthOK,
+		Providers: map[string]string{},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, p := range Providers {
+		hc, ok := p.(mvt.HealthChecker)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, hc mvt.HealthChecker) {
+			defer wg.Done()
+
+			status := healthOK
+			if err := hc.Health(ctx); err != nil {
+				status = err.Error()
+			}
+
+			mu.Lock()
+			health.Providers[name] = status
+			if status != healthOK {
+				health.Status = "error"
+			}
+			mu.Unlock()
+		}(name, hc)
+	}
+	wg.Wait()
+
+	//	content type
+	w.Header().Add("Content-Type", "application/json")
+
+	//	cache control headers (no-cache)
+	w.Header().Add("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Add("Pragma", "no-cache")
+	w.Header().Add("Expires", "0")
+
+	if health.Status != healthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(health)
+}
@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/airmap/tegola/mvt"
+	"github.com/airmap/tegola/server"
+)
+
+//	testHealthProvider is a provider reporting its health
+type testHealthProvider struct {
+	testMVTProvider
+	err error
+}
+
+func (tp *testHealthProvider) Health(ctx context.Context) error {
+	return tp.err
+}
+
+func TestHandleHealth(t *testing.T) {
+	testcases := []struct {
+		providers      map[string]mvt.Provider
+		expectedStatus int
+		expected       server.Health
+	}{
+		{
+			providers: map[string]mvt.Provider{
+				"db":   &testHealthProvider{},
+				"test": &testMVTProvider{},
+			},
+			expectedStatus: http.StatusOK,
+			expected: server.Health{
+				Status: "ok",
+				//	providers without a health check are not listed
+				Providers: map[string]string{
+					"db": "ok",
+				},
+			},
+		},
+		{
+			providers: map[string]mvt.Provider{
+				"db":      &testHealthProvider{},
+				"replica": &testHealthProvider{err: errors.New("connection refused")},
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expected: server.Health{
+				Status: "error",
+				Providers: map[string]string{
+					"db":      "ok",
+					"replica": "connection refused",
+				},
+			},
+		},
+	}
+
+	defer func() {
+		server.Providers = nil
+	}()
+
+	for i, tc := range testcases {
+		server.Providers = tc.providers
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/health", nil)
+
+		server.HandleHealth{}.ServeHTTP(w, r)
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("[%v] status code, expected %v got %v", i, tc.expectedStatus, w.Code)
+			continue
+		}
+
+		var output server.Health
+		if err := json.NewDecoder(w.Body).Decode(&output); err != nil {
+			t.Errorf("[%v] error decoding response: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(output, tc.expected) {
+			t.Errorf("[%v] expected %+v got %+v", i, tc.expected, output)
+		}
+	}
+}
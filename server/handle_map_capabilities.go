@@ -8,9 +8,7 @@ import (
 	"strings"
 
 	"github.com/dimfeld/httptreemux"
-	"github.com/airmap/tegola"
 	"github.com/airmap/tegola/atlas"
-	"github.com/airmap/tegola/mapbox/tilejson"
 )
 
 type HandleMapCapabilities struct {
@@ -63,19 +61,6 @@ func (req HandleMapCapabilities) ServeHTTP(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		tileJSON := tilejson.TileJSON{
-			Attribution: &m.Attribution,
-			Bounds:      m.Bounds,
-			Center:      m.Center,
-			Format:      "pbf",
-			Name:        &m.Name,
-			Scheme:      tilejson.SchemeXYZ,
-			TileJSON:    tilejson.Version,
-			Version:     "1.0.0",
-			Grids:       make([]string, 0),
-			Data:        make([]string, 0),
-		}
-
 		//	parse our query string
 		var query = r.URL.Query()
 
@@ -88,77 +73,13 @@ func (req HandleMapCapabilities) ServeHTTP(w http.ResponseWriter, r *http.Reques
 			m = m.EnableDebugLayers()
 		}
 
-		for i := range m.Layers {
-			//	skip disabled layers
-			if m.Layers[i].Disabled {
-				continue
-			}
-
-			//	check if the layer already exists in our slice. this can happen if the config
-			//	is using the "name" param for a layer to override the providerLayerName
-			var skip bool
-			for j := range tileJSON.VectorLayers {
-				if tileJSON.VectorLayers[j].ID == m.Layers[i].MVTName() {
-					//	we need to use the min and max of all layers with this name
-					if tileJSON.VectorLayers[j].MinZoom > m.Layers[i].MinZoom {
-						tileJSON.VectorLayers[j].MinZoom = m.Layers[i].MinZoom
-					}
-
-					if tileJSON.VectorLayers[j].MaxZoom < m.Layers[i].MaxZoom {
-						tileJSON.VectorLayers[j].MaxZoom = m.Layers[i].MaxZoom
-					}
-
-					skip = true
-					break
-				}
-			}
-			//	entry for layer already exists. move on
-			if skip {
-				continue
-			}
-
-			//	the first layer sets the initial min / max otherwise they default to 0/0
-			if len(tileJSON.VectorLayers) == 0 {
-				tileJSON.MinZoom = m.Layers[i].MinZoom
-				tileJSON.MaxZoom = m.Layers[i].MaxZoom
-			}
+		tileJSON := m.TileJSON()
 
-			//	check if we have a min zoom lower then our current min
-			if tileJSON.MinZoom > m.Layers[i].MinZoom {
-				tileJSON.MinZoom = m.Layers[i].MinZoom
+		//	each layer can be requested on its own
+		for i := range tileJSON.VectorLayers {
+			tileJSON.VectorLayers[i].Tiles = []string{
+				fmt.Sprintf("%v://%v/maps/%v/%v/{z}/{x}/{y}.pbf%v", scheme(r), hostName(r), req.mapName, tileJSON.VectorLayers[i].ID, debugQuery),
 			}
-
-			//	check if we have a max zoom higher then our current max
-			if tileJSON.MaxZoom < m.Layers[i].MaxZoom {
-				tileJSON.MaxZoom = m.Layers[i].MaxZoom
-			}
-
-			//	build our vector layer details
-			layer := tilejson.VectorLayer{
-				Version: 2,
-				Extent:  4096,
-				ID:      m.Layers[i].MVTName(),
-				Name:    m.Layers[i].MVTName(),
-				MinZoom: m.Layers[i].MinZoom,
-				MaxZoom: m.Layers[i].MaxZoom,
-				Tiles: []string{
-					fmt.Sprintf("%v://%v/maps/%v/%v/{z}/{x}/{y}.pbf%v", scheme(r), hostName(r), req.mapName, m.Layers[i].MVTName(), debugQuery),
-				},
-			}
-
-			switch m.Layers[i].GeomType.(type) {
-			case tegola.Point, tegola.MultiPoint:
-				layer.GeometryType = tilejson.GeomTypePoint
-			case tegola.LineString, tegola.MultiLine:
-				layer.GeometryType = tilejson.GeomTypeLine
-			case tegola.Polygon, tegola.MultiPolygon:
-				layer.GeometryType = tilejson.GeomTypePolygon
-			default:
-				layer.GeometryType = tilejson.GeomTypeUnknown
-			}
-
-			//	add our layer to our tile layer response
-			tileJSON.VectorLayers = append(tileJSON.VectorLayers, layer)
 		}
 
 		tileURL := fmt.Sprintf("%v://%v/maps/%v/{z}/{x}/{y}.pbf%v", scheme(r), hostName(r), req.mapName, debugQuery)
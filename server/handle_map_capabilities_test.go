@@ -60,6 +60,7 @@ func TestHandleMapCapabilities(t *testing.T) {
 						GeometryType: tilejson.GeomTypePoint,
 						MinZoom:      testLayer1.MinZoom,
 						MaxZoom:      testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+						Fields:       testLayer1And3Fields,
 						Tiles: []string{
 							fmt.Sprintf("http://localhost:8080/maps/test-map/%v/{z}/{x}/{y}.pbf", testLayer1.MVTName()),
 						},
@@ -130,6 +131,42 @@ func TestHandleMapCapabilities(t *testing.T) {
 							"http://cdn.tegola.io/maps/test-map/debug-tile-center/{z}/{x}/{y}.pbf?debug=true",
 						},
 					},
+					{
+						Version:      2,
+						Extent:       4096,
+						ID:           "debug-tile-buffer",
+						Name:         "debug-tile-buffer",
+						GeometryType: tilejson.GeomTypePolygon,
+						MinZoom:      0,
+						MaxZoom:      atlas.MaxZoom,
+						Tiles: []string{
+							"http://cdn.tegola.io/maps/test-map/debug-tile-buffer/{z}/{x}/{y}.pbf?debug=true",
+						},
+					},
+					{
+						Version:      2,
+						Extent:       4096,
+						ID:           "debug-tile-grid",
+						Name:         "debug-tile-grid",
+						GeometryType: tilejson.GeomTypeLine,
+						MinZoom:      0,
+						MaxZoom:      atlas.MaxZoom,
+						Tiles: []string{
+							"http://cdn.tegola.io/maps/test-map/debug-tile-grid/{z}/{x}/{y}.pbf?debug=true",
+						},
+					},
+					{
+						Version:      2,
+						Extent:       4096,
+						ID:           "debug-tile-stats",
+						Name:         "debug-tile-stats",
+						GeometryType: tilejson.GeomTypePoint,
+						MinZoom:      0,
+						MaxZoom:      atlas.MaxZoom,
+						Tiles: []string{
+							"http://cdn.tegola.io/maps/test-map/debug-tile-stats/{z}/{x}/{y}.pbf?debug=true",
+						},
+					},
 					{
 						Version:      2,
 						Extent:       4096,
@@ -138,6 +175,7 @@ func TestHandleMapCapabilities(t *testing.T) {
 						GeometryType: tilejson.GeomTypePoint,
 						MinZoom:      testLayer1.MinZoom,
 						MaxZoom:      testLayer3.MaxZoom, //	layer 1 and layer 3 share a name in our test so the zoom range includes the entire zoom range
+						Fields:       testLayer1And3Fields,
 						Tiles: []string{
 							fmt.Sprintf("http://cdn.tegola.io/maps/test-map/%v/{z}/{x}/{y}.pbf?debug=true", testLayer1.MVTName()),
 						},
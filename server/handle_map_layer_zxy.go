@@ -138,7 +138,10 @@ func (req HandleMapLayerZXY) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			m = m.EnableDebugLayers()
 		}
 
-		pbyte, err := m.Encode(r.Context(), tile)
+		//	pass the request parameters the map's layers accept down to the providers
+		ctx := mvt.ContextWithParams(r.Context(), m.Params(r.URL.Query()))
+
+		pbyte, err := m.Encode(ctx, tile)
 		if err != nil {
 			switch err {
 			case mvt.ErrCanceled:
@@ -32,6 +32,11 @@ func TileCacheHandler(next http.Handler) http.Handler {
 			return
 		}
 
+		//	tiles filtered by request parameters are cached separately
+		if m, err := Atlas.Map(key.MapName); err == nil {
+			key.Params = m.Params(r.URL.Query()).Encode()
+		}
+
 		//	use the URL path as the key
 		cachedTile, hit, err := cacher.Get(key)
 		if err != nil {
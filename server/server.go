@@ -2,12 +2,16 @@
 package server
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/dimfeld/httptreemux"
 	"github.com/airmap/tegola/atlas"
+	"github.com/airmap/tegola/mvt"
 )
 
 const (
@@ -23,11 +27,24 @@ var (
 	HostName string
 	//	configurable via the tegola config.toml file (set in main.go)
 	Port string
+	//	serve the metrics published with the expvar package at /debug/vars. configurable
+	//	via the tegola config.toml file (set in main.go)
+	DebugVars bool
 	//	reference to the version of atlas to work with
 	Atlas *atlas.Atlas
+	//	the providers, keyed by name, checked by the health endpoint (set in main.go)
+	Providers map[string]mvt.Provider
+
+	//	the running server. nil until Start is called
+	srv *http.Server
+	//	set by Shutdown so a server that is not started yet does not start
+	shutDown bool
+	//	guards srv and shutDown
+	srvMu sync.Mutex
 )
 
-//	Start starts the tile server binding to the provided port
+//	Start starts the tile server binding to the provided port. It blocks until the server is shut down.
+//	It returns immediately if Shutdown was called first.
 func Start(port string) {
 	Atlas = atlas.DefaultAtlas
 
@@ -52,12 +69,48 @@ func Start(port string) {
 	group.UsingContext().Handler("GET", "/maps/:map_name/:layer_name/:z/:x/:y", TileCacheHandler(HandleMapLayerZXY{}))
 	group.UsingContext().Handler("OPTIONS", "/maps/:map_name/:layer_name/:z/:x/:y", HandleMapLayerZXY{})
 
+	//	health endpoint
+	group.UsingContext().Handler("GET", "/health", HandleHealth{})
+
+	//	metrics (i.e. the state of the providers' circuit breakers)
+	if DebugVars {
+		group.UsingContext().Handler("GET", "/debug/vars", expvar.Handler())
+	}
+
 	//	static convenience routes
 	group.UsingContext().Handler("GET", "/", http.FileServer(assetFS()))
 	group.UsingContext().Handler("GET", "/*path", http.FileServer(assetFS()))
 
 	//	start our server
-	log.Fatal(http.ListenAndServe(port, r))
+	s := &http.Server{Addr: port, Handler: r}
+	srvMu.Lock()
+	if shutDown {
+		srvMu.Unlock()
+		log.Printf("tegola server was shut down before it started")
+		return
+	}
+	srv = s
+	srvMu.Unlock()
+
+	if err := s.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+//	Shutdown stops the server from accepting new requests and waits, until the context
+//	is done, for the requests in progress to complete. If the server is not started yet
+//	a later call to Start returns without serving.
+func Shutdown(ctx context.Context) error {
+	srvMu.Lock()
+	s := srv
+	shutDown = true
+	srvMu.Unlock()
+
+	if s == nil {
+		return nil
+	}
+
+	return s.Shutdown(ctx)
 }
 
 //	determines the hostname:port to return based on the following hierarchy
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/airmap/tegola/atlas"
 )
 
 func TestHostName(t *testing.T) {
@@ -99,3 +103,30 @@ func TestScheme(t *testing.T) {
 		}
 	}
 }
+
+func TestShutdownBeforeStart(t *testing.T) {
+	//	restore the package state for the other tests
+	defer func(a *atlas.Atlas) {
+		Atlas = a
+		srvMu.Lock()
+		srv, shutDown = nil, false
+		srvMu.Unlock()
+	}(Atlas)
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	started := make(chan struct{})
+	go func() {
+		Start("127.0.0.1:0")
+		close(started)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Errorf("expected Start to return after Shutdown")
+		Shutdown(context.Background())
+	}
+}
@@ -48,6 +48,9 @@ var testLayer1 = atlas.Layer{
 	MaxZoom:           9,
 	Provider:          &testMVTProvider{},
 	GeomType:          basic.Point{},
+	Fields: map[string]string{
+		"name": mvt.FieldTypeString,
+	},
 	DefaultTags: map[string]interface{}{
 		"foo": "bar",
 	},
@@ -72,7 +75,17 @@ var testLayer3 = atlas.Layer{
 	MaxZoom:           20,
 	Provider:          &testMVTProvider{},
 	GeomType:          basic.Point{},
-	DefaultTags:       map[string]interface{}{},
+	Fields: map[string]string{
+		"name":   mvt.FieldTypeNumber,
+		"height": mvt.FieldTypeNumber,
+	},
+	DefaultTags: map[string]interface{}{},
+}
+
+//	layer 1 and layer 3 share a name so their fields are merged. layer 1's type wins for "name"
+var testLayer1And3Fields = map[string]string{
+	"name":   mvt.FieldTypeString,
+	"height": mvt.FieldTypeNumber,
 }
 
 type layer struct {